@@ -1,7 +1,9 @@
 package ntm
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"math"
 )
@@ -10,12 +12,33 @@ type similarityCircuit struct {
 	U   []Unit
 	V   []Unit
 	Top Unit
+	Dot bool // whether Top is the dot product of U and V instead of their cosine similarity
 
 	UV    float64
 	Unorm float64
 	Vnorm float64
 }
 
+// newDotSimilarity returns a similarityCircuit whose Top is the dot product of u and v.
+func newDotSimilarity(u, v []Unit) *similarityCircuit {
+	s := similarityCircuit{
+		U:   u,
+		V:   v,
+		Dot: true,
+	}
+	for i := 0; i < len(u); i++ {
+		s.UV += u[i].Val * v[i].Val
+	}
+	s.Top.Val = s.UV
+	if val := s.Top.Val; math.IsNaN(val) || math.IsInf(val, 0) {
+		s.Top.Val = degenerate("similarity", val, func() {}, func() string { return fmt.Sprintf("u: %+v, v: %+v", u, v) })
+	}
+	if errorPolicy == ErrorOnDegenerate && (isZero(u) || isZero(v)) {
+		panic(&DegenerateError{Component: "similarity", Reason: "zero norm", Inputs: fmt.Sprintf("u: %+v, v: %+v", u, v)})
+	}
+	return &s
+}
+
 func newSimilarityCircuit(u, v []Unit) *similarityCircuit {
 	s := similarityCircuit{
 		U: u,
@@ -28,18 +51,41 @@ func newSimilarityCircuit(u, v []Unit) *similarityCircuit {
 	}
 	s.Unorm = math.Sqrt(s.Unorm)
 	s.Vnorm = math.Sqrt(s.Vnorm)
-	s.Top.Val = s.UV / (s.Unorm * s.Vnorm)
-	if math.IsNaN(s.Top.Val) {
-		log.Printf("u: %+v, v: %+v", u, v)
-		panic("")
+	// Add MachineEpsilon to the denominator to keep the similarity finite for zero vectors.
+	s.Top.Val = s.UV / (s.Unorm*s.Vnorm + MachineEpsilon)
+	if val := s.Top.Val; math.IsNaN(val) || math.IsInf(val, 0) {
+		s.Top.Val = degenerate("similarity", val, func() {
+			if math.IsNaN(val) {
+				log.Printf("u: %+v, v: %+v", u, v)
+				panic("")
+			}
+		}, func() string { return fmt.Sprintf("u: %+v, v: %+v", u, v) })
+	}
+	if errorPolicy == ErrorOnDegenerate && (s.Unorm == 0 || s.Vnorm == 0) {
+		panic(&DegenerateError{Component: "similarity", Reason: "zero norm", Inputs: fmt.Sprintf("u: %+v, v: %+v", u, v)})
 	}
 	return &s
 }
 
 func (s *similarityCircuit) Backward() {
-	uvuu := s.UV / (s.Unorm * s.Unorm)
-	uvvv := s.UV / (s.Vnorm * s.Vnorm)
-	uvg := s.Top.Grad / (s.Unorm * s.Vnorm)
+	if s.Dot {
+		for i, u := range s.U {
+			s.U[i].Grad += s.V[i].Val * s.Top.Grad
+			s.V[i].Grad += u.Val * s.Top.Grad
+		}
+		return
+	}
+	d := s.Unorm*s.Vnorm + MachineEpsilon
+	// The gradients of the norms vanish for zero vectors.
+	var uvuu float64 = 0
+	if s.Unorm > 0 {
+		uvuu = s.UV * s.Vnorm / (s.Unorm * d)
+	}
+	var uvvv float64 = 0
+	if s.Vnorm > 0 {
+		uvvv = s.UV * s.Unorm / (s.Vnorm * d)
+	}
+	uvg := s.Top.Grad / d
 	for i, u := range s.U {
 		v := s.V[i].Val
 		s.U[i].Grad += (v - u.Val*uvuu) * uvg
@@ -47,54 +93,134 @@ func (s *similarityCircuit) Backward() {
 	}
 }
 
+// A normalizedKey is the key of a memory head divided by its L2 norm,
+// leaving the key strength Beta as the only control of the sharpness of content addressing.
+type normalizedKey struct {
+	K   []Unit
+	Top []Unit
+
+	norm float64
+}
+
+func newNormalizedKey(k []Unit) *normalizedKey {
+	nk := normalizedKey{
+		K:   k,
+		Top: make([]Unit, len(k)),
+	}
+	for _, u := range k {
+		nk.norm += u.Val * u.Val
+	}
+	nk.norm = math.Sqrt(nk.norm)
+	// Add MachineEpsilon to the denominator to keep the key finite when it is zero.
+	for i, u := range k {
+		nk.Top[i].Val = u.Val / (nk.norm + MachineEpsilon)
+	}
+	return &nk
+}
+
+func (nk *normalizedKey) Backward() {
+	d := nk.norm + MachineEpsilon
+	var kg float64 = 0
+	for i, u := range nk.K {
+		kg += u.Val * nk.Top[i].Grad
+	}
+	// The gradient of the norm vanishes for a zero key.
+	var c float64 = 0
+	if nk.norm > 0 {
+		c = kg / (nk.norm * d * d)
+	}
+	for i, u := range nk.K {
+		nk.K[i].Grad += nk.Top[i].Grad/d - u.Val*c
+	}
+}
+
 type betaSimilarity struct {
 	Beta *Unit // Beta is assumed to be in the range (-Inf, Inf)
 	S    *similarityCircuit
 	Top  Unit
+	Max  float64 // the upper clamp of the key strength exp(Beta), zero if unclamped
 
-	b float64
+	b       float64
+	clamped bool
 }
 
-func newBetaSimilarity(beta *Unit, s *similarityCircuit) *betaSimilarity {
+func newBetaSimilarity(beta *Unit, s *similarityCircuit, max float64) *betaSimilarity {
 	bs := betaSimilarity{
 		Beta: beta,
 		S:    s,
-		b:    math.Exp(beta.Val),
+		Max:  max,
 	}
+	bs.b, bs.clamped = clamp(math.Exp(beta.Val), max)
 	bs.Top.Val = bs.b * s.Top.Val
+	if v := bs.Top.Val; math.IsNaN(v) || math.IsInf(v, 0) {
+		bs.Top.Val = degenerate("betaSimilarity", v, func() {}, func() string {
+			return fmt.Sprintf("beta: %f, similarity: %f", beta.Val, s.Top.Val)
+		})
+	}
 	return &bs
 }
 
 func (bs *betaSimilarity) Backward() {
-	bs.Beta.Grad += bs.S.Top.Val * bs.b * bs.Top.Grad
+	if !bs.clamped {
+		bs.Beta.Grad += bs.S.Top.Val * bs.b * bs.Top.Grad
+	}
 	bs.S.Top.Grad += bs.b * bs.Top.Grad
 }
 
+// clamp returns the smaller of x and max and whether x exceeds max, unless max is zero in which case x is returned unchanged.
+func clamp(x, max float64) (float64, bool) {
+	if max == 0 || x <= max {
+		return x, false
+	}
+	return max, true
+}
+
 type contentAddressing struct {
 	Units []*betaSimilarity
 	Top   []Unit
+	Key   *normalizedKey // the normalized key compared by Units, nil if the key is compared as is
 }
 
-func newContentAddressing(units []*betaSimilarity) *contentAddressing {
+// newContentAddressing returns the softmax of the beta similarities units.
+// If compensated is true, the exponentials are summed with Kahan summation.
+func newContentAddressing(units []*betaSimilarity, compensated bool) *contentAddressing {
 	s := contentAddressing{
 		Units: units,
 		Top:   make([]Unit, len(units)),
 	}
 	// Increase numerical stability by subtracting all weights by their max,
 	// before computing math.Exp().
+	// The max is deterministic even if several weights tie, since only its value is used.
 	var max float64 = -math.MaxFloat64
 	for _, u := range s.Units {
 		max = math.Max(max, u.Top.Val)
 	}
 	var sum float64 = 0
+	var ksum kahanSum
 	for i, u := range s.Units {
 		w := math.Exp(u.Top.Val - max)
 		s.Top[i].Val = w
-		sum += w
+		if compensated {
+			ksum.add(w)
+		} else {
+			sum += w
+		}
+	}
+	if compensated {
+		sum = ksum.value()
 	}
 	for i, top := range s.Top {
 		s.Top[i].Val = top.Val / sum
 	}
+	if !finite(s.Top) {
+		degenerateUnits("contentAddressing", s.Top, func() string {
+			sims := make([]float64, len(units))
+			for i, u := range units {
+				sims[i] = u.Top.Val
+			}
+			return fmt.Sprintf("beta similarities: %v", sims)
+		})
+	}
 	return &s
 }
 
@@ -126,6 +252,9 @@ func newGatedWeighting(g *Unit, wc *contentAddressing, wtm1 *refocus) *gatedWeig
 	for i := 0; i < len(wg.Top); i++ {
 		wg.Top[i].Val = gt*wc.Top[i].Val + (1-gt)*wtm1.Top[i].Val
 	}
+	if !finite(wg.Top) {
+		degenerateUnits("gatedWeighting", wg.Top, func() string { return fmt.Sprintf("g: %f, wtm1: %+v", g.Val, wtm1.Top) })
+	}
 	return &wg
 }
 
@@ -148,17 +277,24 @@ func (wg *gatedWeighting) Backward() {
 }
 
 type shiftedWeighting struct {
-	S   *Unit
-	Z   float64
-	WG  *gatedWeighting
-	Top []Unit
+	S        *Unit
+	Z        float64
+	WG       *gatedWeighting
+	Top      []Unit
+	MaxShift float64 // the shift is restricted to the range (-MaxShift, MaxShift)
+
+	// If not nil, the weights are rotated by the circular convolution with the softmax of Logits,
+	// in which case S and Z are unused.
+	Logits []Unit
+	p      []float64 // softmax of Logits
 }
 
-func newShiftedWeighting(s *Unit, wg *gatedWeighting) *shiftedWeighting {
+func newShiftedWeighting(s *Unit, maxShift int, wg *gatedWeighting) *shiftedWeighting {
 	sw := shiftedWeighting{
-		S:   s,
-		WG:  wg,
-		Top: make([]Unit, len(wg.Top)),
+		S:        s,
+		WG:       wg,
+		Top:      make([]Unit, len(wg.Top)),
+		MaxShift: float64(maxShift),
 	}
 
 	n := len(sw.WG.Top)
@@ -168,22 +304,78 @@ func newShiftedWeighting(s *Unit, wg *gatedWeighting) *shiftedWeighting {
 	//}
 
 	//sw.Z = float64(n) * Sigmoid(s.Val)
-	shift := (2*Sigmoid(s.Val) - 1) // * maxShift
+	shift := (2*Sigmoid(s.Val) - 1) * sw.MaxShift
 	sw.Z = math.Mod(shift+float64(n), float64(n))
 
 	simj := 1 - (sw.Z - math.Floor(sw.Z))
 	for i := 0; i < len(sw.Top); i++ {
 		imj := (i + int(sw.Z)) % n
 		sw.Top[i].Val = sw.WG.Top[imj].Val*simj + sw.WG.Top[(imj+1)%n].Val*(1-simj)
-		if math.IsNaN(sw.Top[i].Val) || sw.Top[i].Val < 0 {
-			log.Printf("imj: %d, wg: %f, simj: %f, wg+1: %f", imj, sw.WG.Top[imj].Val, simj, sw.WG.Top[(imj+1)%n].Val)
-			panic("")
+		if v := sw.Top[i].Val; math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+			inputs := func() string {
+				return fmt.Sprintf("imj: %d, wg: %f, simj: %f, wg+1: %f", imj, sw.WG.Top[imj].Val, simj, sw.WG.Top[(imj+1)%n].Val)
+			}
+			sw.Top[i].Val = math.Max(0, degenerate("shiftedWeighting", v, func() {
+				if math.IsNaN(v) || v < 0 {
+					log.Print(inputs())
+					panic("")
+				}
+			}, inputs))
 		}
 	}
 	return &sw
 }
 
+// newLogitShiftedWeighting returns a shiftedWeighting that rotates the weights of wg by the shift distribution softmax(logits),
+// where logits[k] corresponds to a shift of k-r locations, r = (len(logits)-1)/2.
+func newLogitShiftedWeighting(logits []Unit, wg *gatedWeighting) *shiftedWeighting {
+	sw := shiftedWeighting{
+		WG:     wg,
+		Top:    make([]Unit, len(wg.Top)),
+		Logits: logits,
+		p:      make([]float64, len(logits)),
+	}
+
+	maxLogit := math.Inf(-1)
+	for _, l := range logits {
+		maxLogit = math.Max(maxLogit, l.Val)
+	}
+	var sum float64 = 0
+	for k, l := range logits {
+		sw.p[k] = math.Exp(l.Val - maxLogit)
+		sum += sw.p[k]
+	}
+	for k := range sw.p {
+		sw.p[k] = sw.p[k] / sum
+	}
+
+	var top []float64
+	if useFFT(len(sw.Top), len(logits)) {
+		top = shiftFFT(unitVals(sw.WG.Top), sw.p)
+	} else {
+		top = shiftDirect(unitVals(sw.WG.Top), sw.p)
+	}
+	for i, v := range top {
+		sw.Top[i].Val = v
+	}
+	if !finite(sw.Top) {
+		degenerateUnits("shiftedWeighting", sw.Top, func() string { return fmt.Sprintf("shift probabilities: %v", sw.p) })
+	}
+	return &sw
+}
+
+// mod returns the non-negative remainder of a divided by n.
+func mod(a, n int) int {
+	return (a%n + n) % n
+}
+
 func (sw *shiftedWeighting) Backward() {
+	if sw.Logits != nil {
+		sw.backwardLogits()
+		return
+	}
+	// Away from integer shifts, Top[i] = WG[imj]*simj + WG[imj+1]*(1-simj) with simj = 1 - (Z - floor(Z)),
+	// so dTop[i]/dZ = WG[imj+1] - WG[imj], and dZ/dS = 2 * SigmoidGrad(S) * MaxShift since the modulo only offsets Z.
 	var grad float64 = 0
 	n := len(sw.WG.Top)
 	for i := 0; i < len(sw.Top); i++ {
@@ -191,8 +383,7 @@ func (sw *shiftedWeighting) Backward() {
 		grad += (-sw.WG.Top[imj].Val + sw.WG.Top[(imj+1)%n].Val) * sw.Top[i].Grad
 	}
 	sig := Sigmoid(sw.S.Val)
-	grad = grad * 2 * sig * (1 - sig)
-	// grad = grad * sw.Z * (1 - sw.Z/float64(n))
+	grad = grad * 2 * sig * (1 - sig) * sw.MaxShift
 	sw.S.Grad += grad
 
 	simj := 1 - (sw.Z - math.Floor(sw.Z))
@@ -202,39 +393,88 @@ func (sw *shiftedWeighting) Backward() {
 	}
 }
 
-type refocus struct {
-	Gamma *Unit
-	SW    *shiftedWeighting
-	Top   []Unit
+func (sw *shiftedWeighting) backwardLogits() {
+	n := len(sw.Top)
+	r := (len(sw.Logits) - 1) / 2
+	pGrad := make([]float64, len(sw.p))
+	if useFFT(n, len(sw.p)) {
+		// The gradients of the weights and of the shift kernel are the circular cross-correlations of the output gradients
+		// with the kernel and with the weights respectively.
+		grad := unitGrads(sw.Top)
+		wGrad := circularConvolve(grad, reversed(shiftKernel(sw.p, n)))
+		for j, g := range wGrad {
+			sw.WG.Top[j].Grad += g
+		}
+		sGrad := circularConvolve(grad, reversed(unitVals(sw.WG.Top)))
+		for k := range pGrad {
+			pGrad[k] = sGrad[mod(k-r, n)]
+		}
+	} else {
+		for k, p := range sw.p {
+			for i := 0; i < n; i++ {
+				j := mod(i-(k-r), n)
+				pGrad[k] += sw.Top[i].Grad * sw.WG.Top[j].Val
+				sw.WG.Top[j].Grad += sw.Top[i].Grad * p
+			}
+		}
+	}
+	var pGradMean float64 = 0
+	for k, p := range sw.p {
+		pGradMean += p * pGrad[k]
+	}
+	for k, p := range sw.p {
+		sw.Logits[k].Grad += p * (pGrad[k] - pGradMean)
+	}
+}
 
-	g float64
+type refocus struct {
+	Gamma   *Unit
+	SW      *shiftedWeighting
+	Top     []Unit
+	Epsilon float64 // shifted weights smaller than Epsilon are treated as zero in Backward
+	Max     float64 // the upper clamp of the sharpening exponent Softplus(Gamma)+1, zero if unclamped
+
+	g       float64
+	clamped bool
 }
 
-func newRefocus(gamma *Unit, sw *shiftedWeighting) *refocus {
+// newRefocus returns the sharpening of the shifted weights sw by the exponent Softplus(gamma)+1, clamped to max.
+// If compensated is true, the powers are summed with Kahan summation.
+func newRefocus(gamma *Unit, sw *shiftedWeighting, epsilon, max float64, compensated bool) *refocus {
 	rf := refocus{
-		Gamma: gamma,
-		SW:    sw,
-		Top:   make([]Unit, len(sw.Top)),
-		g:     math.Log(math.Exp(gamma.Val)+1) + 1,
+		Gamma:   gamma,
+		SW:      sw,
+		Top:     make([]Unit, len(sw.Top)),
+		Epsilon: epsilon,
+		Max:     max,
 	}
+	rf.g, rf.clamped = clamp(Softplus(gamma.Val)+1, max)
 	var sum float64 = 0
+	var ksum kahanSum
 	for i := 0; i < len(rf.Top); i++ {
 		rf.Top[i].Val = math.Pow(sw.Top[i].Val, rf.g)
-		sum += rf.Top[i].Val
+		if compensated {
+			ksum.add(rf.Top[i].Val)
+		} else {
+			sum += rf.Top[i].Val
+		}
+	}
+	if compensated {
+		sum = ksum.value()
 	}
 	for i := 0; i < len(rf.Top); i++ {
 		rf.Top[i].Val = rf.Top[i].Val / sum
-		if math.IsNaN(rf.Top[i].Val) {
-			log.Printf("g: %f, sw: %+v", rf.g, sw.Top)
-			panic(fmt.Sprintf("rf: %f, sum: %f", rf.Top[i].Val, sum))
-		}
+	}
+	// The weights are 0/0 if every shifted weight is zero, or if their powers underflow.
+	if !finite(rf.Top) {
+		degenerateUnits("refocus", rf.Top, func() string { return fmt.Sprintf("g: %f, sw: %+v", rf.g, sw.Top) })
 	}
 	return &rf
 }
 
 func (rf *refocus) Backward() {
 	for i, sw := range rf.SW.Top {
-		if sw.Val < machineEpsilon {
+		if sw.Val < rf.Epsilon {
 			continue
 		}
 		var grad float64 = 0
@@ -249,11 +489,14 @@ func (rf *refocus) Backward() {
 		rf.SW.Top[i].Grad += grad
 	}
 
+	if rf.clamped {
+		return
+	}
 	lns := make([]float64, len(rf.SW.Top))
 	var lnexp float64 = 0
 	var s float64 = 0
 	for i, sw := range rf.SW.Top {
-		if sw.Val < machineEpsilon {
+		if sw.Val < rf.Epsilon {
 			continue
 		}
 		lns[i] = math.Log(sw.Val)
@@ -264,7 +507,7 @@ func (rf *refocus) Backward() {
 	lnexps := lnexp / s
 	var grad float64 = 0
 	for i, top := range rf.Top {
-		if rf.SW.Top[i].Val < machineEpsilon {
+		if rf.SW.Top[i].Val < rf.Epsilon {
 			continue
 		}
 		grad += top.Grad * (top.Val * (lns[i] - lnexps))
@@ -273,69 +516,111 @@ func (rf *refocus) Backward() {
 	rf.Gamma.Grad += grad
 }
 
+// A memRead reads the memory with the weightings of a head, concatenating the read vectors.
+// Reading with k weightings yields k*m units, where m is the size of a memory row.
 type memRead struct {
-	W      *refocus
+	Ws     []*refocus
 	Memory *writtenMemory
 	Top    []Unit
 }
 
+// newMemRead returns the read of memory with the single weighting w.
 func newMemRead(w *refocus, memory *writtenMemory) *memRead {
+	return newMemReadMulti([]*refocus{w}, memory)
+}
+
+// newMemReadMulti returns the read of memory with the weightings ws, whose Top is the concatenation of the reads of every weighting.
+func newMemReadMulti(ws []*refocus, memory *writtenMemory) *memRead {
+	m := len(memory.Top[0])
 	r := memRead{
-		W:      w,
+		Ws:     ws,
 		Memory: memory,
-		Top:    make([]Unit, len(memory.Top[0])),
-	}
-	for i := 0; i < len(r.Top); i++ {
-		var v float64 = 0
-		for j := 0; j < len(w.Top); j++ {
-			v += w.Top[j].Val * memory.Top[j][i].Val
-			if math.IsNaN(v) {
-				panic(fmt.Sprintf("w: %f, mem: %f", w.Top[j].Val, memory.Top[j][i].Val))
+		Top:    make([]Unit, len(ws)*m),
+	}
+	for k, w := range ws {
+		top := r.Top[k*m : (k+1)*m]
+		for i := 0; i < len(top); i++ {
+			var v float64 = 0
+			for j := 0; j < len(w.Top); j++ {
+				v += w.Top[j].Val * memory.Top[j][i].Val
+				if math.IsNaN(v) && errorPolicy == PanicOnDegenerate {
+					panic(fmt.Sprintf("w: %f, mem: %f", w.Top[j].Val, memory.Top[j][i].Val))
+				}
 			}
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				v = degenerate("memRead", v, func() {}, func() string { return fmt.Sprintf("w: %+v", w.Top) })
+			}
+			top[i].Val = v
 		}
-		r.Top[i].Val = v
 	}
 	return &r
 }
 
+// Backward accumulates the gradients of the reads of every weighting into the weightings and the memory.
 func (r *memRead) Backward() {
-	for i := 0; i < len(r.W.Top); i++ {
-		var grad float64 = 0
-		for j := 0; j < len(r.Top); j++ {
-			grad += r.Top[j].Grad * r.Memory.Top[i][j].Val
+	m := len(r.Memory.Top[0])
+	for k, w := range r.Ws {
+		top := r.Top[k*m : (k+1)*m]
+		for i := 0; i < len(w.Top); i++ {
+			var grad float64 = 0
+			for j := 0; j < len(top); j++ {
+				grad += top[j].Grad * r.Memory.Top[i][j].Val
+			}
+			w.Top[i].Grad += grad
+		}
+
+		for i := 0; i < len(r.Memory.Top); i++ {
+			for j := 0; j < len(r.Memory.Top[i]); j++ {
+				r.Memory.Top[i][j].Grad += top[j].Grad * w.Top[i].Val
+			}
 		}
-		r.W.Top[i].Grad += grad
 	}
+}
 
-	for i := 0; i < len(r.Memory.Top); i++ {
-		for j := 0; j < len(r.Memory.Top[i]); j++ {
-			r.Memory.Top[i][j].Grad += r.Top[j].Grad * r.W.Top[i].Val
+// TopKLocations returns the k memory locations with the largest read weights, in descending order of the weights.
+// For a read with several weightings, the weights of a location are summed over the weightings.
+func (r *memRead) TopKLocations(k int) []int {
+	return topK(sumWeights(r.Ws), k)
+}
+
+// sumWeights returns the sum of the weightings ws on every memory location.
+func sumWeights(ws []*refocus) []float64 {
+	sum := make([]float64, len(ws[0].Top))
+	for _, w := range ws {
+		for i, u := range w.Top {
+			sum[i] += u.Val
 		}
 	}
+	return sum
 }
 
 type writtenMemory struct {
 	Ws    []*refocus
 	Heads []*Head        // We actually need only the erase and add vectors.
 	Mtm1  *writtenMemory // memory at time t-1
-	Top   [][]Unit
+	Top   [][]Unit       // a row major view over data
 
+	data     []Unit // flattened backing array of Top, nil if Top was allocated row by row
 	erase    [][]float64
 	add      [][]float64
 	erasures [][]float64
+	gates    []float64   // the write gates of each head, 1 for heads that always write
+	w        [][]float64 // the write weights of each head, which are Ws scaled by gates
 }
 
-func newWrittenMemory(ws []*refocus, heads []*Head, mtm1 *writtenMemory) *writtenMemory {
+func newWrittenMemory(ws []*refocus, heads []*Head, mtm1 *writtenMemory, tp *tape) *writtenMemory {
 	wm := writtenMemory{
 		Ws:    ws,
 		Heads: heads,
 		Mtm1:  mtm1,
-		Top:   makeTensorUnit2(len(mtm1.Top), len(mtm1.Top[0])),
 
-		erase:    MakeTensor2(len(heads), len(mtm1.Top[0])),
-		add:      MakeTensor2(len(heads), len(mtm1.Top[0])),
-		erasures: MakeTensor2(len(mtm1.Top), len(mtm1.Top[0])),
+		erase:    tp.tensor2(len(heads), len(mtm1.Top[0])),
+		add:      tp.tensor2(len(heads), len(mtm1.Top[0])),
+		erasures: tp.tensor2(len(mtm1.Top), len(mtm1.Top[0])),
+		gates:    tp.floats1(len(heads)),
+		w:        tp.tensor2(len(heads), len(mtm1.Top)),
 	}
+	wm.data, wm.Top = tp.flatTensorUnit2(len(mtm1.Top), len(mtm1.Top[0]))
 	for i, h := range wm.Heads {
 		erase := wm.erase[i]
 		add := wm.add[i]
@@ -343,7 +628,18 @@ func newWrittenMemory(ws []*refocus, heads []*Head, mtm1 *writtenMemory) *writte
 		addVec := h.AddVector()
 		for j, e := range eraseVec {
 			erase[j] = Sigmoid(e.Val)
-			add[j] = Sigmoid(addVec[j].Val)
+			add[j] = h.cfg.addActivation.activate(addVec[j].Val)
+		}
+
+		wm.gates[i] = 1
+		if g := h.WriteGate(); g != nil {
+			wm.gates[i] = Sigmoid(g.Val)
+		}
+		for j, w := range wm.Ws[i].Top {
+			wm.w[i][j] = wm.gates[i] * w.Val
+		}
+		if h.cfg.readOnly {
+			wm.w[i][h.cfg.readOnlyRow] = 0
 		}
 	}
 
@@ -353,37 +649,89 @@ func newWrittenMemory(ws []*refocus, heads []*Head, mtm1 *writtenMemory) *writte
 		for j, mtm1 := range mtm1Row {
 			var e float64 = 1
 			var adds float64 = 0
-			for k, weights := range wm.Ws {
-				e = e * (1 - weights.Top[i].Val*wm.erase[k][j])
-				adds += weights.Top[i].Val * wm.add[k][j]
+			for k, weights := range wm.w {
+				e = e * (1 - weights[i]*wm.erase[k][j])
+				adds += weights[i] * wm.add[k][j]
 			}
 			erasure[j] = e
 			topRow[j].Val += e*mtm1.Val + adds
+			if v := topRow[j].Val; math.IsNaN(v) || math.IsInf(v, 0) {
+				topRow[j].Val = degenerate("writtenMemory", v, func() {}, func() string {
+					return fmt.Sprintf("erase: %v, add: %v", wm.erase, wm.add)
+				})
+			}
 		}
 	}
 	return &wm
 }
 
+// contentHashPrecision is the number of decimal places to which memory values are rounded before being hashed.
+const contentHashPrecision = 1e9
+
+// ContentHash returns a FNV-1a hash of the memory content, rounded to contentHashPrecision.
+// Memories whose values differ only by rounding errors have the same hash.
+func (wm *writtenMemory) ContentHash() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, row := range wm.Top {
+		for _, u := range row {
+			v := math.Round(u.Val * contentHashPrecision)
+			if v == 0 {
+				v = 0 // normalize negative zero
+			}
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+			h.Write(buf[:])
+		}
+	}
+	return h.Sum64()
+}
+
+// Row returns a copy of the content of the memory at location i.
+// It panics if i is not a memory location.
+func (wm *writtenMemory) Row(i int) []float64 {
+	if i < 0 || i >= len(wm.Top) {
+		panic(fmt.Sprintf("ntm: memory location %d out of range [0, %d)", i, len(wm.Top)))
+	}
+	return unitVals(wm.Top[i])
+}
+
+// unit returns the memory unit at row i and column j.
+func (wm *writtenMemory) unit(i, j int) *Unit {
+	if wm.data == nil {
+		return &wm.Top[i][j]
+	}
+	return &wm.data[i*len(wm.Top[0])+j]
+}
+
 func (wm *writtenMemory) Backward() {
 	// Gradient of W
 	var grad float64 = 0
 	for i, weights := range wm.Ws {
 		erase := wm.erase[i]
 		add := wm.add[i]
+		var gateGrad float64 = 0
 		for j, topRow := range wm.Top {
+			if cfg := wm.Heads[i].cfg; cfg.readOnly && j == cfg.readOnlyRow {
+				// The head does not write to a read-only location, whatever its weight.
+				continue
+			}
 			mtm1Row := wm.Mtm1.Top[j]
 			grad = 0
 			for k, top := range topRow {
 				mtilt := mtm1Row[k].Val
-				for q, ws := range wm.Ws {
+				for q, ws := range wm.w {
 					if q == i {
 						continue
 					}
-					mtilt = mtilt * (1 - ws.Top[j].Val*wm.erase[q][k])
+					mtilt = mtilt * (1 - ws[j]*wm.erase[q][k])
 				}
 				grad += (mtilt*(-erase[k]) + add[k]) * top.Grad
 			}
-			weights.Top[j].Grad += grad
+			weights.Top[j].Grad += grad * wm.gates[i]
+			gateGrad += grad * weights.Top[j].Val
+		}
+		if g := wm.Heads[i].WriteGate(); g != nil {
+			g.Grad += gateGrad * SigmoidGrad(g.Val)
 		}
 	}
 
@@ -391,20 +739,20 @@ func (wm *writtenMemory) Backward() {
 	for k, h := range wm.Heads {
 		hErase := h.EraseVector()
 		erase := wm.erase[k]
-		ws := wm.Ws[k]
+		ws := wm.w[k]
 		for i := range hErase {
 			grad = 0
 			for j, topRow := range wm.Top {
 				gErase := wm.Mtm1.Top[j][i].Val
-				for q := range wm.Ws {
+				for q := range wm.w {
 					if q == k {
 						continue
 					}
-					gErase = gErase * (1 - wm.Ws[q].Top[j].Val*wm.erase[q][i])
+					gErase = gErase * (1 - wm.w[q][j]*wm.erase[q][i])
 				}
 				// Contrary to the rules of math, the order in which these 3 numbers multiply matters...
 				// For example, in the copy task the rate of convergence for rand.Seed(8) differs a lot if an alternative ordering is used.
-				grad += topRow[i].Grad * gErase * (-ws.Top[j].Val)
+				grad += topRow[i].Grad * gErase * (-ws[j])
 			}
 			e := erase[i]
 			hErase[i].Grad += grad * e * (1 - e)
@@ -414,15 +762,14 @@ func (wm *writtenMemory) Backward() {
 	// Gradient of Add vector
 	for k, h := range wm.Heads {
 		add := wm.add[k]
-		ws := wm.Ws[k]
+		ws := wm.w[k]
 		hAdd := h.AddVector()
 		for i := range hAdd {
 			grad = 0
 			for j, toprow := range wm.Top {
-				grad += toprow[i].Grad * ws.Top[j].Val
+				grad += toprow[i].Grad * ws[j]
 			}
-			a := add[i]
-			hAdd[i].Grad += grad * a * (1 - a)
+			hAdd[i].Grad += h.cfg.addActivation.backward(grad, add[i])
 		}
 	}
 
@@ -431,8 +778,8 @@ func (wm *writtenMemory) Backward() {
 		toprow := wm.Top[i]
 		for j, top := range toprow {
 			grad = 1
-			for q, ws := range wm.Ws {
-				grad = grad * (1 - ws.Top[i].Val*wm.erase[q][j])
+			for q, ws := range wm.w {
+				grad = grad * (1 - ws[i]*wm.erase[q][j])
 			}
 			mtm1row[j].Grad += grad * top.Grad
 		}
@@ -441,46 +788,216 @@ func (wm *writtenMemory) Backward() {
 
 type memOp struct {
 	W  []*refocus
+	RW [][]*refocus // the additional read weightings of every head, see WithReadWeightings
 	R  []*memRead
 	WM *writtenMemory
+	A  []Addressing   // the addressing of the memory by every head, which computes W
+	RA [][]Addressing // the addressing computing RW
+}
+
+// An Addressing is the circuit computing the weights with which a memory head addresses the memory at a time instant.
+type Addressing interface {
+	// Weights returns the addressing weights, one for each memory location.
+	// The gradients of the weights are accumulated by the memory reads and writes before Backward is called.
+	Weights() []Unit
+	// Backward propagates the gradients of the weights to the units of the head, the weights of the head at the previous time instant, and the memory.
+	Backward()
+}
+
+// An Addresser computes the weights with which memory heads address the memory.
+// It allows replacing the addressing scheme of a NTM, see WithAddresser.
+type Addresser interface {
+	// Address returns the addressing of memory by the head h, whose index among the heads of its controller is head.
+	// memory is the memory at the previous time instant, and h.Wtm1 holds the weights of h at the previous time instant.
+	Address(h *Head, head int, memory [][]Unit) Addressing
 }
 
-func newMemOp(heads []*Head, mtm1 *writtenMemory) *memOp {
+// DefaultAddresser is the addressing scheme of the NTM paper, which is used when no other Addresser is given.
+// A head first addresses the memory by content with the key K and key strength Beta.
+// Unless the head is ContentOnly, the content weights are then interpolated with the previous weights by the gate G,
+// shifted by S, and sharpened by Gamma.
+type DefaultAddresser struct{}
+
+// defaultAddressing is the Addressing of DefaultAddresser.
+type defaultAddressing struct {
+	WC *contentAddressing
+	W  *refocus
+}
+
+// Address implements Addresser.
+func (DefaultAddresser) Address(h *Head, head int, memory [][]Unit) Addressing {
+	check := h.cfg.nanCheck
+	k := h.K()
+	var nk *normalizedKey
+	if h.cfg.normalizeKey {
+		nk = newNormalizedKey(k)
+		k = nk.Top
+	}
+	ss := make([]*betaSimilarity, len(memory))
+	for i := 0; i < len(memory); i++ {
+		key := memory[i][:len(k)]
+		var s *similarityCircuit
+		if h.cfg.similarity == DotProductSimilarity {
+			s = newDotSimilarity(k, key)
+		} else {
+			s = newSimilarityCircuit(k, key)
+		}
+		ss[i] = newBetaSimilarity(h.Beta(), s, h.cfg.maxBeta)
+		if check {
+			checkUnits("betaSimilarity", head, []Unit{ss[i].Top}, true, func() string {
+				return fmt.Sprintf("beta: %f, similarity: %f", h.Beta().Val, s.Top.Val)
+			})
+		}
+	}
+	wc := newContentAddressing(ss, h.cfg.compensatedSums)
+	wc.Key = nk
+	if check {
+		checkUnits("contentAddressing", head, wc.Top, true, func() string {
+			sims := make([]float64, len(ss))
+			for i, s := range ss {
+				sims[i] = s.Top.Val
+			}
+			return fmt.Sprintf("beta similarities: %v", sims)
+		})
+	}
+	if h.cfg.mode == ContentOnly {
+		// Share the units of the content addressing weights, so that gradients flow directly into them.
+		return &defaultAddressing{WC: wc, W: &refocus{Top: wc.Top}}
+	}
+
+	wg := newGatedWeighting(h.G(), wc, h.Wtm1)
+	if check {
+		checkUnits("gatedWeighting", head, wg.Top, true, func() string {
+			return fmt.Sprintf("g: %f, wtm1: %+v", h.G().Val, h.Wtm1.Top)
+		})
+	}
+	var ws *shiftedWeighting
+	if h.cfg.shiftLogits {
+		ws = newLogitShiftedWeighting(h.ShiftLogits(), wg)
+	} else {
+		ws = newShiftedWeighting(h.S(), h.cfg.shiftRange(), wg)
+	}
+	if check {
+		checkUnits("shiftedWeighting", head, ws.Top, true, func() string { return fmt.Sprintf("shift: %f", ws.Z) })
+	}
+	rf := newRefocus(h.Gamma(), ws, h.cfg.refocusEpsilon(), h.cfg.maxGamma, h.cfg.compensatedSums)
+	checkUnits("refocus", head, rf.Top, check, func() string { return fmt.Sprintf("g: %f, sw: %+v", rf.g, ws.Top) })
+	return &defaultAddressing{WC: wc, W: rf}
+}
+
+func (a *defaultAddressing) Weights() []Unit {
+	return a.W.Top
+}
+
+func (a *defaultAddressing) Backward() {
+	if a.W.SW != nil {
+		a.W.Backward()
+		a.W.SW.Backward()
+		a.W.SW.WG.Backward()
+	}
+	a.WC.Backward()
+	for _, bs := range a.WC.Units {
+		bs.Backward()
+		bs.S.Backward()
+	}
+	if a.WC.Key != nil {
+		a.WC.Key.Backward()
+	}
+}
+
+// newMemOp returns the memory operations of heads on the memory mtm1, allocating the buffers of the written memory from tp, which may be nil.
+func newMemOp(heads []*Head, mtm1 *writtenMemory, tp *tape) *memOp {
 	circuit := memOp{
+		W: make([]*refocus, len(heads)),
 		R: make([]*memRead, len(heads)),
+		A: make([]Addressing, len(heads)),
 	}
-	circuit.W = make([]*refocus, len(heads))
+	checkInf := false
 	for wi, h := range heads {
-		ss := make([]*betaSimilarity, len(mtm1.Top))
-		for i := 0; i < len(mtm1.Top); i++ {
-			s := newSimilarityCircuit(h.K(), mtm1.Top[i])
-			ss[i] = newBetaSimilarity(h.Beta(), s)
+		check := h.cfg.nanCheck
+		checkInf = checkInf || check
+		circuit.A[wi], circuit.W[wi] = address(h, wi, mtm1)
+		ws := circuit.W[wi : wi+1 : wi+1]
+		if len(h.reads) > 0 {
+			if circuit.RW == nil {
+				circuit.RW = make([][]*refocus, len(heads))
+				circuit.RA = make([][]Addressing, len(heads))
+			}
+			circuit.RW[wi] = make([]*refocus, len(h.reads))
+			circuit.RA[wi] = make([]Addressing, len(h.reads))
+			for j, rh := range h.reads {
+				circuit.RA[wi][j], circuit.RW[wi][j] = address(rh, wi, mtm1)
+			}
+			ws = append([]*refocus{circuit.W[wi]}, circuit.RW[wi]...)
+		}
+		circuit.R[wi] = newMemReadMulti(ws, mtm1)
+		if check {
+			checkUnits("memRead", wi, circuit.R[wi].Top, true, func() string { return fmt.Sprintf("w: %+v", circuit.W[wi].Top) })
 		}
-		wc := newContentAddressing(ss)
-		wg := newGatedWeighting(h.G(), wc, h.Wtm1)
-		ws := newShiftedWeighting(h.S(), wg)
-		circuit.W[wi] = newRefocus(h.Gamma(), ws)
-		circuit.R[wi] = newMemRead(circuit.W[wi], mtm1)
 	}
 
-	circuit.WM = newWrittenMemory(circuit.W, heads, mtm1)
+	circuit.WM = newWrittenMemory(circuit.W, heads, mtm1, tp)
+	if checkInf {
+		for _, row := range circuit.WM.Top {
+			checkUnits("writtenMemory", -1, row, true, func() string { return fmt.Sprintf("erase: %v, add: %v", circuit.WM.erase, circuit.WM.add) })
+		}
+	}
 	return &circuit
 }
 
+// address returns the addressing of the memory mtm1 by the head h, whose index among the heads of its controller is head,
+// together with the weights it computes.
+func address(h *Head, head int, mtm1 *writtenMemory) (Addressing, *refocus) {
+	addr := h.cfg.addresser
+	if addr == nil {
+		addr = DefaultAddresser{}
+	}
+	a := addr.Address(h, head, mtm1.Top)
+	if da, ok := a.(*defaultAddressing); ok {
+		return a, da.W
+	}
+	w := &refocus{Top: a.Weights()}
+	if h.cfg.nanCheck {
+		checkUnits("addressing", head, w.Top, true, func() string { return fmt.Sprintf("%T", a) })
+	}
+	return a, w
+}
+
+// A NaNError reports that a component of the memory operations of a NTM produced a NaN or infinite value.
+type NaNError struct {
+	Component string  // the name of the component, such as "refocus" or "writtenMemory"
+	Head      int     // the index of the memory head, or -1 for components shared by all heads
+	Unit      int     // the index of the offending output unit of the component
+	Val       float64 // the offending value
+	Inputs    string  // a description of the inputs of the component
+}
+
+func (e *NaNError) Error() string {
+	return fmt.Sprintf("ntm: %v in %s of head %d at unit %d, inputs: %s", e.Val, e.Component, e.Head, e.Unit, e.Inputs)
+}
+
+// checkUnits panics with a *NaNError if a unit of a component operating for a head is NaN, or infinite when checkInf is true.
+// inputs is only called on failure.
+func checkUnits(component string, head int, units []Unit, checkInf bool, inputs func() string) {
+	for i, u := range units {
+		if math.IsNaN(u.Val) || checkInf && math.IsInf(u.Val, 0) {
+			panic(&NaNError{Component: component, Head: head, Unit: i, Val: u.Val, Inputs: inputs()})
+		}
+	}
+}
+
 func (c *memOp) Backward() {
 	for _, r := range c.R {
 		r.Backward()
 	}
 	c.WM.Backward()
 
-	for _, rf := range c.WM.Ws {
-		rf.Backward()
-		rf.SW.Backward()
-		rf.SW.WG.Backward()
-		rf.SW.WG.WC.Backward()
-		for _, bs := range rf.SW.WG.WC.Units {
-			bs.Backward()
-			bs.S.Backward()
+	for _, a := range c.A {
+		a.Backward()
+	}
+	for _, ras := range c.RA {
+		for _, a := range ras {
+			a.Backward()
 		}
 	}
 }
@@ -1,9 +1,12 @@
 package ntm
 
 import (
+	"fmt"
 	"math"
 	"math/rand"
 	"testing"
+
+	"github.com/fumin/ntm/copytask"
 )
 
 const (
@@ -17,6 +20,14 @@ const (
 )
 
 func TestCircuit(t *testing.T) {
+	testCircuit(t, headConfig{})
+}
+
+func TestCircuitWriteGate(t *testing.T) {
+	testCircuit(t, headConfig{writeGate: true})
+}
+
+func testCircuit(t *testing.T, cfg headConfig) {
 	n := 3
 	m := 2
 	memory := &writtenMemory{Top: makeTensorUnit2(n, m)}
@@ -27,7 +38,7 @@ func TestCircuit(t *testing.T) {
 	}
 	heads := make([]*Head, 2)
 	for i := 0; i < len(heads); i++ {
-		heads[i] = NewHead(m)
+		heads[i] = newHead(m, cfg)
 		heads[i].Wtm1 = randomRefocus(n)
 		for j := 0; j < len(heads[i].units); j++ {
 			heads[i].units[j].Val = rand.Float64()
@@ -35,9 +46,11 @@ func TestCircuit(t *testing.T) {
 	}
 	// We want to check the case where Beta > 0 and Gamma > 1.
 	heads[0].Beta().Val = 0.137350
-	heads[0].Gamma().Val = 1.9876
+	if cfg.mode != ContentOnly {
+		heads[0].Gamma().Val = 1.9876
+	}
 
-	circuit := newMemOp(heads, memory)
+	circuit := newMemOp(heads, memory, nil)
 	for i := 0; i < len(circuit.W); i++ {
 		for j := 0; j < len(circuit.W[i].Top); j++ {
 			if i == 0 && j == 0 {
@@ -60,13 +73,22 @@ func TestCircuit(t *testing.T) {
 	circuit.Backward()
 
 	ax := addressing(heads, memory.Top)
-	checkGamma(t, heads, memory.Top, ax)
-	checkS(t, heads, memory.Top, ax)
-	checkG(t, heads, memory.Top, ax)
+	if cfg.mode != ContentOnly {
+		checkGamma(t, heads, memory.Top, ax)
+		if cfg.shiftLogits {
+			checkShiftLogits(t, heads, memory.Top, ax)
+		} else {
+			checkS(t, heads, memory.Top, ax)
+		}
+		checkG(t, heads, memory.Top, ax)
+	}
 	checkWtm1(t, heads, memory.Top, ax)
 	checkBeta(t, heads, memory.Top, ax)
 	checkK(t, heads, memory.Top, ax)
 	checkMemory(t, heads, memory.Top, ax)
+	if cfg.writeGate {
+		checkWriteGate(t, heads, memory.Top, ax)
+	}
 }
 
 func addressing(heads []*Head, memory [][]Unit) float64 {
@@ -80,13 +102,29 @@ func doAddressing(heads []*Head, memory [][]Unit) (weights [][]float64, reads []
 		beta := math.Exp(h.Beta().Val)
 		wc := make([]float64, len(memory))
 		var sum float64 = 0
+		k := unitVals(h.K())
+		if h.cfg.normalizeKey {
+			norm := math.Sqrt(dotProduct(k, k))
+			for j := range k {
+				k[j] /= norm + MachineEpsilon
+			}
+		}
 		for j := 0; j < len(wc); j++ {
-			wc[j] = math.Exp(beta * cosineSimilarity(unitVals(h.K()), unitVals(memory[j])))
+			key := unitVals(memory[j][:len(k)])
+			sim := cosineSimilarity(k, key)
+			if h.cfg.similarity == DotProductSimilarity {
+				sim = dotProduct(k, key)
+			}
+			wc[j] = math.Exp(beta * sim)
 			sum += wc[j]
 		}
 		for j := 0; j < len(wc); j++ {
 			wc[j] = wc[j] / sum
 		}
+		if h.cfg.mode == ContentOnly {
+			copy(weights[i], wc)
+			continue
+		}
 
 		// Content-based, location-based addressing gate
 		g := Sigmoid(h.G().Val)
@@ -100,11 +138,26 @@ func doAddressing(heads []*Head, memory [][]Unit) (weights [][]float64, reads []
 		//if s < 0 {
 		//	s += float64(n)
 		//}
-		s := math.Mod((2*Sigmoid(h.S().Val)-1)+float64(n), float64(n))
-		for j := 0; j < n; j++ {
-			imj := (j + int(s)) % n
-			simj := 1 - (s - math.Floor(s))
-			weights[i][j] = wc[imj]*simj + wc[(imj+1)%n]*(1-simj)
+		if logits := h.ShiftLogits(); logits != nil {
+			r := h.cfg.shiftRange()
+			p := make([]float64, len(logits))
+			sum = 0
+			for k := range logits {
+				p[k] = math.Exp(logits[k].Val)
+				sum += p[k]
+			}
+			for j := 0; j < n; j++ {
+				for k := range p {
+					weights[i][j] += p[k] / sum * wc[((j-(k-r))%n+n)%n]
+				}
+			}
+		} else {
+			s := math.Mod((2*Sigmoid(h.S().Val)-1)*float64(h.cfg.shiftRange())+float64(n), float64(n))
+			for j := 0; j < n; j++ {
+				imj := (j + int(s)) % n
+				simj := 1 - (s - math.Floor(s))
+				weights[i][j] = wc[imj]*simj + wc[(imj+1)%n]*(1-simj)
+			}
 		}
 
 		// Refocusing
@@ -129,6 +182,16 @@ func doAddressing(heads []*Head, memory [][]Unit) (weights [][]float64, reads []
 		}
 	}
 
+	writeWeights := MakeTensor2(len(heads), len(memory))
+	for k, w := range weights {
+		var gate float64 = 1
+		if g := heads[k].WriteGate(); g != nil {
+			gate = Sigmoid(g.Val)
+		}
+		for i := range w {
+			writeWeights[k][i] = gate * w[i]
+		}
+	}
 	erase := MakeTensor2(len(heads), len(memory[0]))
 	add := MakeTensor2(len(heads), len(memory[0]))
 	for k := 0; k < len(heads); k++ {
@@ -138,7 +201,14 @@ func doAddressing(heads []*Head, memory [][]Unit) (weights [][]float64, reads []
 		}
 		addVec := heads[k].AddVector()
 		for i := 0; i < len(add[k]); i++ {
-			add[k][i] = Sigmoid(addVec[i].Val)
+			switch heads[k].cfg.addActivation {
+			case TanhAdd:
+				add[k][i] = math.Tanh(addVec[i].Val)
+			case LinearAdd:
+				add[k][i] = addVec[i].Val
+			default:
+				add[k][i] = Sigmoid(addVec[i].Val)
+			}
 		}
 	}
 	newMem = MakeTensor2(len(memory), len(memory[0]))
@@ -146,10 +216,10 @@ func doAddressing(heads []*Head, memory [][]Unit) (weights [][]float64, reads []
 		for j := 0; j < len(newMem[i]); j++ {
 			newMem[i][j] = memory[i][j].Val
 			for k := 0; k < len(heads); k++ {
-				newMem[i][j] = newMem[i][j] * (1 - weights[k][i]*erase[k][j])
+				newMem[i][j] = newMem[i][j] * (1 - writeWeights[k][i]*erase[k][j])
 			}
 			for k := 0; k < len(heads); k++ {
-				newMem[i][j] += weights[k][i] * add[k][j]
+				newMem[i][j] += writeWeights[k][i] * add[k][j]
 			}
 		}
 	}
@@ -300,6 +370,46 @@ func checkS(t *testing.T, heads []*Head, memory [][]Unit, ax float64) {
 	}
 }
 
+func checkShiftLogits(t *testing.T, heads []*Head, memory [][]Unit, ax float64) {
+	for k, hd := range heads {
+		for i := range hd.ShiftLogits() {
+			x := hd.ShiftLogits()[i].Val
+			h := machineEpsilonSqrt * math.Max(math.Abs(x), 1)
+			xph := x + h
+			hd.ShiftLogits()[i].Val = xph
+			dx := xph - x
+			axph := addressing(heads, memory)
+			grad := (axph - ax) / dx
+			hd.ShiftLogits()[i].Val = x
+
+			if math.IsNaN(grad) || math.Abs(grad-hd.ShiftLogits()[i].Grad) > 1e-5 {
+				t.Fatalf("wrong shift logit[%d] gradient expected %f, got %f", i, grad, hd.ShiftLogits()[i].Grad)
+			} else {
+				t.Logf("OK shift logit[%d][%d] gradient %f %f", k, i, grad, hd.ShiftLogits()[i].Grad)
+			}
+		}
+	}
+}
+
+func checkWriteGate(t *testing.T, heads []*Head, memory [][]Unit, ax float64) {
+	for k, hd := range heads {
+		x := hd.WriteGate().Val
+		h := machineEpsilonSqrt * math.Max(math.Abs(x), 1)
+		xph := x + h
+		hd.WriteGate().Val = xph
+		dx := xph - x
+		axph := addressing(heads, memory)
+		grad := (axph - ax) / dx
+		hd.WriteGate().Val = x
+
+		if math.IsNaN(grad) || math.Abs(grad-hd.WriteGate().Grad) > 1e-5 {
+			t.Fatalf("wrong write gate gradient expected %f, got %f", grad, hd.WriteGate().Grad)
+		} else {
+			t.Logf("OK write gate[%d] gradient %f %f", k, grad, hd.WriteGate().Grad)
+		}
+	}
+}
+
 func checkGamma(t *testing.T, heads []*Head, memory [][]Unit, ax float64) {
 	for k, hd := range heads {
 		x := hd.Gamma().Val
@@ -331,3 +441,1006 @@ func randomRefocus(n int) *refocus {
 	}
 	return &refocus{Top: w}
 }
+
+func TestCircuitMaxShift(t *testing.T) {
+	testCircuit(t, headConfig{maxShift: 2})
+}
+
+func TestCircuitContentOnly(t *testing.T) {
+	testCircuit(t, headConfig{mode: ContentOnly})
+}
+
+func TestCircuitShiftLogits(t *testing.T) {
+	testCircuit(t, headConfig{shiftLogits: true})
+	testCircuit(t, headConfig{shiftLogits: true, maxShift: 2, writeGate: true})
+}
+
+func TestShiftLogitsUnits(t *testing.T) {
+	m := 3
+	for _, maxShift := range []int{0, 1, 2, 5} {
+		cfg := headConfig{shiftLogits: true, maxShift: maxShift, writeGate: true}
+		h := newHead(m, cfg)
+		if h.S() != nil {
+			t.Errorf("maxShift %d: head with shift logits has a scalar S", maxShift)
+		}
+		if len(h.ShiftLogits()) != 2*cfg.shiftRange()+1 {
+			t.Errorf("maxShift %d: %d shift logits, expected %d", maxShift, len(h.ShiftLogits()), 2*cfg.shiftRange()+1)
+		}
+		if len(h.units) != 3*m+3+len(h.ShiftLogits())+1 {
+			t.Errorf("maxShift %d: %d units", maxShift, len(h.units))
+		}
+		// Make sure the units of a head do not overlap.
+		h.ShiftLogits()[len(h.ShiftLogits())-1].Val = 1
+		if h.Gamma().Val != 0 || h.WriteGate().Val != 0 {
+			t.Errorf("maxShift %d: shift logits overlap with other units", maxShift)
+		}
+	}
+	if NewHead(m).ShiftLogits() != nil {
+		t.Errorf("default head has shift logits")
+	}
+
+	c := NewEmptyController1(2, 2, 3, 2, 5, m, WithShiftLogits(), WithMaxShift(2))
+	machines := ForwardBackward(c, [][]float64{{0, 1}}, [][]float64{{1, 0}})
+	for _, h := range machines[0].Controller.Heads() {
+		if len(h.ShiftLogits()) != 5 {
+			t.Errorf("%d shift logits, expected 5", len(h.ShiftLogits()))
+		}
+	}
+}
+
+func TestContentOnlyHead(t *testing.T) {
+	rnd := rand.New(rand.NewSource(11))
+	n := 4
+	m := 3
+	memory := &writtenMemory{Top: makeTensorUnit2(n, m)}
+	for i := range memory.Top {
+		for j := range memory.Top[i] {
+			memory.Top[i][j].Val = rnd.Float64()
+		}
+	}
+	heads := []*Head{newHead(m, headConfig{mode: ContentOnly}), NewHead(m)}
+	if len(heads[0].units) != 3*m+1 {
+		t.Fatalf("expected %d units for a content only head, got %d", 3*m+1, len(heads[0].units))
+	}
+	if heads[0].G() != nil || heads[0].S() != nil || heads[0].Gamma() != nil {
+		t.Fatalf("content only head has location addressing units")
+	}
+	for _, h := range heads {
+		h.Wtm1 = &refocus{Top: make([]Unit, n)}
+		for j := range h.Wtm1.Top {
+			h.Wtm1.Top[j].Val = 1 / float64(n)
+		}
+		for j := range h.units {
+			h.units[j].Val = rnd.Float64()
+		}
+	}
+
+	circuit := newMemOp(heads, memory, nil)
+	wc := circuit.A[0].(*defaultAddressing).WC
+	for j, w := range circuit.W[0].Top {
+		if w.Val != wc.Top[j].Val {
+			t.Errorf("weight %d is %f, expected the content addressing weight %f", j, w.Val, wc.Top[j].Val)
+		}
+	}
+	for i := range circuit.R {
+		for j := range circuit.R[i].Top {
+			circuit.R[i].Top[j].Grad = outputGradient
+		}
+	}
+	circuit.Backward()
+	for j, w := range heads[0].Wtm1.Top {
+		if w.Grad != 0 {
+			t.Errorf("content only head received a gradient at its previous weights[%d]: %f", j, w.Grad)
+		}
+	}
+}
+
+func TestShiftedWeightingMaxShift(t *testing.T) {
+	n := 8
+	for _, s := range []float64{-3, -0.4, 0, 0.9, 3} {
+		wc := &contentAddressing{Top: make([]Unit, n)}
+		wc.Top[4].Val = 1
+		wtm1 := &refocus{Top: make([]Unit, n)}
+		g := &Unit{Val: 100} // choose content addressing
+		wg := newGatedWeighting(g, wc, wtm1)
+		sw := newShiftedWeighting(&Unit{Val: s}, 1, wg)
+		for i, w := range sw.Top {
+			if (i < 3 || i > 5) && w.Val != 0 {
+				t.Errorf("s: %f, weight at %d is %f, expected only locations 3, 4, 5 to be mixed", s, i, w.Val)
+			}
+		}
+	}
+}
+
+func TestMaxShiftValidation(t *testing.T) {
+	// panics reports whether f panics.
+	panics := func(f func()) (r bool) {
+		defer func() { r = recover() != nil }()
+		f()
+		return false
+	}
+	if !panics(func() { WithMaxShift(-1) }) {
+		t.Errorf("no panic for a negative maximum shift")
+	}
+	if !panics(func() { NewEmptyController1(2, 2, 3, 1, 4, 2, WithMaxShift(4)) }) {
+		t.Errorf("no panic for a maximum shift spanning the memory")
+	}
+	if panics(func() { NewEmptyController1(2, 2, 3, 1, 4, 2, WithMaxShift(3)) }) {
+		t.Errorf("panic for a maximum shift within the memory")
+	}
+}
+
+func TestShiftedWeightingGradients(t *testing.T) {
+	n := 5
+	rnd := rand.New(rand.NewSource(23))
+	wcVals := make([]float64, n)
+	topGrads := make([]float64, n)
+	for i := range wcVals {
+		wcVals[i] = rnd.Float64()
+		topGrads[i] = rnd.Float64() - 0.5
+	}
+	newSW := func(s float64, maxShift int, wcv []float64) (*shiftedWeighting, *contentAddressing) {
+		wc := &contentAddressing{Top: make([]Unit, n)}
+		for i, v := range wcv {
+			wc.Top[i].Val = v
+		}
+		wtm1 := &refocus{Top: make([]Unit, n)}
+		g := &Unit{Val: 100} // choose content addressing
+		return newShiftedWeighting(&Unit{Val: s}, maxShift, newGatedWeighting(g, wc, wtm1)), wc
+	}
+	loss := func(s float64, maxShift int, wcv []float64) float64 {
+		sw, _ := newSW(s, maxShift, wcv)
+		var l float64 = 0
+		for i, w := range sw.Top {
+			l += topGrads[i] * w.Val
+		}
+		return l
+	}
+
+	// The shifts are chosen such that Z is fractional.
+	for _, tc := range []struct {
+		s        float64
+		maxShift int
+	}{{-1.3, 1}, {-0.2, 1}, {0.7, 1}, {-0.9, 2}, {0.35, 2}, {1.2, 3}} {
+		sw, wc := newSW(tc.s, tc.maxShift, wcVals)
+		if frac := sw.Z - math.Floor(sw.Z); frac < 0.01 || frac > 0.99 {
+			t.Fatalf("s: %f, Z %f is too close to an integer", tc.s, sw.Z)
+		}
+		for i := range sw.Top {
+			sw.Top[i].Grad = topGrads[i]
+		}
+		sw.Backward()
+		sw.WG.Backward()
+
+		if g := (loss(tc.s+1e-6, tc.maxShift, wcVals) - loss(tc.s-1e-6, tc.maxShift, wcVals)) / 2e-6; math.Abs(g-sw.S.Grad) > 1e-6 {
+			t.Errorf("s: %f, max shift %d, S gradient %f, expected %f", tc.s, tc.maxShift, sw.S.Grad, g)
+		}
+		for i := range wcVals {
+			plus := append([]float64(nil), wcVals...)
+			minus := append([]float64(nil), wcVals...)
+			plus[i] += 1e-6
+			minus[i] -= 1e-6
+			g := (loss(tc.s, tc.maxShift, plus) - loss(tc.s, tc.maxShift, minus)) / 2e-6
+			if math.Abs(g-wc.Top[i].Grad) > 1e-6 {
+				t.Errorf("s: %f, max shift %d, weight %d gradient %f, expected %f", tc.s, tc.maxShift, i, wc.Top[i].Grad, g)
+			}
+		}
+	}
+}
+
+func TestSimilarityCircuitNearZero(t *testing.T) {
+	v := []Unit{{Val: 0.3}, {Val: -0.8}, {Val: 0.5}}
+	similarity := func(u []Unit) float64 {
+		return newSimilarityCircuit(u, v).Top.Val
+	}
+	for _, scale := range []float64{0, 1e-4} {
+		u := []Unit{{Val: 0.7 * scale}, {Val: 0.2 * scale}, {Val: -0.4 * scale}}
+		for i := range v {
+			v[i].Grad = 0
+		}
+		s := newSimilarityCircuit(u, v)
+		if math.IsNaN(s.Top.Val) || math.IsInf(s.Top.Val, 0) {
+			t.Fatalf("scale %g: similarity is not finite: %f", scale, s.Top.Val)
+		}
+		s.Top.Grad = outputGradient
+		s.Backward()
+
+		for i := range u {
+			if math.IsNaN(u[i].Grad) || math.IsInf(u[i].Grad, 0) || math.IsNaN(v[i].Grad) || math.IsInf(v[i].Grad, 0) {
+				t.Fatalf("scale %g: gradient is not finite: u %+v, v %+v", scale, u, v)
+			}
+		}
+		if scale == 0 {
+			continue
+		}
+		for i := range u {
+			x := u[i].Val
+			h := 1e-6 * scale
+			u[i].Val = x + h
+			sph := similarity(u)
+			u[i].Val = x - h
+			smh := similarity(u)
+			u[i].Val = x
+			grad := outputGradient * (sph - smh) / (2 * h)
+			if math.Abs(grad-u[i].Grad) > 1e-5*math.Abs(grad) {
+				t.Errorf("scale %g: wrong u[%d] gradient expected %g, got %g", scale, i, grad, u[i].Grad)
+			}
+		}
+	}
+}
+
+func TestWrittenMemoryFlatLayout(t *testing.T) {
+	// Use a private source so as not to perturb the random inputs of other tests.
+	rnd := rand.New(rand.NewSource(5))
+	n := 5
+	m := 3
+	rowMem := &writtenMemory{Top: makeTensorUnit2(n, m)}
+	flatMem := &writtenMemory{}
+	flatMem.data, flatMem.Top = makeFlatTensorUnit2(n, m)
+	for i := range rowMem.Top {
+		for j := range rowMem.Top[i] {
+			v := rnd.Float64()
+			rowMem.Top[i][j].Val = v
+			flatMem.unit(i, j).Val = v
+		}
+	}
+	rowHeads := make([]*Head, 2)
+	flatHeads := make([]*Head, len(rowHeads))
+	for i := range rowHeads {
+		rowHeads[i] = NewHead(m)
+		flatHeads[i] = NewHead(m)
+		rowHeads[i].Wtm1 = &refocus{Top: make([]Unit, n)}
+		flatHeads[i].Wtm1 = &refocus{Top: make([]Unit, n)}
+		for j := range rowHeads[i].Wtm1.Top {
+			v := 1 / float64(n)
+			rowHeads[i].Wtm1.Top[j].Val = v
+			flatHeads[i].Wtm1.Top[j].Val = v
+		}
+		for j := range rowHeads[i].units {
+			v := rnd.Float64()
+			rowHeads[i].units[j].Val = v
+			flatHeads[i].units[j].Val = v
+		}
+	}
+
+	rowOp := newMemOp(rowHeads, rowMem, nil)
+	flatOp := newMemOp(flatHeads, flatMem, nil)
+	for _, op := range []*memOp{rowOp, flatOp} {
+		for i := range op.WM.Top {
+			for j := range op.WM.Top[i] {
+				op.WM.Top[i][j].Grad = outputGradient
+			}
+		}
+		op.Backward()
+	}
+
+	for i := range rowMem.Top {
+		for j := range rowMem.Top[i] {
+			if rowOp.WM.Top[i][j].Val != flatOp.WM.unit(i, j).Val {
+				t.Fatalf("memory[%d][%d] value differs: %v != %v", i, j, rowOp.WM.Top[i][j].Val, flatOp.WM.unit(i, j).Val)
+			}
+			if rowMem.Top[i][j].Grad != flatMem.unit(i, j).Grad {
+				t.Fatalf("memory[%d][%d] gradient differs: %v != %v", i, j, rowMem.Top[i][j].Grad, flatMem.unit(i, j).Grad)
+			}
+		}
+	}
+	for k := range rowHeads {
+		for j := range rowHeads[k].units {
+			if rowHeads[k].units[j].Grad != flatHeads[k].units[j].Grad {
+				t.Fatalf("head[%d] unit[%d] gradient differs: %v != %v", k, j, rowHeads[k].units[j].Grad, flatHeads[k].units[j].Grad)
+			}
+		}
+	}
+}
+
+func BenchmarkWrittenMemoryBackward(b *testing.B) {
+	n := 128
+	m := 20
+	memory := &writtenMemory{}
+	memory.data, memory.Top = makeFlatTensorUnit2(n, m)
+	for i := range memory.data {
+		memory.data[i].Val = rand.Float64()
+	}
+	heads := []*Head{NewHead(m)}
+	heads[0].Wtm1 = randomRefocus(n)
+	for j := range heads[0].units {
+		heads[0].units[j].Val = rand.Float64()
+	}
+	op := newMemOp(heads, memory, nil)
+	for i := range op.WM.data {
+		op.WM.data[i].Grad = outputGradient
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		op.WM.Backward()
+	}
+}
+
+func TestRefocusEpsilon(t *testing.T) {
+	refocusGrads := func(epsilon float64) []float64 {
+		sw := &shiftedWeighting{Top: make([]Unit, 4)}
+		for i, v := range []float64{0.6, 0.3, 0.09, 0.01} {
+			sw.Top[i].Val = v
+		}
+		gamma := &Unit{Val: 0.7}
+		rf := newRefocus(gamma, sw, epsilon, 0, false)
+		for i := range rf.Top {
+			rf.Top[i].Grad = float64(i + 1)
+		}
+		rf.Backward()
+		return append(unitGrads(sw.Top), gamma.Grad)
+	}
+
+	if eps := (headConfig{}).refocusEpsilon(); eps != MachineEpsilon {
+		t.Fatalf("default epsilon %g, expected %g", eps, MachineEpsilon)
+	}
+	def := refocusGrads(headConfig{}.refocusEpsilon())
+	for i, g := range def[:4] {
+		if g == 0 {
+			t.Errorf("default epsilon skipped the gradient of weight %d", i)
+		}
+	}
+
+	// Only the weights below epsilon are skipped, which leaves the gradients of the other weights unchanged,
+	// but not that of gamma, which sums over the weights.
+	for _, test := range []struct {
+		epsilon float64
+		skipped int // the weights from this index on are below epsilon
+	}{
+		{epsilon: 0.05, skipped: 3},
+		{epsilon: 0.1, skipped: 2},
+	} {
+		grads := refocusGrads(test.epsilon)
+		for i, g := range grads[:4] {
+			if i >= test.skipped && g != 0 {
+				t.Errorf("epsilon %g: gradient of weight %d below epsilon is %f", test.epsilon, i, g)
+			}
+			if i < test.skipped && g != def[i] {
+				t.Errorf("epsilon %g: gradient of weight %d is %f, expected %f", test.epsilon, i, g, def[i])
+			}
+		}
+		if grads[4] == def[4] {
+			t.Errorf("epsilon %g did not change the gamma gradient", test.epsilon)
+		}
+	}
+}
+
+func TestMachineEpsilon(t *testing.T) {
+	if MachineEpsilon != 2.2e-16 {
+		t.Fatalf("MachineEpsilon is %g", MachineEpsilon)
+	}
+	if eps := math.Nextafter(1, 2) - 1; math.Abs(MachineEpsilon-eps) > 0.01*eps {
+		t.Errorf("MachineEpsilon %g differs from the float64 epsilon %g", MachineEpsilon, eps)
+	}
+
+	// By default, sharpening backpropagates exactly to the shifted weights no smaller than MachineEpsilon.
+	sw := &shiftedWeighting{Top: make([]Unit, 4)}
+	for i, v := range []float64{0.7, 0.3, MachineEpsilon / 2, 2 * MachineEpsilon} {
+		sw.Top[i].Val = v
+	}
+	rf := newRefocus(&Unit{Val: 0.2}, sw, headConfig{}.refocusEpsilon(), 0, false)
+	for i := range rf.Top {
+		rf.Top[i].Grad = float64(i + 1)
+	}
+	rf.Backward()
+	for _, u := range sw.Top {
+		if skipped := u.Val < MachineEpsilon; skipped != (u.Grad == 0) {
+			t.Errorf("shifted weight %g has gradient %g", u.Val, u.Grad)
+		}
+	}
+}
+
+func TestTopKLocations(t *testing.T) {
+	w := &refocus{Top: make([]Unit, 6)}
+	for i, v := range []float64{0.1, 0.3, 0.05, 0.3, 0.2, 0.05} {
+		w.Top[i].Val = v
+	}
+	memory := &writtenMemory{}
+	memory.data, memory.Top = makeFlatTensorUnit2(len(w.Top), 2)
+	r := newMemRead(w, memory)
+	tests := []struct {
+		k        int
+		expected []int
+	}{
+		{0, []int{}},
+		{1, []int{1}},
+		{3, []int{1, 3, 4}},
+		{10, []int{1, 3, 4, 0, 2, 5}},
+	}
+	for _, test := range tests {
+		locs := r.TopKLocations(test.k)
+		if len(locs) != len(test.expected) {
+			t.Fatalf("k %d: %v != %v", test.k, locs, test.expected)
+		}
+		for i := range locs {
+			if locs[i] != test.expected[i] {
+				t.Errorf("k %d: %v != %v", test.k, locs, test.expected)
+				break
+			}
+		}
+	}
+
+	w2 := &refocus{Top: make([]Unit, len(w.Top))}
+	for i, v := range []float64{0, 0.1, 0, 0, 0, 0.9} {
+		w2.Top[i].Val = v
+	}
+	machines := []*NTM{{memOp: &memOp{W: []*refocus{w}}}, {memOp: &memOp{W: []*refocus{w2}}}}
+	if focus := ReadFocus(machines, 2); len(focus) != 1 || focus[0][0] != 5 || focus[0][1] != 1 {
+		t.Errorf("read focus %v, expected [[5 1]]", focus)
+	}
+}
+
+func TestGammaClamp(t *testing.T) {
+	sw := &shiftedWeighting{Top: make([]Unit, 4)}
+	for i, v := range []float64{0.4, 0.3, 0.2, 0.1} {
+		sw.Top[i].Val = v
+	}
+	gamma := &Unit{Val: 1e3}
+	unclamped := newRefocus(gamma, sw, MachineEpsilon, 0, false)
+	if unclamped.Top[1].Val > 1e-100 {
+		t.Fatalf("unclamped refocus is not one-hot: %+v", unclamped.Top)
+	}
+
+	rf := newRefocus(gamma, sw, MachineEpsilon, 2, false)
+	var sum float64 = 0
+	for _, u := range sw.Top {
+		sum += u.Val * u.Val
+	}
+	for i, u := range rf.Top {
+		if math.IsNaN(u.Val) || math.IsInf(u.Val, 0) {
+			t.Fatalf("refocus[%d] is %f", i, u.Val)
+		}
+		expected := sw.Top[i].Val * sw.Top[i].Val / sum
+		if math.Abs(u.Val-expected) > 1e-12 {
+			t.Errorf("refocus[%d] %f != %f", i, u.Val, expected)
+		}
+		rf.Top[i].Grad = float64(i + 1)
+	}
+	rf.Backward()
+	if gamma.Grad != 0 {
+		t.Errorf("gamma gradient above the clamp is %f", gamma.Grad)
+	}
+
+	// Below the clamp, the clamp has no effect.
+	gamma = &Unit{Val: 0.3}
+	a := newRefocus(gamma, sw, MachineEpsilon, 0, false)
+	b := newRefocus(gamma, sw, MachineEpsilon, 5, false)
+	for i := range a.Top {
+		if a.Top[i].Val != b.Top[i].Val {
+			t.Errorf("refocus[%d] %f != %f", i, b.Top[i].Val, a.Top[i].Val)
+		}
+	}
+}
+
+func TestBetaClamp(t *testing.T) {
+	s := &similarityCircuit{Top: Unit{Val: 0.5}}
+	beta := &Unit{Val: 1e3}
+	bs := newBetaSimilarity(beta, s, 10)
+	if bs.Top.Val != 5 {
+		t.Errorf("clamped beta similarity %f, expected 5", bs.Top.Val)
+	}
+	bs.Top.Grad = 1
+	bs.Backward()
+	if beta.Grad != 0 {
+		t.Errorf("beta gradient above the clamp is %f", beta.Grad)
+	}
+	if s.Top.Grad != 10 {
+		t.Errorf("similarity gradient %f, expected 10", s.Top.Grad)
+	}
+
+	beta = &Unit{Val: 1}
+	bs = newBetaSimilarity(beta, s, 10)
+	bs.Top.Grad = 1
+	bs.Backward()
+	if beta.Grad != 0.5*math.E {
+		t.Errorf("beta gradient below the clamp %f, expected %f", beta.Grad, 0.5*math.E)
+	}
+}
+
+func TestCircuitClamps(t *testing.T) {
+	// Clamps above the key strengths and exponents pass the gradients through.
+	testCircuit(t, headConfig{maxBeta: 1e6, maxGamma: 1e6})
+
+	// exp(beta) > 1 for beta > 0 and Softplus(gamma)+1 > 1.69 for gamma > 0, so these clamps bind for every head.
+	n, m := 3, 2
+	rnd := rand.New(rand.NewSource(49))
+	memory := &writtenMemory{Top: makeTensorUnit2(n, m)}
+	for i := range memory.Top {
+		for j := range memory.Top[i] {
+			memory.Top[i][j].Val = rnd.Float64()
+		}
+	}
+	cfg := headConfig{maxBeta: 1, maxGamma: 1.5}
+	heads := make([]*Head, 2)
+	for i := range heads {
+		heads[i] = newHead(m, cfg)
+		heads[i].Wtm1 = randomRefocus(n)
+		for j := range heads[i].units {
+			heads[i].units[j].Val = 0.1 + rnd.Float64()
+		}
+	}
+	circuit := newMemOp(heads, memory, nil)
+	for _, w := range circuit.W {
+		for j := range w.Top {
+			w.Top[j].Grad += outputGradient
+		}
+	}
+	circuit.Backward()
+
+	for i, h := range heads {
+		a := circuit.A[i].(*defaultAddressing)
+		for j, bs := range a.WC.Units {
+			if !bs.clamped || bs.b != cfg.maxBeta {
+				t.Errorf("head %d location %d: key strength %f is not clamped to %f", i, j, bs.b, cfg.maxBeta)
+			}
+		}
+		if rf := circuit.W[i]; !rf.clamped || rf.g != cfg.maxGamma {
+			t.Errorf("head %d: exponent %f is not clamped to %f", i, rf.g, cfg.maxGamma)
+		}
+		for _, w := range circuit.W[i].Top {
+			if math.IsNaN(w.Val) || math.IsInf(w.Val, 0) {
+				t.Errorf("head %d: weights %+v are not finite", i, circuit.W[i].Top)
+				break
+			}
+		}
+		if g := h.Beta().Grad; g != 0 {
+			t.Errorf("head %d: clamped beta has gradient %g", i, g)
+		}
+		if g := h.Gamma().Grad; g != 0 {
+			t.Errorf("head %d: clamped gamma has gradient %g", i, g)
+		}
+		if g := h.G().Grad; g == 0 {
+			t.Errorf("head %d: the interpolation gate has no gradient", i)
+		}
+	}
+}
+
+func TestCircuitDotProductSimilarity(t *testing.T) {
+	testCircuit(t, headConfig{similarity: DotProductSimilarity})
+}
+
+func TestCircuitAddActivation(t *testing.T) {
+	for _, a := range []AddActivation{SigmoidAdd, TanhAdd, LinearAdd} {
+		t.Run(fmt.Sprintf("%d", a), func(t *testing.T) {
+			testCircuit(t, headConfig{addActivation: a})
+		})
+	}
+}
+
+func TestCircuitCompensatedSums(t *testing.T) {
+	testCircuit(t, headConfig{compensatedSums: true})
+}
+
+func TestDotSimilarityZero(t *testing.T) {
+	u := make([]Unit, 3)
+	v := make([]Unit, 3)
+	s := newDotSimilarity(u, v)
+	if s.Top.Val != 0 {
+		t.Errorf("similarity of zero vectors %f", s.Top.Val)
+	}
+	s.Top.Grad = 1
+	s.Backward()
+	for i := range u {
+		if u[i].Grad != 0 || v[i].Grad != 0 {
+			t.Errorf("gradient %d: %f %f", i, u[i].Grad, v[i].Grad)
+		}
+	}
+
+	v[0].Val, v[1].Val = 2, -1
+	s = newDotSimilarity(u, v)
+	s.Top.Grad = 1
+	s.Backward()
+	for i := range u {
+		if u[i].Grad != v[i].Val || math.IsNaN(v[i].Grad) {
+			t.Errorf("gradient %d: %f %f", i, u[i].Grad, v[i].Grad)
+		}
+	}
+}
+
+func TestWriteFocus(t *testing.T) {
+	newRefocusOf := func(vals ...float64) *refocus {
+		w := &refocus{Top: make([]Unit, len(vals))}
+		for i, v := range vals {
+			w.Top[i].Val = v
+		}
+		return w
+	}
+	machines := []*NTM{
+		{memOp: &memOp{W: []*refocus{newRefocusOf(0.7, 0.2, 0.1, 0), newRefocusOf(0, 0.1, 0.3, 0.6)}}},
+		{memOp: &memOp{W: []*refocus{newRefocusOf(0.1, 0.6, 0.25, 0.05), newRefocusOf(0.4, 0.1, 0.1, 0.4)}}},
+	}
+	expected := [][][]int{{{0, 1}, {3, 2}}, {{1, 2}, {0, 3}}}
+	focus := WriteFocus(machines, 2)
+	if len(focus) != len(expected) {
+		t.Fatalf("%v != %v", focus, expected)
+	}
+	for tt := range expected {
+		if len(focus[tt]) != len(expected[tt]) {
+			t.Fatalf("[%d] %v != %v", tt, focus[tt], expected[tt])
+		}
+		for i := range expected[tt] {
+			for j := range expected[tt][i] {
+				if focus[tt][i][j] != expected[tt][i][j] {
+					t.Errorf("[%d][%d] %v != %v", tt, i, focus[tt][i], expected[tt][i])
+					break
+				}
+			}
+		}
+	}
+
+	if focus := WriteFocus(nil, 2); focus != nil {
+		t.Errorf("write focus of no machines %v", focus)
+	}
+	if focus := ReadFocus(nil, 2); focus != nil {
+		t.Errorf("read focus of no machines %v", focus)
+	}
+	for _, f := range WriteFocus(machines, -1) {
+		for _, locs := range f {
+			if len(locs) != 0 {
+				t.Errorf("write focus with a negative k %v", locs)
+			}
+		}
+	}
+	for _, locs := range ReadFocus(machines, -1) {
+		if len(locs) != 0 {
+			t.Errorf("read focus with a negative k %v", locs)
+		}
+	}
+}
+
+func TestCircuitKeyWidth(t *testing.T) {
+	testCircuit(t, headConfig{keyColumns: 1})
+}
+
+func TestKeyWidthGradients(t *testing.T) {
+	n, m, p := 4, 3, 2
+	rnd := rand.New(rand.NewSource(23))
+	newCircuit := func() (*memOp, *writtenMemory) {
+		memory := &writtenMemory{}
+		memory.data, memory.Top = makeFlatTensorUnit2(n, m)
+		for i := range memory.data {
+			memory.data[i].Val = rnd.Float64()
+		}
+		h := newHead(m, headConfig{keyColumns: p})
+		h.Wtm1 = randomRefocus(n)
+		for i := range h.units {
+			h.units[i].Val = rnd.Float64()
+		}
+		if len(h.K()) != p {
+			t.Fatalf("key of size %d, expected %d", len(h.K()), p)
+		}
+		return newMemOp([]*Head{h}, memory, nil), memory
+	}
+
+	// Gradients flowing only through the addressing weights reach the key columns only.
+	op, memory := newCircuit()
+	for i := range op.W[0].Top {
+		op.W[0].Top[i].Grad = float64(i + 1)
+	}
+	op.Backward()
+	for i, row := range memory.Top {
+		for j, u := range row {
+			if j < p && u.Grad == 0 {
+				t.Errorf("key column [%d][%d] has no similarity gradient", i, j)
+			}
+			if j >= p && u.Grad != 0 {
+				t.Errorf("value column [%d][%d] has similarity gradient %f", i, j, u.Grad)
+			}
+		}
+	}
+
+	// Reads and writes reach the value columns.
+	op, memory = newCircuit()
+	for i := range op.R[0].Top {
+		op.R[0].Top[i].Grad = 1
+	}
+	for i := range op.WM.data {
+		op.WM.data[i].Grad = 1
+	}
+	op.Backward()
+	for i, row := range memory.Top {
+		for j := p; j < m; j++ {
+			if row[j].Grad == 0 {
+				t.Errorf("value column [%d][%d] has no read or write gradient", i, j)
+			}
+		}
+	}
+}
+
+func TestCircuitNormalizedKey(t *testing.T) {
+	testCircuit(t, headConfig{normalizeKey: true})
+	testCircuit(t, headConfig{normalizeKey: true, similarity: DotProductSimilarity})
+}
+
+func TestCircuitAddresser(t *testing.T) {
+	testCircuit(t, headConfig{addresser: DefaultAddresser{}})
+	testCircuit(t, headConfig{addresser: DefaultAddresser{}, mode: ContentOnly})
+}
+
+func TestDefaultAddresser(t *testing.T) {
+	vectorSize := 3
+	c := NewEmptyController1(vectorSize+2, vectorSize, 5, 2, 6, 3)
+	plugged := NewEmptyController1(vectorSize+2, vectorSize, 5, 2, 6, 3, WithAddresser(DefaultAddresser{}))
+	for _, c := range []Controller{c, plugged} {
+		rnd := rand.New(rand.NewSource(39))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	}
+	x, y := copytask.GenSeq(4, vectorSize)
+
+	expected := HeadWeights(ForwardBackward(c, x, y))
+	expectedGrads := Gradients(c)
+	for i, hw := range HeadWeights(ForwardBackward(plugged, x, y)) {
+		for tt := range hw {
+			for j, w := range hw[tt] {
+				if w != expected[i][tt][j] {
+					t.Fatalf("head %d, time %d: weight %d %g != %g", i, tt, j, w, expected[i][tt][j])
+				}
+			}
+		}
+	}
+	for i, g := range Gradients(plugged) {
+		if g != expectedGrads[i] {
+			t.Fatalf("gradient %d %g != %g", i, g, expectedGrads[i])
+		}
+	}
+}
+
+// rotatingAddresser moves the weights of a head by one memory location at every time instant, ignoring the memory.
+type rotatingAddresser struct{}
+
+type rotatingAddressing struct {
+	wtm1 *refocus
+	top  []Unit
+}
+
+func (rotatingAddresser) Address(h *Head, head int, memory [][]Unit) Addressing {
+	a := &rotatingAddressing{wtm1: h.Wtm1, top: make([]Unit, len(memory))}
+	for i := range a.top {
+		a.top[i].Val = h.Wtm1.Top[(i+len(memory)-1)%len(memory)].Val
+	}
+	return a
+}
+
+func (a *rotatingAddressing) Weights() []Unit {
+	return a.top
+}
+
+func (a *rotatingAddressing) Backward() {
+	n := len(a.top)
+	for i, top := range a.top {
+		a.wtm1.Top[(i+n-1)%n].Grad += top.Grad
+	}
+}
+
+func TestAddresser(t *testing.T) {
+	vectorSize := 2
+	rnd := rand.New(rand.NewSource(40))
+	c := NewEmptyController1(vectorSize+2, vectorSize, 3, 1, 4, 3, WithAddresser(rotatingAddresser{}))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(2, vectorSize)
+
+	hw := HeadWeights(forward(c, x))[0]
+	for tt := 1; tt < len(hw); tt++ {
+		for j := range hw[tt] {
+			if prev := hw[tt-1][(j+len(hw[tt])-1)%len(hw[tt])]; hw[tt][j] != prev {
+				t.Fatalf("time %d: weight %d %f, expected the previous weight %f", tt, j, hw[tt][j], prev)
+			}
+		}
+	}
+	for i, g := range CheckGradients(c, x, y) {
+		if g.Error() > 1e-5 {
+			t.Errorf("weight %d: analytic gradient %f, numeric %f", i, g.Analytic, g.Numeric)
+		}
+	}
+}
+
+func TestReadOnlyLocation(t *testing.T) {
+	n, m, reserved := 4, 3, 2
+	rnd := rand.New(rand.NewSource(42))
+	mtm1 := &writtenMemory{}
+	mtm1.data, mtm1.Top = makeFlatTensorUnit2(n, m)
+	for i := range mtm1.data {
+		mtm1.data[i].Val = rnd.Float64() - 0.5
+	}
+	heads := []*Head{
+		newHead(m, headConfig{readOnly: true, readOnlyRow: reserved}),
+		newHead(m, headConfig{readOnly: true, readOnlyRow: reserved, writeGate: true}),
+	}
+	ws := make([]*refocus, len(heads))
+	for k, h := range heads {
+		for i := range h.units {
+			h.units[i].Val = rnd.Float64() - 0.5
+		}
+		ws[k] = randomRefocus(n)
+	}
+	wm := newWrittenMemory(ws, heads, mtm1, nil)
+	for j, u := range wm.Top[reserved] {
+		if u.Val != mtm1.Top[reserved][j].Val {
+			t.Errorf("reserved memory [%d] changed from %f to %f", j, mtm1.Top[reserved][j].Val, u.Val)
+		}
+	}
+	for i := range wm.Top {
+		if i != reserved && wm.Top[i][0].Val == mtm1.Top[i][0].Val {
+			t.Errorf("memory location %d was not written", i)
+		}
+	}
+
+	// The gradients of the reserved location flow only to the memory at the previous time instant.
+	for i := range wm.Top {
+		for j := range wm.Top[i] {
+			wm.Top[i][j].Grad = rnd.Float64() - 0.5
+		}
+	}
+	wm.Backward()
+	headGrads := make([][]float64, len(heads))
+	for k, h := range heads {
+		headGrads[k] = unitGrads(h.units)
+		for i := range h.units {
+			h.units[i].Grad = 0
+		}
+		if g := ws[k].Top[reserved].Grad; g != 0 {
+			t.Errorf("head %d: write weight of the reserved location has gradient %f", k, g)
+		}
+	}
+	for j := range wm.Top[reserved] {
+		if g := mtm1.Top[reserved][j].Grad; g != wm.Top[reserved][j].Grad {
+			t.Errorf("reserved memory [%d] has gradient %f, expected %f", j, g, wm.Top[reserved][j].Grad)
+		}
+		wm.Top[reserved][j].Grad *= 3
+	}
+	wm.Backward()
+	for k, h := range heads {
+		for i, g := range unitGrads(h.units) {
+			if g != headGrads[k][i] {
+				t.Errorf("head %d: unit %d gradient %f depends on the reserved location, expected %f", k, i, g, headGrads[k][i])
+			}
+		}
+	}
+}
+
+func TestReadOnlyLocationGradients(t *testing.T) {
+	vectorSize := 2
+	c := NewEmptyController1(vectorSize+2, vectorSize, 3, 2, 4, 3, WithReadOnlyLocation(0), WithWriteGate())
+	rnd := rand.New(rand.NewSource(43))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(2, vectorSize)
+	for i, g := range CheckGradients(c, x, y) {
+		if g.Error() > 1e-5 {
+			t.Errorf("weight %d: analytic gradient %f, numeric %f", i, g.Analytic, g.Numeric)
+		}
+	}
+}
+
+func TestMemReadMulti(t *testing.T) {
+	n, m := 3, 2
+	rnd := rand.New(rand.NewSource(54))
+	memory := &writtenMemory{}
+	memory.data, memory.Top = makeFlatTensorUnit2(n, m)
+	for i := range memory.data {
+		memory.data[i].Val = rnd.Float64() - 0.5
+	}
+	ws := []*refocus{randomRefocus(n), randomRefocus(n)}
+
+	// The loss is a weighted sum of the read units, so that every unit receives a distinct gradient.
+	loss := func() float64 {
+		r := newMemReadMulti(ws, memory)
+		var l float64 = 0
+		for i, u := range r.Top {
+			l += float64(i+1) * u.Val
+		}
+		return l
+	}
+
+	r := newMemReadMulti(ws, memory)
+	if len(r.Top) != len(ws)*m {
+		t.Fatalf("read has %d units, expected %d", len(r.Top), len(ws)*m)
+	}
+	for k, w := range ws {
+		for i, u := range newMemRead(w, memory).Top {
+			if u.Val != r.Top[k*m+i].Val {
+				t.Errorf("read %d unit %d: %f != %f", k, i, r.Top[k*m+i].Val, u.Val)
+			}
+		}
+	}
+	for i := range r.Top {
+		r.Top[i].Grad = float64(i + 1)
+	}
+	r.Backward()
+
+	check := func(name string, u *Unit) {
+		x := u.Val
+		h := 1e-6
+		u.Val = x + h
+		lp := loss()
+		u.Val = x - h
+		lm := loss()
+		u.Val = x
+		grad := (lp - lm) / (2 * h)
+		if math.Abs(grad-u.Grad) > 1e-5 {
+			t.Errorf("wrong %s gradient expected %f, got %f", name, grad, u.Grad)
+		}
+	}
+	for i := range memory.data {
+		check(fmt.Sprintf("memory[%d]", i), &memory.data[i])
+	}
+	for k, w := range ws {
+		for i := range w.Top {
+			check(fmt.Sprintf("ws[%d][%d]", k, i), &w.Top[i])
+		}
+	}
+}
+
+func TestReadWeightings(t *testing.T) {
+	vectorSize, h1Size, numHeads, n, m := 2, 3, 2, 4, 3
+	single := NewEmptyController1(vectorSize+2, vectorSize, h1Size, numHeads, n, m, WithWriteGate())
+	c := NewEmptyController1(vectorSize+2, vectorSize, h1Size, numHeads, n, m, WithWriteGate(), WithReadWeightings(2))
+	if len(c.Wh1r[0])*len(c.Wh1r[0][0]) != 2*numHeads*m {
+		t.Errorf("read input width %d, expected %d", len(c.Wh1r[0])*len(c.Wh1r[0][0]), 2*numHeads*m)
+	}
+	// Every head reads m more inputs, and emits the addressing units of its second weighting.
+	addressingUnits := m + 1 + 2 + 1
+	if d := c.NumWeights() - single.NumWeights(); d != numHeads*(h1Size*m+addressingUnits*(h1Size+1)) {
+		t.Errorf("a second weighting adds %d weights, expected %d", d, numHeads*(h1Size*m+addressingUnits*(h1Size+1)))
+	}
+
+	rnd := rand.New(rand.NewSource(55))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(2, vectorSize)
+	machines := ForwardBackward(c, x, y)
+	for _, m := range machines {
+		for i, h := range m.Controller.Heads() {
+			if len(h.reads) != 1 || h.reads[0].WriteGate() != nil || &h.reads[0].Gamma().Val == &h.Gamma().Val {
+				t.Fatalf("head %d: wrong additional read weighting", i)
+			}
+			if len(m.memOp.R[i].Top) != 2*len(m.memOp.WM.Top[0]) {
+				t.Fatalf("head %d: read has %d units", i, len(m.memOp.R[i].Top))
+			}
+		}
+	}
+	for i, g := range CheckGradients(c, x, y) {
+		if g.Error() > 1e-5 {
+			t.Errorf("weight %d: analytic gradient %f, numeric %f", i, g.Analytic, g.Numeric)
+		}
+	}
+
+	deep := NewEmptyController1Deep(vectorSize+2, vectorSize, []int{3}, 1, n, m, WithReadWeightings(3), WithAddressingModes(ContentOnly))
+	deep.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	for i, g := range CheckGradients(deep, x, y) {
+		if g.Error() > 1e-5 {
+			t.Errorf("deep weight %d: analytic gradient %f, numeric %f", i, g.Analytic, g.Numeric)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("no panic for zero read weightings")
+		}
+	}()
+	WithReadWeightings(0)
+}
+
+func TestNormalizedKeyScale(t *testing.T) {
+	n, m := 4, 3
+	rnd := rand.New(rand.NewSource(26))
+	memory := &writtenMemory{}
+	memory.data, memory.Top = makeFlatTensorUnit2(n, m)
+	for i := range memory.data {
+		memory.data[i].Val = rnd.Float64() - 0.5
+	}
+	h := newHead(m, headConfig{normalizeKey: true, similarity: DotProductSimilarity})
+	h.Wtm1 = randomRefocus(n)
+	for i := range h.units {
+		h.units[i].Val = rnd.Float64() - 0.5
+	}
+	w := unitVals(newMemOp([]*Head{h}, memory, nil).W[0].Top)
+	for i := range h.K() {
+		h.K()[i].Val *= 7
+	}
+	scaled := unitVals(newMemOp([]*Head{h}, memory, nil).W[0].Top)
+	for i := range w {
+		if math.Abs(w[i]-scaled[i]) > 1e-12 {
+			t.Errorf("[%d] weight %f changed to %f by scaling the key", i, w[i], scaled[i])
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package ntm
+
+// minSlabSize is the minimum number of elements allocated at once by a slab.
+const minSlabSize = 4096
+
+// A unitSlab hands out slices of Units from a large backing array, which is reused after reset.
+type unitSlab struct {
+	all  []Unit
+	free []Unit
+	used int
+}
+
+// alloc returns a slice of n zeroed Units.
+func (s *unitSlab) alloc(n int) []Unit {
+	s.used += n
+	if len(s.free) < n {
+		size := n
+		if size < minSlabSize {
+			size = minSlabSize
+		}
+		s.free = make([]Unit, size)
+	}
+	b := s.free[:n:n]
+	s.free = s.free[n:]
+	for i := range b {
+		b[i] = Unit{}
+	}
+	return b
+}
+
+// reset makes the slab reuse its backing array, which is enlarged to fit all allocations since the last reset.
+func (s *unitSlab) reset() {
+	if len(s.all) < s.used {
+		s.all = make([]Unit, s.used)
+	}
+	s.free = s.all
+	s.used = 0
+}
+
+// A floatSlab is similar to a unitSlab, except that it hands out slices of float64s.
+type floatSlab struct {
+	all  []float64
+	free []float64
+	used int
+}
+
+// alloc returns a slice of n zeroed float64s.
+func (s *floatSlab) alloc(n int) []float64 {
+	s.used += n
+	if len(s.free) < n {
+		size := n
+		if size < minSlabSize {
+			size = minSlabSize
+		}
+		s.free = make([]float64, size)
+	}
+	b := s.free[:n:n]
+	s.free = s.free[n:]
+	for i := range b {
+		b[i] = 0
+	}
+	return b
+}
+
+func (s *floatSlab) reset() {
+	if len(s.all) < s.used {
+		s.all = make([]float64, s.used)
+	}
+	s.free = s.all
+	s.used = 0
+}
+
+// A tape holds the written memory buffers of a run of ForwardBackward.
+// A nil tape allocates fresh buffers.
+type tape struct {
+	units  unitSlab
+	floats floatSlab
+}
+
+func (tp *tape) reset() {
+	tp.units.reset()
+	tp.floats.reset()
+}
+
+// flatTensorUnit2 is similar to makeFlatTensorUnit2, except that the backing array is allocated from the tape.
+func (tp *tape) flatTensorUnit2(n, m int) ([]Unit, [][]Unit) {
+	if tp == nil {
+		return makeFlatTensorUnit2(n, m)
+	}
+	data := tp.units.alloc(n * m)
+	t := make([][]Unit, n)
+	for i := 0; i < len(t); i++ {
+		t[i] = data[i*m : (i+1)*m : (i+1)*m]
+	}
+	return data, t
+}
+
+// tensor2 is similar to MakeTensor2, except that the rows are allocated from the tape.
+func (tp *tape) tensor2(n, m int) [][]float64 {
+	if tp == nil {
+		return MakeTensor2(n, m)
+	}
+	data := tp.floats.alloc(n * m)
+	t := make([][]float64, n)
+	for i := 0; i < len(t); i++ {
+		t[i] = data[i*m : (i+1)*m : (i+1)*m]
+	}
+	return t
+}
+
+// floats1 returns a slice of n zeroed float64s allocated from the tape.
+func (tp *tape) floats1(n int) []float64 {
+	if tp == nil {
+		return make([]float64, n)
+	}
+	return tp.floats.alloc(n)
+}
+
+// An Arena runs ForwardBackward on a controller repeatedly, reusing the backing arrays of the written memories of its previous runs.
+// The arrays grow to fit the longest sequence seen.
+// Only these arrays are pooled: the rows indexing them, the addressing circuits and the controller states are still allocated on every run.
+type Arena struct {
+	C  Controller
+	tp tape
+}
+
+// NewArena returns an Arena running the controller c.
+func NewArena(c Controller) *Arena {
+	return &Arena{C: c}
+}
+
+// Run is similar to ForwardBackward on the controller of the Arena.
+// The returned NTMs are only valid until the next call to Run.
+func (a *Arena) Run(x, y [][]float64) []*NTM {
+	a.tp.reset()
+	a.C.Weights(func(u *Unit) { u.Grad = 0 })
+	return runTape(&a.tp, a.C, x, y, nil)
+}
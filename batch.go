@@ -0,0 +1,211 @@
+package ntm
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// PadBatch pads every sequence in seqs with zero vectors to the length of the longest sequence.
+// It also returns the original lengths of the sequences.
+func PadBatch(seqs [][][]float64) (padded [][][]float64, lengths []int) {
+	maxLen := 0
+	size := 0
+	lengths = make([]int, len(seqs))
+	for i, seq := range seqs {
+		lengths[i] = len(seq)
+		if len(seq) > maxLen {
+			maxLen = len(seq)
+		}
+		if len(seq) > 0 {
+			size = len(seq[0])
+		}
+	}
+
+	padded = make([][][]float64, len(seqs))
+	for i, seq := range seqs {
+		padded[i] = make([][]float64, maxLen)
+		copy(padded[i], seq)
+		for t := len(seq); t < maxLen; t++ {
+			padded[i][t] = make([]float64, size)
+		}
+	}
+	return padded, lengths
+}
+
+// LengthMask returns a mask of the given size whose first length elements are true.
+func LengthMask(size, length int) []bool {
+	mask := make([]bool, size)
+	for t := 0; t < length && t < size; t++ {
+		mask[t] = true
+	}
+	return mask
+}
+
+// ForwardBackwardPadded computes a controller's predictions and gradients on a padded batch of sequences.
+// The time instants of the i-th sequence beyond lengths[i] are padding, and are ignored.
+// The gradients of the controller weights are summed over the batch.
+func ForwardBackwardPadded(c Controller, xs, ys [][][]float64, lengths []int) [][]*NTM {
+	c.Weights(func(u *Unit) { u.Grad = 0 })
+	machines := make([][]*NTM, len(xs))
+	for i := range xs {
+		machines[i] = forwardBackward(c, xs[i][0:lengths[i]], ys[i][0:lengths[i]])
+	}
+	return machines
+}
+
+// LossPadded returns the cross-entropy loss summed over a padded batch of sequences, excluding the padded time instants.
+func LossPadded(ys [][][]float64, lengths []int, machines [][]*NTM) float64 {
+	var l float64 = 0
+	for i, y := range ys {
+		l += LossMasked(y, machines[i], LengthMask(len(y), lengths[i]))
+	}
+	return l
+}
+
+// A cloner is a Controller that can make independent copies of itself.
+type cloner interface {
+	clone() Controller
+}
+
+// copyWeights copies the values of the weights of src to those of dst, which must have the same architecture.
+func copyWeights(dst, src Controller) {
+	vals := make([]float64, 0, src.NumWeights())
+	src.Weights(func(u *Unit) { vals = append(vals, u.Val) })
+	i := 0
+	dst.Weights(func(u *Unit) {
+		u.Val = vals[i]
+		i++
+	})
+}
+
+// Clone returns an independent copy of c, whose weights, gradients, frozen groups and pruned weights are copies of those of c.
+// Modifying the copy does not affect c, and the two make identical predictions until either is modified.
+// The dropout masks of the copy are drawn from a random source seeded from that of c.
+// Clone panics if c cannot be copied.
+func Clone(c Controller) Controller {
+	cl, ok := c.(cloner)
+	if !ok {
+		panic(fmt.Sprintf("ntm: %T does not support Clone", c))
+	}
+	d := cl.clone()
+	grads := Gradients(c)
+	i := 0
+	d.Weights(func(u *Unit) {
+		u.Grad = grads[i]
+		i++
+	})
+	if g, ok := c.(weightGrouper); ok {
+		for group, frozen := range g.frozenGroups() {
+			d.(weightGrouper).frozenGroups()[group] = frozen
+		}
+	}
+	if p, ok := c.(pruner); ok {
+		for j, pruned := range p.prunedWeights() {
+			d.(pruner).prunedWeights()[j] = pruned
+		}
+	}
+	return d
+}
+
+// ForwardBackwardBatch computes a controller's predictions and gradients on a batch of sequences,
+// where batch[i][0] and batch[i][1] are the input and output of the i-th sequence.
+// The gradients of the controller weights are summed over the batch.
+//
+// The sequences are run concurrently on GOMAXPROCS workers.
+// Each worker runs on its own copy of the controller,
+// whose gradients are added to those of the controller after the worker finishes.
+// The returned NTMs thus refer to the copies rather than to c.
+// Controllers which cannot be copied are run sequentially.
+func ForwardBackwardBatch(c Controller, batch [][2][][]float64) [][]*NTM {
+	c.Weights(func(u *Unit) { u.Grad = 0 })
+	machines := make([][]*NTM, len(batch))
+
+	cl, ok := c.(cloner)
+	numWorkers := runtime.GOMAXPROCS(0)
+	if numWorkers > len(batch) {
+		numWorkers = len(batch)
+	}
+	if !ok || numWorkers <= 1 {
+		for i, seq := range batch {
+			machines[i] = forwardBackward(c, seq[0], seq[1])
+		}
+		return machines
+	}
+
+	jobs := make(chan int, len(batch))
+	for i := range batch {
+		jobs <- i
+	}
+	close(jobs)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		// Clone outside of the worker, as cloning may draw from the random source of the controller.
+		wc := cl.clone()
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				machines[i] = forwardBackward(wc, batch[i][0], batch[i][1])
+			}
+
+			grads := make([]float64, 0, wc.NumWeights())
+			wc.Weights(func(u *Unit) { grads = append(grads, u.Grad) })
+			mu.Lock()
+			defer mu.Unlock()
+			j := 0
+			c.Weights(func(u *Unit) {
+				u.Grad += grads[j]
+				j++
+			})
+		}()
+	}
+	wg.Wait()
+	return machines
+}
+
+// ForwardMany returns the predictions across time of a controller on every input sequence of xs, see Predictions.
+// The sequences are run concurrently on GOMAXPROCS workers, each running on its own copy of the controller,
+// and sequentially for controllers which cannot be copied.
+// ForwardMany panics with a DimError if a sequence is empty or does not match the input size of the controller.
+// It does not modify the gradients of the controller.
+func ForwardMany(c Controller, xs [][][]float64) [][][]float64 {
+	pdts := make([][][]float64, len(xs))
+
+	cl, ok := c.(cloner)
+	numWorkers := runtime.GOMAXPROCS(0)
+	if numWorkers > len(xs) {
+		numWorkers = len(xs)
+	}
+	if !ok || numWorkers <= 1 {
+		for i, x := range xs {
+			pdts[i] = predict(c, x)
+		}
+		return pdts
+	}
+
+	// Check the sizes of the inputs up front, so that the workers do not panic.
+	for _, x := range xs {
+		checkInputSize(c, x)
+	}
+	jobs := make(chan int, len(xs))
+	for i := range xs {
+		jobs <- i
+	}
+	close(jobs)
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		// Clone outside of the worker, as cloning may draw from the random source of the controller.
+		wc := cl.clone()
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				pdts[i] = predict(wc, xs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return pdts
+}
@@ -0,0 +1,211 @@
+package ntm
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/fumin/ntm/copytask"
+)
+
+func TestPadBatch(t *testing.T) {
+	rnd := rand.New(rand.NewSource(9))
+	vectorSize := 3
+	var xs, ys [][][]float64
+	for _, size := range []int{1, 4, 2} {
+		x, y := copytask.GenSeq(size, vectorSize)
+		xs = append(xs, x)
+		ys = append(ys, y)
+	}
+	c := NewEmptyController1(vectorSize+2, vectorSize, 5, 1, 6, 3)
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+
+	var expectedLoss float64 = 0
+	expectedGrads := make([]float64, c.NumWeights())
+	for i := range xs {
+		expectedLoss += Loss(ys[i], ForwardBackward(c, xs[i], ys[i]))
+		j := 0
+		c.Weights(func(u *Unit) {
+			expectedGrads[j] += u.Grad
+			j++
+		})
+	}
+
+	paddedXs, lengths := PadBatch(xs)
+	paddedYs, _ := PadBatch(ys)
+	for i := range paddedXs {
+		if len(paddedXs[i]) != len(xs[1]) || len(paddedYs[i]) != len(ys[1]) {
+			t.Fatalf("sequence %d is not padded to the longest length %d", i, len(xs[1]))
+		}
+		if lengths[i] != len(xs[i]) {
+			t.Fatalf("expected length %d, got %d", len(xs[i]), lengths[i])
+		}
+	}
+	machines := ForwardBackwardPadded(c, paddedXs, paddedYs, lengths)
+	if l := LossPadded(paddedYs, lengths, machines); math.Abs(l-expectedLoss) > 1e-9 {
+		t.Errorf("expected padded loss %f, got %f", expectedLoss, l)
+	}
+	j := 0
+	c.Weights(func(u *Unit) {
+		if math.Abs(u.Grad-expectedGrads[j]) > 1e-9 {
+			t.Errorf("weight %d: expected padded gradient %f, got %f", j, expectedGrads[j], u.Grad)
+		}
+		j++
+	})
+}
+
+func TestForwardBackwardBatch(t *testing.T) {
+	rnd := rand.New(rand.NewSource(10))
+	vectorSize := 3
+	batch := make([][2][][]float64, 0)
+	for _, size := range []int{1, 4, 2, 3, 5} {
+		x, y := copytask.GenSeq(size, vectorSize)
+		batch = append(batch, [2][][]float64{x, y})
+	}
+	controllers := []Controller{
+		NewEmptyController1(vectorSize+2, vectorSize, 5, 2, 6, 3, WithWriteGate()),
+		NewEmptyController1Deep(vectorSize+2, vectorSize, []int{5, 4}, 1, 6, 3),
+	}
+	for _, c := range controllers {
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+
+		expectedGrads := make([]float64, c.NumWeights())
+		expectedPdts := make([][][]float64, len(batch))
+		for i, seq := range batch {
+			expectedPdts[i] = Predictions(ForwardBackward(c, seq[0], seq[1]))
+			j := 0
+			c.Weights(func(u *Unit) {
+				expectedGrads[j] += u.Grad
+				j++
+			})
+		}
+
+		machines := ForwardBackwardBatch(c, batch)
+		for i := range batch {
+			pdts := Predictions(machines[i])
+			for tt := range pdts {
+				for k := range pdts[tt] {
+					if pdts[tt][k] != expectedPdts[i][tt][k] {
+						t.Fatalf("prediction [%d][%d][%d] %f != %f", i, tt, k, pdts[tt][k], expectedPdts[i][tt][k])
+					}
+				}
+			}
+		}
+		j := 0
+		c.Weights(func(u *Unit) {
+			if math.Abs(u.Grad-expectedGrads[j]) > 1e-12 {
+				t.Errorf("grad %d %f != %f", j, u.Grad, expectedGrads[j])
+			}
+			j++
+		})
+	}
+}
+
+func TestForwardMany(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(2))
+	vectorSize := 3
+	c := NewEmptyController1(vectorSize+2, vectorSize, 5, 2, 6, 3)
+	rnd := rand.New(rand.NewSource(44))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x1, _ := copytask.GenSeq(2, vectorSize)
+	x2, _ := copytask.GenSeq(4, vectorSize)
+	expected := [][][]float64{Predictions(forward(c, x1)), Predictions(forward(c, x2))}
+
+	pdts := ForwardMany(c, [][][]float64{x1, x2})
+	if len(pdts) != len(expected) {
+		t.Fatalf("%d sequences, expected %d", len(pdts), len(expected))
+	}
+	for i := range expected {
+		if len(pdts[i]) != len(expected[i]) {
+			t.Fatalf("sequence %d: %d time instants, expected %d", i, len(pdts[i]), len(expected[i]))
+		}
+		for tt := range expected[i] {
+			for k := range expected[i][tt] {
+				if pdts[i][tt][k] != expected[i][tt][k] {
+					t.Errorf("prediction [%d][%d][%d] %f != %f", i, tt, k, pdts[i][tt][k], expected[i][tt][k])
+				}
+			}
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	rnd := rand.New(rand.NewSource(27))
+	vectorSize := 3
+	x, y := copytask.GenSeq(3, vectorSize)
+	controllers := []Controller{
+		NewEmptyController1(vectorSize+2, vectorSize, 5, 2, 6, 3, WithWriteGate()),
+		NewEmptyController1Deep(vectorSize+2, vectorSize, []int{5, 4}, 1, 6, 3),
+	}
+	for _, c := range controllers {
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+		ForwardBackward(c, x, y)
+		if err := SetTrainable(c, GroupHeads, false); err != nil {
+			t.Fatalf("%v", err)
+		}
+		before := Snapshot(c)
+		grads := Gradients(c)
+
+		d := Clone(c)
+		if !d.(weightGrouper).frozenGroups()[GroupHeads] {
+			t.Errorf("%T: the heads of the clone are not frozen", c)
+		}
+		if err := SetTrainable(d, GroupHeads, true); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !c.(weightGrouper).frozenGroups()[GroupHeads] {
+			t.Errorf("%T: unfreezing the clone unfroze the original", c)
+		}
+		for i, g := range Gradients(d) {
+			if g != grads[i] {
+				t.Fatalf("%T: clone gradient %d %f != %f", c, i, g, grads[i])
+			}
+		}
+		pdts := Predictions(ForwardBackward(c, x, y))
+		clonePdts := Predictions(ForwardBackward(d, x, y))
+		for tt := range pdts {
+			for k := range pdts[tt] {
+				if clonePdts[tt][k] != pdts[tt][k] {
+					t.Fatalf("%T: clone prediction [%d][%d] %f != %f", c, tt, k, clonePdts[tt][k], pdts[tt][k])
+				}
+			}
+		}
+
+		d.Weights(func(u *Unit) { u.Val += 1 })
+		for i, v := range Snapshot(c) {
+			if v != before[i] {
+				t.Fatalf("%T: modifying the clone changed weight %d from %f to %f", c, i, before[i], v)
+			}
+		}
+	}
+}
+
+func benchmarkBatch(b *testing.B, run func(c Controller, batch [][2][][]float64)) {
+	rnd := rand.New(rand.NewSource(11))
+	vectorSize := 8
+	batch := make([][2][][]float64, 16)
+	for i := range batch {
+		x, y := copytask.GenSeq(10, vectorSize)
+		batch[i] = [2][][]float64{x, y}
+	}
+	c := NewEmptyController1(vectorSize+2, vectorSize, 100, 1, 128, 20)
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		run(c, batch)
+	}
+}
+
+func BenchmarkForwardBackwardBatch(b *testing.B) {
+	benchmarkBatch(b, func(c Controller, batch [][2][][]float64) { ForwardBackwardBatch(c, batch) })
+}
+
+func BenchmarkForwardBackwardSequential(b *testing.B) {
+	benchmarkBatch(b, func(c Controller, batch [][2][][]float64) {
+		c.Weights(func(u *Unit) { u.Grad = 0 })
+		for _, seq := range batch {
+			forwardBackward(c, seq[0], seq[1])
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package ntm
+
+import (
+	"math"
+)
+
+// A CalibrationBin summarizes the predictions of a NTM that fall into a range of probabilities.
+type CalibrationBin struct {
+	Lower, Upper   float64 // the range of predicted probabilities, including Upper only for the last bin
+	Count          int     // the number of predictions in the bin
+	MeanPrediction float64 // the mean predicted probability, NaN if the bin is empty
+	Accuracy       float64 // the mean of the ground truth outputs, which is the fraction of ones for bits, NaN if the bin is empty
+}
+
+// Calibration bins the predictions of a NTM, as returned by Predictions, into bins equally wide bins spanning [0, 1],
+// and compares the mean prediction of each bin with the ground truth outputs y.
+// For well calibrated predictions, the Accuracy of each bin is close to its MeanPrediction.
+func Calibration(y [][]float64, machines []*NTM, bins int) []CalibrationBin {
+	c := make([]CalibrationBin, bins)
+	for i := range c {
+		c[i].Lower = float64(i) / float64(bins)
+		c[i].Upper = float64(i+1) / float64(bins)
+	}
+	sums := make([]float64, bins)
+	for t, pdt := range Predictions(machines) {
+		for j, p := range pdt {
+			i := int(p * float64(bins))
+			if i >= bins {
+				i = bins - 1
+			}
+			if i < 0 {
+				i = 0
+			}
+			c[i].Count++
+			c[i].MeanPrediction += p
+			sums[i] += y[t][j]
+		}
+	}
+	for i := range c {
+		if c[i].Count == 0 {
+			c[i].MeanPrediction = math.NaN()
+			c[i].Accuracy = math.NaN()
+			continue
+		}
+		c[i].MeanPrediction /= float64(c[i].Count)
+		c[i].Accuracy = sums[i] / float64(c[i].Count)
+	}
+	return c
+}
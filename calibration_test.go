@@ -0,0 +1,51 @@
+package ntm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalibration(t *testing.T) {
+	// In every other bin, predict the bin center p for 20 outputs, exactly p*20 of which are one.
+	bins := 10
+	var y [][]float64
+	var machines []*NTM
+	for i := 0; i < bins; i += 2 {
+		p := (float64(i) + 0.5) / float64(bins)
+		ones := int(math.Round(p * 20))
+		yt := make([]float64, 20)
+		units := make([]Unit, 20)
+		for j := range yt {
+			if j < ones {
+				yt[j] = 1
+			}
+			units[j].Val = p
+		}
+		y = append(y, yt)
+		machines = append(machines, &NTM{Controller: &controller1{controllerCore: controllerCore{y: units}}})
+	}
+	cal := Calibration(y, machines, bins)
+	if len(cal) != bins {
+		t.Fatalf("%d bins, expected %d", len(cal), bins)
+	}
+	for i, b := range cal {
+		if b.Count == 0 {
+			if !math.IsNaN(b.MeanPrediction) || !math.IsNaN(b.Accuracy) {
+				t.Errorf("empty bin %d: %+v", i, b)
+			}
+			continue
+		}
+		if b.Count != 20 {
+			t.Errorf("bin %d has %d predictions", i, b.Count)
+		}
+		if b.MeanPrediction < b.Lower || b.MeanPrediction > b.Upper {
+			t.Errorf("bin %d mean prediction %f outside [%f, %f]", i, b.MeanPrediction, b.Lower, b.Upper)
+		}
+		if math.Abs(b.Accuracy-b.MeanPrediction) > 1e-12 {
+			t.Errorf("bin %d is not on the diagonal: accuracy %f, mean prediction %f", i, b.Accuracy, b.MeanPrediction)
+		}
+	}
+	if cal[bins-1].Upper != 1 {
+		t.Errorf("last bin ends at %f", cal[bins-1].Upper)
+	}
+}
@@ -0,0 +1,112 @@
+package ntm
+
+import (
+	"math"
+)
+
+// ForwardBackwardCheckpointed is similar to ForwardBackward, except that it keeps the state of the NTM only at about sqrt(T) checkpoints,
+// where T is the number of time instants, and recomputes the time instants between two checkpoints during the backward pass.
+// This bounds the history of the NTM held in memory to O(sqrt(T)) time instants, at the cost of running the forward pass twice.
+// The gradients are identical to those of ForwardBackward, except for controllers with dropout,
+// whose recomputed forward passes draw new dropout masks.
+//
+// As the history of the NTM is not kept, ForwardBackwardCheckpointed returns the predictions across time, see Predictions,
+// together with the loss in bits, see Loss.
+func ForwardBackwardCheckpointed(c Controller, in, out [][]float64) (pdts [][]float64, loss float64) {
+	if err := CheckDims(c, in, out); err != nil {
+		panic(err)
+	}
+	c.Weights(func(u *Unit) { u.Grad = 0 })
+	empty, cas := initialNTM(c)
+	reads := empty.memOp.R
+
+	// checkpoints[k] is the NTM before the time instant k*seg.
+	// Except for the first one, the checkpoints are detached from their history,
+	// so that only the time instants since the latest checkpoint are held in memory.
+	seg := int(math.Ceil(math.Sqrt(float64(len(in)))))
+	checkpoints := []*NTM{empty}
+	m := empty
+	for t := range in {
+		if t > 0 && t%seg == 0 {
+			m = m.detach()
+			// The controller of a time instant refers to the reads of the previous one, and thus to the whole history.
+			// Any controller sharing the weights of c advances the NTM alike.
+			m.Controller = c
+			checkpoints = append(checkpoints, m)
+		}
+		m = newNTM(m, in[t], nil)
+	}
+
+	pdts = make([][]float64, len(in))
+	losses := make([]float64, len(in))
+	var next *NTM // the checkpoint of the segment that follows, which holds the gradients flowing out of it
+	for k := len(checkpoints) - 1; k >= 0; k-- {
+		start := k * seg
+		end := start + seg
+		if end > len(in) {
+			end = len(in)
+		}
+		machines := make([]*NTM, end-start)
+		prev := checkpoints[k]
+		for t := start; t < end; t++ {
+			prev = newNTM(prev, in[t], nil)
+			machines[t-start] = prev
+		}
+		if next != nil {
+			addStateGrads(machines[len(machines)-1], next)
+		}
+
+		for t := end - 1; t >= start; t-- {
+			m := machines[t-start]
+			y := out[t]
+			for i := 0; i < len(y); i++ {
+				m.Controller.Y()[i].Grad = m.Controller.Y()[i].Val - y[i]
+			}
+			pdts[t] = unitVals(m.Controller.Y())
+			losses[t] = stepLoss(y, m.Controller)
+			m.backward()
+		}
+		next = checkpoints[k]
+	}
+
+	// Compute gradients for the bias values of the initial memory and weights.
+	for i := range reads {
+		reads[i].Backward()
+		for j := range reads[i].Ws[0].Top {
+			cas[i].Top[j].Grad += reads[i].Ws[0].Top[j].Grad
+		}
+		cas[i].Backward()
+	}
+
+	for _, l := range losses {
+		loss += l
+	}
+	return pdts, loss
+}
+
+// addStateGrads adds the gradients of the detached NTM d to those of the state of m that d is a copy of,
+// which are the gradients of the head weights, the reads and the memory.
+func addStateGrads(m, d *NTM) {
+	for i, w := range d.memOp.W {
+		for j, u := range w.Top {
+			m.memOp.W[i].Top[j].Grad += u.Grad
+		}
+	}
+	for i, rws := range d.memOp.RW {
+		for j, w := range rws {
+			for k, u := range w.Top {
+				m.memOp.RW[i][j].Top[k].Grad += u.Grad
+			}
+		}
+	}
+	for i, r := range d.memOp.R {
+		for j, u := range r.Top {
+			m.memOp.R[i].Top[j].Grad += u.Grad
+		}
+	}
+	for i, row := range d.memOp.WM.Top {
+		for j, u := range row {
+			m.memOp.WM.Top[i][j].Grad += u.Grad
+		}
+	}
+}
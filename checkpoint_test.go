@@ -0,0 +1,103 @@
+package ntm
+
+import (
+	"math/rand"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/fumin/ntm/copytask"
+)
+
+func TestForwardBackwardCheckpointed(t *testing.T) {
+	vectorSize := 3
+	controllers := []Controller{
+		NewEmptyController1(vectorSize+2, vectorSize, 5, 2, 6, 3, WithWriteGate()),
+		NewEmptyController1Deep(vectorSize+2, vectorSize, []int{5, 4}, 1, 6, 3),
+		NewEmptyController1(vectorSize+2, vectorSize, 5, 2, 6, 3, WithReadWeightings(2)),
+	}
+	for _, c := range controllers {
+		rnd := rand.New(rand.NewSource(32))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+		// Sequences of 12 and 10 time instants are split into segments of 4, the latter with a shorter last segment.
+		for _, size := range []int{5, 4, 1} {
+			x, y := copytask.GenSeq(size, vectorSize)
+			machines := ForwardBackward(c, x, y)
+			expectedPdts := Predictions(machines)
+			expectedLoss := Loss(y, machines)
+			expectedGrads := Gradients(c)
+
+			pdts, loss := ForwardBackwardCheckpointed(c, x, y)
+			if loss != expectedLoss {
+				t.Errorf("%T, size %d: loss %f, expected %f", c, size, loss, expectedLoss)
+			}
+			for tt := range expectedPdts {
+				for i := range expectedPdts[tt] {
+					if pdts[tt][i] != expectedPdts[tt][i] {
+						t.Fatalf("%T, size %d: prediction [%d][%d] %f != %f", c, size, tt, i, pdts[tt][i], expectedPdts[tt][i])
+					}
+				}
+			}
+			for i, g := range Gradients(c) {
+				if g != expectedGrads[i] {
+					t.Fatalf("%T, size %d: gradient %d %g != %g", c, size, i, g, expectedGrads[i])
+				}
+			}
+		}
+	}
+}
+
+// peakLiveHeap returns the largest live heap in bytes observed while f runs.
+// Garbage is collected aggressively so that the live heap is measured often.
+func peakLiveHeap(f func()) uint64 {
+	defer debug.SetGCPercent(debug.SetGCPercent(1))
+	runtime.GC()
+	sample := []metrics.Sample{{Name: "/gc/heap/live:bytes"}}
+	var peak uint64
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		for {
+			metrics.Read(sample)
+			if v := sample[0].Value.Uint64(); v > peak {
+				peak = v
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+	f()
+	close(done)
+	<-sampled
+	return peak
+}
+
+func benchmarkLongSequence(b *testing.B, run func(c Controller, x, y [][]float64)) {
+	rnd := rand.New(rand.NewSource(33))
+	vectorSize := 8
+	c := NewEmptyController1(vectorSize+2, vectorSize, 100, 1, 128, 20)
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(100, vectorSize)
+	var peak uint64
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if p := peakLiveHeap(func() { run(c, x, y) }); p > peak {
+			peak = p
+		}
+	}
+	b.ReportMetric(float64(peak)/(1<<20), "peak-MB")
+}
+
+func BenchmarkForwardBackwardLong(b *testing.B) {
+	benchmarkLongSequence(b, func(c Controller, x, y [][]float64) { ForwardBackward(c, x, y) })
+}
+
+func BenchmarkForwardBackwardCheckpointed(b *testing.B) {
+	benchmarkLongSequence(b, func(c Controller, x, y [][]float64) { ForwardBackwardCheckpointed(c, x, y) })
+}
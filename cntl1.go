@@ -5,153 +5,160 @@ import (
 )
 
 type controller1 struct {
-	wtm1s      [][]*betaSimilarity
-	mtm1       *writtenMemory
-	Wh1r       [][][]Unit
-	Wh1x       [][]Unit
-	Wh1b       []Unit
-	Wyh1       [][]Unit
-	Wuh1       [][][]Unit
-	numWeights int
+	controllerCore
+	Wh1r [][][]Unit
+	Wh1x [][]Unit
+	Wh1b []Unit
+	Wyh1 [][]Unit
+	Wuh1 [][][]Unit
 
-	Reads []*memRead
-	X     []float64
+	H1   []Unit
+	drop []float64 // the dropout scales of H1, nil if dropout is disabled
+}
 
-	H1 []Unit
+// A ControllerConfig holds the architecture of a controller.
+type ControllerConfig struct {
+	XSize           int // the size of the inputs
+	YSize           int // the size of the outputs
+	HiddenSize      int // the size of the hidden layer
+	NumHeads        int // the number of memory heads
+	MemoryLocations int // the number of memory locations, N in the NTM paper
+	MemoryWidth     int // the size of each memory location, M in the NTM paper
+	Options         []ControllerOption
+}
 
-	y     []Unit
-	heads []*Head
+// Validate returns an error describing the first invalid field of a ControllerConfig.
+func (cfg ControllerConfig) Validate() error {
+	fields := []struct {
+		name string
+		val  int
+	}{
+		{"XSize", cfg.XSize},
+		{"YSize", cfg.YSize},
+		{"HiddenSize", cfg.HiddenSize},
+		{"NumHeads", cfg.NumHeads},
+		{"MemoryLocations", cfg.MemoryLocations},
+		{"MemoryWidth", cfg.MemoryWidth},
+	}
+	for _, f := range fields {
+		if f.val <= 0 {
+			return fmt.Errorf("ntm: %s must be positive, got %d", f.name, f.val)
+		}
+	}
+	return newControllerConfig(cfg.Options).validate(cfg.MemoryLocations, cfg.MemoryWidth)
 }
 
-// NewEmptyController1 returns a new controller1 which is a single layer feedforward network.
+// NewController1 returns a new controller1 which is a single layer feedforward network of the given architecture.
 // The returned controller1 is empty in that all its network weights are initialized as 0.
-func NewEmptyController1(xSize, ySize, h1Size, numHeads, n, m int) *controller1 {
-	h := NewHead(m)
-	headUnitsSize := len(h.units)
-	c := controller1{
-		wtm1s: make([][]*betaSimilarity, numHeads),
-		mtm1:  &writtenMemory{Top: makeTensorUnit2(n, m)},
-		Wh1r:  makeTensorUnit3(h1Size, numHeads, m),
-		Wh1x:  makeTensorUnit2(h1Size, xSize),
-		Wh1b:  make([]Unit, h1Size),
-		Wyh1:  makeTensorUnit2(ySize, h1Size+1),
-		Wuh1:  makeTensorUnit3(numHeads, headUnitsSize, h1Size+1),
+func NewController1(cfg ControllerConfig) (Controller, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
-	for i := range c.wtm1s {
-		c.wtm1s[i] = make([]*betaSimilarity, n)
-		for j := range c.wtm1s[i] {
-			c.wtm1s[i][j] = &betaSimilarity{}
-		}
+	return newEmptyController1(cfg.XSize, cfg.YSize, cfg.HiddenSize, cfg.NumHeads, cfg.MemoryLocations, cfg.MemoryWidth, newControllerConfig(cfg.Options)), nil
+}
+
+// NewEmptyController1 is like NewController1 but takes the architecture as positional arguments.
+// It panics if the architecture is invalid.
+func NewEmptyController1(xSize, ySize, h1Size, numHeads, n, m int, opts ...ControllerOption) *controller1 {
+	c, err := NewController1(ControllerConfig{
+		XSize:           xSize,
+		YSize:           ySize,
+		HiddenSize:      h1Size,
+		NumHeads:        numHeads,
+		MemoryLocations: n,
+		MemoryWidth:     m,
+		Options:         opts,
+	})
+	if err != nil {
+		panic(err.Error())
 	}
-	c.numWeights = numHeads*n + n*m + h1Size*numHeads*m + h1Size*xSize + h1Size + ySize*(h1Size+1) + numHeads*headUnitsSize*(h1Size+1)
+	return c.(*controller1)
+}
+
+func newEmptyController1(xSize, ySize, h1Size, numHeads, n, m int, cfg controllerConfig) *controller1 {
+	c := controller1{
+		controllerCore: newControllerCore(numHeads, n, m, cfg),
+		Wh1r:           makeTensorUnit3(h1Size, cfg.numReadInputs(numHeads, m)/cfg.readWidth(m), cfg.readWidth(m)),
+		Wh1x:           makeTensorUnit2(h1Size, xSize),
+		Wh1b:           make([]Unit, h1Size),
+		Wyh1:           makeTensorUnit2(ySize, h1Size+1),
+		Wuh1:           cfg.makeHeadProjections(numHeads, m, h1Size+1),
+	}
+	c.numWeights += h1Size*cfg.numReadInputs(numHeads, m) + h1Size*xSize + h1Size + ySize*(h1Size+1) + cfg.numHeadProjections(numHeads, m)*(h1Size+1)
 	return &c
 }
 
-func (c *controller1) Heads() []*Head {
-	return c.heads
+// clone returns a new controller1 with the same architecture as c, whose weights are copies of those of c.
+func (c *controller1) clone() Controller {
+	d := newEmptyController1(c.inputSize(), c.outputSize(), len(c.Wh1b), c.NumHeads(), c.MemoryN(), c.MemoryM(), c.cfg.cloned())
+	copyWeights(d, c)
+	return d
 }
 
-func (c *controller1) Y() []Unit {
-	return c.y
+func (c *controller1) inputSize() int {
+	return len(c.Wh1x[0])
+}
+
+func (c *controller1) outputSize() int {
+	return len(c.Wyh1)
+}
+
+func (c *controller1) stepFLOPs() (dense, addressing int64) {
+	h1Size := len(c.Wh1b)
+	dense, addressing = c.outputFLOPs(c.Wyh1, c.NumHeads(), h1Size+1)
+	return dense + int64(h1Size*(c.cfg.numReadInputs(c.NumHeads(), c.MemoryM())+c.inputSize()+1)), addressing
+}
+
+func (c *controller1) outputBiases() []*Unit {
+	return biasUnits(c.Wyh1)
 }
 
 func (old *controller1) Forward(reads []*memRead, x []float64) Controller {
 	c := controller1{
-		Wh1r:       old.Wh1r,
-		Wh1x:       old.Wh1x,
-		Wh1b:       old.Wh1b,
-		Wyh1:       old.Wyh1,
-		Wuh1:       old.Wuh1,
-		numWeights: old.numWeights,
-		Reads:      reads,
-		X:          x,
-		H1:         make([]Unit, len(old.Wh1r)),
-		y:          make([]Unit, len(old.Wyh1)),
-		heads:      make([]*Head, len(reads)),
-	}
-
-	var v float64
-	for i, wh1ri := range c.Wh1r {
-		wh1xi := c.Wh1x[i]
-		v = 0
-		for j, wh1rij := range wh1ri {
-			read := reads[j]
-			for k, wh1rijk := range wh1rij {
-				v += wh1rijk.Val * read.Top[k].Val
-			}
-		}
-		for j, wh1xij := range wh1xi {
-			v += wh1xij.Val * x[j]
-		}
-		v += c.Wh1b[i].Val
-		c.H1[i].Val = Sigmoid(v)
+		controllerCore: old.step(reads, x, len(old.Wyh1)),
+		Wh1r:           old.Wh1r,
+		Wh1x:           old.Wh1x,
+		Wh1b:           old.Wh1b,
+		Wyh1:           old.Wyh1,
+		Wuh1:           old.Wuh1,
+		H1:             make([]Unit, len(old.Wh1r)),
 	}
 
-	for i, wyh1i := range c.Wyh1 {
-		v = 0
-		for j, wyh1ij := range wyh1i[0:len(c.H1)] {
-			v += wyh1ij.Val * c.H1[j].Val
-		}
-		v += c.Wyh1[i][len(c.H1)].Val
-		c.y[i].Val = Sigmoid(v)
-	}
-	memoryM := len(reads[0].Top)
-	for i, wuh1i := range c.Wuh1 {
-		c.heads[i] = NewHead(memoryM)
-		head := c.heads[i]
-		for j, wuh1ij := range wuh1i {
-			v = 0
-			for k, wuh1ijk := range wuh1ij[0:len(c.H1)] {
-				v += wuh1ijk.Val * c.H1[k].Val
+	h1 := make([]float64, len(c.Wh1r))
+	if len(c.Wh1r) > 0 {
+		rows := make([][]Unit, len(c.Wh1r))
+		for j := range c.Wh1r[0] {
+			for i, wh1ri := range c.Wh1r {
+				rows[i] = wh1ri[j]
 			}
-			v += wuh1ij[len(c.H1)].Val
-			head.units[j].Val += v
+			c.cfg.mulVecAdd(h1, rows, unitVals(reads[j].Top))
 		}
 	}
+	c.cfg.mulVecAdd(h1, c.Wh1x, x)
+	for i, v := range h1 {
+		c.H1[i].Val = Sigmoid(v + c.Wh1b[i].Val)
+	}
+	c.drop = c.cfg.dropoutScales(len(c.H1))
+	for i, s := range c.drop {
+		c.H1[i].Val *= s
+	}
 
+	c.forwardOutputs(c.Wyh1, c.Wuh1, unitVals(c.H1))
 	return &c
 }
 
 func (c *controller1) Backward() {
-	for j, y := range c.y {
-		for i, wyh1 := range c.Wyh1[j][0:len(c.H1)] {
-			c.H1[i].Grad += wyh1.Val * y.Grad
-		}
-	}
-	for j, head := range c.heads {
-		wuh1j := c.Wuh1[j]
-		for k, h := range head.units {
-			for i, wuh1jki := range wuh1j[k][0:len(c.H1)] {
-				c.H1[i].Grad += h.Grad * wuh1jki.Val
-			}
-		}
-	}
-	for i, wyh1i := range c.Wyh1 {
-		yGrad := c.y[i].Grad
-		for j, h1 := range c.H1 {
-			wyh1i[j].Grad += yGrad * h1.Val
-		}
-		wyh1i[len(wyh1i)-1].Grad += yGrad
-	}
-	for i, wuh1i := range c.Wuh1 {
-		for j, head := range c.heads[i].units {
-			wuh1ij := wuh1i[j]
-			for k, h1 := range c.H1 {
-				wuh1ij[k].Grad += head.Grad * h1.Val
-			}
-			wuh1ij[len(wuh1ij)-1].Grad += head.Grad
-		}
-	}
+	c.backwardOutputs(c.Wyh1, c.Wuh1, c.H1)
 
 	h1Grads := make([]float64, len(c.H1))
 	for i, h1 := range c.H1 {
-		h1Grads[i] = h1.Grad * h1.Val * (1 - h1.Val)
+		s, g := dropoutGrad(c.drop, i, h1.Val, h1.Grad)
+		h1Grads[i] = g * s * (1 - s)
 	}
 
 	for k, h1g := range h1Grads {
-		wh1rk := c.Wh1r[k]
-		for i, read := range c.Reads {
-			wh1rki := wh1rk[i]
+		for i, wh1rki := range c.Wh1r[k] {
+			read := c.Reads[i]
 			for j, wh1rkij := range wh1rki {
 				read.Top[j].Grad += h1g * wh1rkij.Val
 			}
@@ -176,15 +183,210 @@ func (c *controller1) Backward() {
 	}
 }
 
-func (c *controller1) Wtm1BiasV() [][]*betaSimilarity {
-	return c.wtm1s
+func (c *controller1) inputGrads() []float64 {
+	grads := make([]float64, len(c.X))
+	for i, h1 := range c.H1 {
+		s, g := dropoutGrad(c.drop, i, h1.Val, h1.Grad)
+		h1g := g * s * (1 - s)
+		for j, wh1xij := range c.Wh1x[i] {
+			grads[j] += h1g * wh1xij.Val
+		}
+	}
+	return grads
 }
 
-func (c *controller1) Mtm1BiasV() *writtenMemory {
-	return c.mtm1
+func (c *controller1) Weights(f func(*Unit)) {
+	c.memoryInitWeights(f)
+	doUnit2(c.Wyh1, func(ids []int, u *Unit) { f(u) })
+	c.cfg.doHeadWeights(c.Wuh1, c.MemoryM(), func(ids []int, u *Unit) { f(u) })
+	doUnit3(c.Wh1r, func(ids []int, u *Unit) { f(u) })
+	doUnit2(c.Wh1x, func(ids []int, u *Unit) { f(u) })
+	doUnit1(c.Wh1b, func(ids []int, u *Unit) { f(u) })
+	if c.temperature != nil {
+		f(c.temperature)
+	}
 }
 
-func (c *controller1) Weights(f func(*Unit)) {
+// WeightsVerbose is similar to Weights, but with additional information passed in.
+// Avoid using this function except for debugging, as it calls fmt.Sprintf many times which is a performance hog.
+func (c *controller1) WeightsVerbose(f func(string, *Unit)) {
+	c.memoryInitWeightsVerbose(f)
+	tagify := func(tag string, ids []int) string {
+		s := tag
+		for i := len(ids) - 1; i >= 0; i-- {
+			s = fmt.Sprintf("%s[%d]", s, ids[i])
+		}
+		return s
+	}
+	doUnit2(c.Wyh1, func(ids []int, u *Unit) { f(tagify("Wyh1", ids), u) })
+	c.cfg.doHeadWeights(c.Wuh1, c.MemoryM(), func(ids []int, u *Unit) { f(tagify("Wuh1", ids), u) })
+	doUnit3(c.Wh1r, func(ids []int, u *Unit) { f(tagify("Wh1r", ids), u) })
+	doUnit2(c.Wh1x, func(ids []int, u *Unit) { f(tagify("Wh1x", ids), u) })
+	doUnit1(c.Wh1b, func(ids []int, u *Unit) { f(tagify("Wh1b", ids), u) })
+	if c.temperature != nil {
+		f("temperature[0]", c.temperature)
+	}
+}
+
+func (c *controller1) weightGroup(group string, f func(*Unit)) {
+	switch group {
+	case GroupController:
+		doUnit2(c.Wyh1, func(ids []int, u *Unit) { f(u) })
+		doUnit3(c.Wh1r, func(ids []int, u *Unit) { f(u) })
+		doUnit2(c.Wh1x, func(ids []int, u *Unit) { f(u) })
+		doUnit1(c.Wh1b, func(ids []int, u *Unit) { f(u) })
+		if c.temperature != nil {
+			f(c.temperature)
+		}
+	case GroupHeads:
+		c.cfg.doHeadWeights(c.Wuh1, c.MemoryM(), func(ids []int, u *Unit) { f(u) })
+	case GroupMemoryInit:
+		c.memoryInitWeights(f)
+	}
+}
+
+func (c *controller1) NumHeads() int {
+	return len(c.Wuh1)
+}
+
+// A controllerCore holds what the feedforward controllers share apart from their hidden layers:
+// the initial memory and addressing weights, the optional features,
+// and the outputs and memory heads projected from the last hidden layer.
+type controllerCore struct {
+	wtm1s       [][]*betaSimilarity
+	mtm1        *writtenMemory
+	temperature *Unit // the log of the temperature dividing the outputs before activation, nil without WithOutputTemperature
+	numWeights  int
+	cfg         controllerConfig
+	frozen      map[string]bool
+	pruned      map[int]bool
+
+	Reads []*memRead
+	X     []float64
+
+	y      []Unit
+	logits []float64 // the outputs before activation
+	heads  []*Head
+}
+
+// newControllerCore returns the core of an empty controller with numHeads memory heads and a memory of n locations of size m.
+// Its numWeights counts the initial memory, the initial addressing weights and the temperature,
+// to which a controller adds the weights of its layers.
+func newControllerCore(numHeads, n, m int, cfg controllerConfig) controllerCore {
+	if err := cfg.validate(n, m); err != nil {
+		panic(err.Error())
+	}
+	c := controllerCore{
+		wtm1s:      make([][]*betaSimilarity, numHeads),
+		mtm1:       &writtenMemory{},
+		numWeights: numHeads*n + n*m,
+		cfg:        cfg,
+	}
+	c.mtm1.data, c.mtm1.Top = makeFlatTensorUnit2(n, m)
+	if cfg.temperature {
+		c.temperature = &Unit{}
+		c.numWeights++
+	}
+	for i := range c.wtm1s {
+		c.wtm1s[i] = make([]*betaSimilarity, n)
+		for j := range c.wtm1s[i] {
+			c.wtm1s[i][j] = &betaSimilarity{}
+		}
+	}
+	return c
+}
+
+// step returns the core of a controller at a time instant, which has ySize outputs and
+// shares the temperature and the options of c.
+func (c *controllerCore) step(reads []*memRead, x []float64, ySize int) controllerCore {
+	return controllerCore{
+		numWeights:  c.numWeights,
+		cfg:         c.cfg,
+		temperature: c.temperature,
+		Reads:       reads,
+		X:           x,
+		y:           make([]Unit, ySize),
+		heads:       make([]*Head, len(reads)),
+	}
+}
+
+// forwardOutputs computes the outputs and the memory heads from the last hidden layer h,
+// where wy and wu are the weights projecting h onto the outputs and the units of each head,
+// and the last column of the weights is the bias.
+func (c *controllerCore) forwardOutputs(wy [][]Unit, wu [][][]Unit, h []float64) {
+	y := make([]float64, len(wy))
+	c.cfg.mulVecAdd(y, wy, h)
+	for i, v := range y {
+		c.y[i].Val = v + wy[i][len(h)].Val
+	}
+	divideByTemperature(c.y, c.temperature)
+	c.logits = unitVals(c.y)
+	c.cfg.activateOutputs(c.y)
+	memoryM := len(c.Reads[0].Top) / c.cfg.head.numWeightings()
+	for i, wui := range wu {
+		c.heads[i] = newHead(memoryM, c.cfg.headConfig(i))
+		head := c.heads[i]
+		u := make([]float64, len(wui))
+		c.cfg.mulVecAdd(u, wui, h)
+		for j, wuij := range wui {
+			head.units[j].Val += u[j] + wuij[len(h)].Val
+		}
+	}
+}
+
+// backwardOutputs backpropagates the gradients of the outputs and the memory heads to the last hidden layer h
+// and to the weights wy and wu, see forwardOutputs.
+func (c *controllerCore) backwardOutputs(wy [][]Unit, wu [][][]Unit, h []Unit) {
+	yGrads := temperatureGrads(c.y, c.logits, c.temperature)
+	for j, yGrad := range yGrads {
+		for i, wyj := range wy[j][0:len(h)] {
+			h[i].Grad += wyj.Val * yGrad
+		}
+	}
+	for j, head := range c.heads {
+		wuj := wu[j]
+		for k, u := range head.units {
+			for i, wujki := range wuj[k][0:len(h)] {
+				h[i].Grad += u.Grad * wujki.Val
+			}
+		}
+	}
+	for i, wyi := range wy {
+		yGrad := yGrads[i]
+		for j, hj := range h {
+			wyi[j].Grad += yGrad * hj.Val
+		}
+		wyi[len(wyi)-1].Grad += yGrad
+	}
+	for i, wui := range wu {
+		for j, head := range c.heads[i].units {
+			wuij := wui[j]
+			for k, hk := range h {
+				wuij[k].Grad += head.Grad * hk.Val
+			}
+			wuij[len(wuij)-1].Grad += head.Grad
+		}
+	}
+}
+
+// outputFLOPs returns the FLOPs of projecting a last hidden layer of cols-1 units and a bias
+// onto the outputs wy and numHeads memory heads, and of the memory addressing of a time instant.
+func (c *controllerCore) outputFLOPs(wy [][]Unit, numHeads, cols int) (dense, addressing int64) {
+	dense = int64(len(wy)*cols) + c.cfg.headFLOPs(numHeads, c.MemoryM(), cols)
+	return dense, c.cfg.addressingFLOPs(numHeads, c.MemoryN(), c.MemoryM())
+}
+
+// biasUnits returns the last column of w, which holds the biases of its rows.
+func biasUnits(w [][]Unit) []*Unit {
+	biases := make([]*Unit, len(w))
+	for i, wi := range w {
+		biases[i] = &wi[len(wi)-1]
+	}
+	return biases
+}
+
+// memoryInitWeights calls f on the initial addressing weights and the initial memory.
+func (c *controllerCore) memoryInitWeights(f func(*Unit)) {
 	for _, wtm1 := range c.wtm1s {
 		for _, w := range wtm1 {
 			f(&w.Top)
@@ -195,16 +397,10 @@ func (c *controller1) Weights(f func(*Unit)) {
 			f(&row[i])
 		}
 	}
-	doUnit2(c.Wyh1, func(ids []int, u *Unit) { f(u) })
-	doUnit3(c.Wuh1, func(ids []int, u *Unit) { f(u) })
-	doUnit3(c.Wh1r, func(ids []int, u *Unit) { f(u) })
-	doUnit2(c.Wh1x, func(ids []int, u *Unit) { f(u) })
-	doUnit1(c.Wh1b, func(ids []int, u *Unit) { f(u) })
 }
 
-// WeightsVerbose is similar to Weights, but with additional information passed in.
-// Avoid using this function except for debugging, as it calls fmt.Sprintf many times which is a performance hog.
-func (c *controller1) WeightsVerbose(f func(string, *Unit)) {
+// memoryInitWeightsVerbose is similar to memoryInitWeights, but with the names of the weights passed in.
+func (c *controllerCore) memoryInitWeightsVerbose(f func(string, *Unit)) {
 	for i, wtm1 := range c.wtm1s {
 		for j, w := range wtm1 {
 			f(fmt.Sprintf("wtm1[%d][%d]", i, j), &w.Top)
@@ -215,32 +411,62 @@ func (c *controller1) WeightsVerbose(f func(string, *Unit)) {
 			f(fmt.Sprintf("mtm1[%d][%d]", i, j), &row[j])
 		}
 	}
-	tagify := func(tag string, ids []int) string {
-		s := tag
-		for i := len(ids) - 1; i >= 0; i-- {
-			s = fmt.Sprintf("%s[%d]", s, ids[i])
-		}
-		return s
+}
+
+func (c *controllerCore) outputMode() OutputMode {
+	return c.cfg.output
+}
+
+func (c *controllerCore) Heads() []*Head {
+	return c.heads
+}
+
+func (c *controllerCore) Y() []Unit {
+	return c.y
+}
+
+func (c *controllerCore) Wtm1BiasV() [][]*betaSimilarity {
+	return c.wtm1s
+}
+
+func (c *controllerCore) Mtm1BiasV() *writtenMemory {
+	return c.mtm1
+}
+
+func (c *controllerCore) numReadWeightings() int {
+	return c.cfg.head.numWeightings()
+}
+
+func (c *controllerCore) dropoutConfig() *dropoutConfig {
+	return c.cfg.dropout
+}
+
+func (c *controllerCore) frozenGroups() map[string]bool {
+	if c.frozen == nil {
+		c.frozen = make(map[string]bool)
 	}
-	doUnit2(c.Wyh1, func(ids []int, u *Unit) { f(tagify("Wyh1", ids), u) })
-	doUnit3(c.Wuh1, func(ids []int, u *Unit) { f(tagify("Wuh1", ids), u) })
-	doUnit3(c.Wh1r, func(ids []int, u *Unit) { f(tagify("Wh1r", ids), u) })
-	doUnit2(c.Wh1x, func(ids []int, u *Unit) { f(tagify("Wh1x", ids), u) })
-	doUnit1(c.Wh1b, func(ids []int, u *Unit) { f(tagify("Wh1b", ids), u) })
+	return c.frozen
 }
 
-func (c *controller1) NumWeights() int {
-	return c.numWeights
+func (c *controllerCore) prunedWeights() map[int]bool {
+	if c.pruned == nil {
+		c.pruned = make(map[int]bool)
+	}
+	return c.pruned
 }
 
-func (c *controller1) NumHeads() int {
-	return len(c.Wuh1)
+func (c *controllerCore) NumWeights() int {
+	return c.numWeights
 }
 
-func (c *controller1) MemoryN() int {
+func (c *controllerCore) MemoryN() int {
 	return len(c.mtm1.Top)
 }
 
-func (c *controller1) MemoryM() int {
-	return len(c.Wh1r[0][0])
+func (c *controllerCore) MemoryM() int {
+	return len(c.mtm1.Top[0])
+}
+
+func (c *controllerCore) outputLogits() []float64 {
+	return c.logits
 }
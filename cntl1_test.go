@@ -164,3 +164,294 @@ func transformWSFloat64(wsFloat64 [][]float64) []*refocus {
 	}
 	return wtm1s
 }
+
+func TestController1ReadFeedback(t *testing.T) {
+	xSize, ySize, h1Size, numHeads, n, m := 3, 2, 4, 2, 5, 3
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}, {1, 1, 0}}
+	y := [][]float64{{0, 1}, {1, 0}, {1, 1}}
+	readGrad := func(c Controller) float64 {
+		rnd := rand.New(rand.NewSource(12))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+		machines := ForwardBackward(c, x, y)
+		var g float64 = 0
+		for _, m := range machines[1:] {
+			for _, r := range m.Controller.(*controller1).Reads {
+				for _, u := range r.Top {
+					g += math.Abs(u.Grad)
+				}
+			}
+		}
+		return g
+	}
+
+	fed := NewEmptyController1(xSize, ySize, h1Size, numHeads, n, m)
+	unfed := NewEmptyController1(xSize, ySize, h1Size, numHeads, n, m, WithReadFeedback(false))
+	if len(fed.Wh1r[0])*len(fed.Wh1r[0][0]) != numHeads*m || len(unfed.Wh1r[0]) != 0 {
+		t.Errorf("wrong read input widths %d %d", len(fed.Wh1r[0]), len(unfed.Wh1r[0]))
+	}
+	if explicit := NewEmptyController1(xSize, ySize, h1Size, numHeads, n, m, WithReadFeedback(true)); explicit.NumWeights() != fed.NumWeights() {
+		t.Errorf("%d weights with explicit read feedback, expected %d", explicit.NumWeights(), fed.NumWeights())
+	}
+	if d := fed.NumWeights() - unfed.NumWeights(); d != h1Size*numHeads*m {
+		t.Errorf("read feedback adds %d weights, expected %d", d, h1Size*numHeads*m)
+	}
+	if g := readGrad(fed); g == 0 {
+		t.Errorf("zero read gradients with read feedback")
+	}
+	if g := readGrad(unfed); g != 0 {
+		t.Errorf("nonzero read gradients %f without read feedback", g)
+	}
+	if unfed.MemoryM() != m {
+		t.Errorf("memory size %d, expected %d", unfed.MemoryM(), m)
+	}
+
+	deep := NewEmptyController1Deep(xSize, ySize, []int{4}, numHeads, n, m)
+	unfedDeep := NewEmptyController1Deep(xSize, ySize, []int{4}, numHeads, n, m, WithReadFeedback(false))
+	if d := deep.NumWeights() - unfedDeep.NumWeights(); d != 4*numHeads*m {
+		t.Errorf("read feedback adds %d weights to the deep controller, expected %d", d, 4*numHeads*m)
+	}
+	ForwardBackward(unfedDeep, x, y)
+}
+
+func TestController1TiedEraseAdd(t *testing.T) {
+	xSize, ySize, h1Size, numHeads, n, m := 3, 2, 4, 2, 5, 3
+	untied := NewEmptyController1(xSize, ySize, h1Size, numHeads, n, m)
+	tied := NewEmptyController1(xSize, ySize, h1Size, numHeads, n, m, WithTiedEraseAdd())
+	if d := untied.NumWeights() - tied.NumWeights(); d != numHeads*m*(h1Size+1) {
+		t.Fatalf("tying removes %d weights, expected %d", d, numHeads*m*(h1Size+1))
+	}
+	numWeights := 0
+	tied.Weights(func(u *Unit) { numWeights++ })
+	if numWeights != tied.NumWeights() {
+		t.Fatalf("Weights enumerates %d weights, expected %d", numWeights, tied.NumWeights())
+	}
+
+	// An untied controller whose erase and add projections are equal computes the same function,
+	// and the gradient of a tied weight is the sum of the gradients of its erase and add counterparts.
+	rnd := rand.New(rand.NewSource(13))
+	tied.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	tiedUnits := make(map[string]*Unit)
+	tied.WeightsVerbose(func(tag string, u *Unit) { tiedUnits[tag] = u })
+	untied.WeightsVerbose(func(tag string, u *Unit) {
+		if tu, ok := tiedUnits[tag]; ok {
+			u.Val = tu.Val
+		}
+	})
+	for i := range untied.Wuh1 {
+		for j := 0; j < m; j++ {
+			copy(untied.Wuh1[i][m+j], untied.Wuh1[i][j])
+		}
+	}
+
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}, {1, 1, 0}}
+	y := [][]float64{{0, 1}, {1, 0}, {1, 1}}
+	ForwardBackward(tied, x, y)
+	ForwardBackward(untied, x, y)
+	for i := range tied.Wuh1 {
+		for j := 0; j < m; j++ {
+			for k := range tied.Wuh1[i][j] {
+				expected := untied.Wuh1[i][j][k].Grad + untied.Wuh1[i][m+j][k].Grad
+				if math.Abs(tied.Wuh1[i][j][k].Grad-expected) > 1e-12 {
+					t.Errorf("Wuh1[%d][%d][%d] grad %f, expected %f", i, j, k, tied.Wuh1[i][j][k].Grad, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestController1SharedHeadWeights(t *testing.T) {
+	xSize, ySize, h1Size, numHeads, n, m := 3, 2, 4, 3, 5, 3
+	unshared := NewEmptyController1(xSize, ySize, h1Size, numHeads, n, m)
+	shared := NewEmptyController1(xSize, ySize, h1Size, numHeads, n, m, WithSharedHeadWeights())
+	numUnits := len(shared.Wuh1[0])
+	if d := unshared.NumWeights() - shared.NumWeights(); d != (numHeads-1)*numUnits*(h1Size+1) {
+		t.Fatalf("sharing removes %d weights, expected %d", d, (numHeads-1)*numUnits*(h1Size+1))
+	}
+	numWeights := 0
+	shared.Weights(func(u *Unit) { numWeights++ })
+	if numWeights != shared.NumWeights() {
+		t.Fatalf("Weights enumerates %d weights, expected %d", numWeights, shared.NumWeights())
+	}
+
+	// An unshared controller whose head projections are equal computes the same function,
+	// and the gradient of a shared weight is the sum of the gradients of its counterparts in every head.
+	rnd := rand.New(rand.NewSource(14))
+	shared.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	sharedUnits := make(map[string]*Unit)
+	shared.WeightsVerbose(func(tag string, u *Unit) { sharedUnits[tag] = u })
+	unshared.WeightsVerbose(func(tag string, u *Unit) {
+		if su, ok := sharedUnits[tag]; ok {
+			u.Val = su.Val
+		}
+	})
+	for i := 1; i < numHeads; i++ {
+		for j := range unshared.Wuh1[i] {
+			copy(unshared.Wuh1[i][j], unshared.Wuh1[0][j])
+		}
+	}
+
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}, {1, 1, 0}}
+	y := [][]float64{{0, 1}, {1, 0}, {1, 1}}
+	ForwardBackward(shared, x, y)
+	ForwardBackward(unshared, x, y)
+	nonzero := 0
+	for j := range shared.Wuh1[0] {
+		for k, u := range shared.Wuh1[0][j] {
+			var expected float64 = 0
+			for i := range unshared.Wuh1 {
+				expected += unshared.Wuh1[i][j][k].Grad
+			}
+			if math.Abs(u.Grad-expected) > 1e-12 {
+				t.Errorf("Wuh1[0][%d][%d] grad %f, expected %f", j, k, u.Grad, expected)
+			}
+			if u.Grad != 0 {
+				nonzero++
+			}
+		}
+	}
+	if nonzero == 0 {
+		t.Errorf("all shared gradients are zero")
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("no panic for heads of different addressing modes")
+		}
+	}()
+	NewEmptyController1(xSize, ySize, h1Size, 2, n, m, WithSharedHeadWeights(), WithAddressingModes(ContentAndLocation, ContentOnly))
+}
+
+func TestController1SoftmaxOutput(t *testing.T) {
+	xSize, ySize, h1Size, numHeads, n, m := 3, 4, 4, 1, 5, 2
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}, {1, 1, 0}}
+	y := [][]float64{{0, 1, 0, 0}, {0, 0, 0, 1}, {1, 0, 0, 0}}
+	controllers := []Controller{
+		NewEmptyController1(xSize, ySize, h1Size, numHeads, n, m, WithOutputMode(SoftmaxOutput)),
+		NewEmptyController1Deep(xSize, ySize, []int{4, 3}, numHeads, n, m, WithOutputMode(SoftmaxOutput)),
+	}
+	for _, c := range controllers {
+		rnd := rand.New(rand.NewSource(15))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+
+		machines := ForwardBackward(c, x, y)
+		for tt, p := range Predictions(machines) {
+			var sum float64 = 0
+			for _, v := range p {
+				sum += v
+			}
+			if math.Abs(sum-1) > 1e-12 {
+				t.Errorf("%T: predictions at time %d sum to %f", c, tt, sum)
+			}
+		}
+
+		// Check the gradients against finite differences of the categorical cross-entropy in nats.
+		grads := make([]float64, 0, c.NumWeights())
+		c.Weights(func(u *Unit) { grads = append(grads, u.Grad) })
+		loss := func() float64 { return Loss(y, ForwardBackward(c, x, y)) * math.Ln2 }
+		i := 0
+		c.Weights(func(u *Unit) {
+			v := u.Val
+			h := 1e-6
+			u.Val = v + h
+			lph := loss()
+			u.Val = v - h
+			lmh := loss()
+			u.Val = v
+			grad := (lph - lmh) / (2 * h)
+			if math.IsNaN(grad) || math.Abs(grad-grads[i]) > 1e-6 {
+				t.Errorf("%T: weight %d gradient expected %f, got %f", c, i, grad, grads[i])
+			}
+			i++
+		})
+	}
+}
+
+func TestController1OutputTemperature(t *testing.T) {
+	xSize, ySize, h1Size, numHeads, n, m := 4, 2, 3, 1, 4, 3
+	x := [][]float64{{1, 0, 1, 0}, {0, 1, 0, 0}, {1, 1, 0, 1}}
+	y := [][]float64{{0, 1}, {1, 1}, {1, 0}}
+	controllers := [][2]Controller{
+		{
+			NewEmptyController1(xSize, ySize, h1Size, numHeads, n, m),
+			NewEmptyController1(xSize, ySize, h1Size, numHeads, n, m, WithOutputTemperature()),
+		},
+		{
+			NewEmptyController1Deep(xSize, ySize, []int{4, 3}, numHeads, n, m),
+			NewEmptyController1Deep(xSize, ySize, []int{4, 3}, numHeads, n, m, WithOutputTemperature()),
+		},
+	}
+	for _, pair := range controllers {
+		c, tc := pair[0], pair[1]
+		if tc.NumWeights() != c.NumWeights()+1 {
+			t.Fatalf("%T: %d weights with a temperature, expected %d", tc, tc.NumWeights(), c.NumWeights()+1)
+		}
+		rnd := rand.New(rand.NewSource(46))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+		ws := append(Snapshot(c), 0)
+		i := 0
+		tc.Weights(func(u *Unit) {
+			u.Val = ws[i]
+			i++
+		})
+
+		// A temperature of 1 leaves the predictions unchanged.
+		expected := Predictions(ForwardBackward(c, x, y))
+		for tt, p := range Predictions(ForwardBackward(tc, x, y)) {
+			for j := range p {
+				if p[j] != expected[tt][j] {
+					t.Errorf("%T: prediction [%d][%d] %f != %f", tc, tt, j, p[j], expected[tt][j])
+				}
+			}
+		}
+
+		var temperature *Unit
+		tc.Weights(func(u *Unit) { temperature = u })
+		temperature.Val = 0.4
+		checks := CheckGradients(tc, x, y)
+		for i, g := range checks {
+			if g.Error() > 1e-5 {
+				t.Errorf("%T: weight %d: analytic gradient %f, numeric %f", tc, i, g.Analytic, g.Numeric)
+			}
+		}
+		if g := checks[len(checks)-1]; g.Analytic == 0 {
+			t.Errorf("%T: the temperature has no gradient", tc)
+		}
+	}
+}
+
+func TestNewController1(t *testing.T) {
+	cfg := ControllerConfig{XSize: 3, YSize: 2, HiddenSize: 4, NumHeads: 2, MemoryLocations: 5, MemoryWidth: 3, Options: []ControllerOption{WithWriteGate()}}
+	c, err := NewController1(cfg)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := NewEmptyController1(3, 2, 4, 2, 5, 3, WithWriteGate())
+	if c.NumWeights() != expected.NumWeights() || c.MemoryN() != 5 || c.MemoryM() != 3 || c.NumHeads() != 2 {
+		t.Errorf("wrong architecture %d %d %d %d", c.NumWeights(), c.MemoryN(), c.MemoryM(), c.NumHeads())
+	}
+
+	tests := []struct {
+		modify func(*ControllerConfig)
+		err    string
+	}{
+		{func(c *ControllerConfig) { c.XSize = 0 }, "ntm: XSize must be positive, got 0"},
+		{func(c *ControllerConfig) { c.HiddenSize = -1 }, "ntm: HiddenSize must be positive, got -1"},
+		{func(c *ControllerConfig) { c.MemoryLocations = 0 }, "ntm: MemoryLocations must be positive, got 0"},
+		{func(c *ControllerConfig) { c.MemoryWidth = 0 }, "ntm: MemoryWidth must be positive, got 0"},
+		{func(c *ControllerConfig) { c.Options = []ControllerOption{WithMaxShift(5)} }, "ntm: maximum shift 5 must be less than the 5 memory locations"},
+		{func(c *ControllerConfig) { c.Options = []ControllerOption{WithReadOnlyLocation(5)} }, "ntm: read-only memory location 5 out of range [0, 5)"},
+		{func(c *ControllerConfig) { c.Options = []ControllerOption{WithKeyWidth(0)} }, "ntm: key width 0 out of range [1, 3]"},
+		{func(c *ControllerConfig) { c.Options = []ControllerOption{WithKeyWidth(4)} }, "ntm: key width 4 out of range [1, 3]"},
+	}
+	for _, test := range tests {
+		invalid := cfg
+		test.modify(&invalid)
+		c, err := NewController1(invalid)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("got error %v, expected %s", err, test.err)
+		}
+		if c != nil {
+			t.Errorf("got controller for invalid config %+v", invalid)
+		}
+	}
+}
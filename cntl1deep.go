@@ -0,0 +1,220 @@
+package ntm
+
+import (
+	"fmt"
+)
+
+type controller1Deep struct {
+	controllerCore
+	Wh  [][][]Unit // Wh[l] are the weights of the l-th hidden layer, the last column of which is the bias
+	Wyh [][]Unit
+	Wuh [][][]Unit
+
+	H    [][]Unit
+	drop [][]float64 // drop[l] are the dropout scales of H[l], nil if dropout is disabled
+}
+
+// NewEmptyController1Deep returns a new controller1Deep which is a feedforward network with hidden layers of sizes h1Sizes.
+// The inputs of the first hidden layer are the memory reads and x, unless disabled with WithReadFeedback,
+// and all hidden layers use tanh activations.
+// The returned controller1Deep is empty in that all its network weights are initialized as 0.
+// It panics if h1Sizes is empty or has a size that is not positive.
+func NewEmptyController1Deep(xSize, ySize int, h1Sizes []int, numHeads, n, m int, opts ...ControllerOption) *controller1Deep {
+	return newEmptyController1Deep(xSize, ySize, h1Sizes, numHeads, n, m, newControllerConfig(opts))
+}
+
+func newEmptyController1Deep(xSize, ySize int, h1Sizes []int, numHeads, n, m int, cfg controllerConfig) *controller1Deep {
+	if len(h1Sizes) == 0 {
+		panic("ntm: a controller1Deep needs at least one hidden layer")
+	}
+	for l, size := range h1Sizes {
+		if size <= 0 {
+			panic(fmt.Sprintf("ntm: hidden layer %d has a non-positive size %d", l, size))
+		}
+	}
+	last := h1Sizes[len(h1Sizes)-1]
+	c := controller1Deep{
+		controllerCore: newControllerCore(numHeads, n, m, cfg),
+		Wh:             make([][][]Unit, len(h1Sizes)),
+		Wyh:            makeTensorUnit2(ySize, last+1),
+		Wuh:            cfg.makeHeadProjections(numHeads, m, last+1),
+	}
+	inSize := cfg.numReadInputs(numHeads, m) + xSize
+	c.numWeights += ySize*(last+1) + cfg.numHeadProjections(numHeads, m)*(last+1)
+	for l, size := range h1Sizes {
+		c.Wh[l] = makeTensorUnit2(size, inSize+1)
+		c.numWeights += size * (inSize + 1)
+		inSize = size
+	}
+	return &c
+}
+
+// clone returns a new controller1Deep with the same architecture as c, whose weights are copies of those of c.
+func (c *controller1Deep) clone() Controller {
+	h1Sizes := make([]int, len(c.Wh))
+	for l, w := range c.Wh {
+		h1Sizes[l] = len(w)
+	}
+	d := newEmptyController1Deep(c.inputSize(), c.outputSize(), h1Sizes, c.NumHeads(), c.MemoryN(), c.MemoryM(), c.cfg.cloned())
+	copyWeights(d, c)
+	return d
+}
+
+func (c *controller1Deep) inputSize() int {
+	return len(c.Wh[0][0]) - 1 - c.cfg.numReadInputs(c.NumHeads(), c.MemoryM())
+}
+
+func (c *controller1Deep) outputSize() int {
+	return len(c.Wyh)
+}
+
+func (c *controller1Deep) stepFLOPs() (dense, addressing int64) {
+	dense, addressing = c.outputFLOPs(c.Wyh, c.NumHeads(), len(c.Wh[len(c.Wh)-1])+1)
+	for _, w := range c.Wh {
+		dense += int64(len(w) * len(w[0]))
+	}
+	return dense, addressing
+}
+
+func (c *controller1Deep) outputBiases() []*Unit {
+	return biasUnits(c.Wyh)
+}
+
+func (old *controller1Deep) Forward(reads []*memRead, x []float64) Controller {
+	c := controller1Deep{
+		controllerCore: old.step(reads, x, len(old.Wyh)),
+		Wh:             old.Wh,
+		Wyh:            old.Wyh,
+		Wuh:            old.Wuh,
+		H:              make([][]Unit, len(old.Wh)),
+		drop:           make([][]float64, len(old.Wh)),
+	}
+
+	in := make([]float64, 0, len(c.Wh[0][0])-1)
+	for _, read := range c.fedReads() {
+		in = append(in, unitVals(read.Top)...)
+	}
+	in = append(in, x...)
+	for l, whl := range c.Wh {
+		c.H[l] = make([]Unit, len(whl))
+		v := make([]float64, len(whl))
+		c.cfg.mulVecAdd(v, whl, in)
+		for i, whli := range whl {
+			c.H[l][i].Val = Tanh(v[i] + whli[len(in)].Val)
+		}
+		c.drop[l] = c.cfg.dropoutScales(len(c.H[l]))
+		for i, s := range c.drop[l] {
+			c.H[l][i].Val *= s
+		}
+		in = unitVals(c.H[l])
+	}
+	c.forwardOutputs(c.Wyh, c.Wuh, in)
+	return &c
+}
+
+func (c *controller1Deep) Backward() {
+	c.backwardOutputs(c.Wyh, c.Wuh, c.H[len(c.H)-1])
+
+	for l := len(c.Wh) - 1; l >= 0; l-- {
+		whl := c.Wh[l]
+		for i, hli := range c.H[l] {
+			s, g := dropoutGrad(c.drop[l], i, hli.Val, hli.Grad)
+			hg := g * (1 - s*s)
+			whli := whl[i]
+			j := 0
+			if l == 0 {
+				for _, read := range c.fedReads() {
+					for k := range read.Top {
+						read.Top[k].Grad += hg * whli[j].Val
+						whli[j].Grad += hg * read.Top[k].Val
+						j++
+					}
+				}
+				for _, xk := range c.X {
+					whli[j].Grad += hg * xk
+					j++
+				}
+			} else {
+				for k := range c.H[l-1] {
+					c.H[l-1][k].Grad += hg * whli[j].Val
+					whli[j].Grad += hg * c.H[l-1][k].Val
+					j++
+				}
+			}
+			whli[j].Grad += hg
+		}
+	}
+}
+
+func (c *controller1Deep) inputGrads() []float64 {
+	// The inputs come after the fed reads in the weights of the first hidden layer.
+	offset := 0
+	for _, read := range c.fedReads() {
+		offset += len(read.Top)
+	}
+	grads := make([]float64, len(c.X))
+	for i, h0i := range c.H[0] {
+		s, g := dropoutGrad(c.drop[0], i, h0i.Val, h0i.Grad)
+		hg := g * (1 - s*s)
+		for k, w := range c.Wh[0][i][offset : offset+len(c.X)] {
+			grads[k] += hg * w.Val
+		}
+	}
+	return grads
+}
+
+// fedReads returns the memory reads that are fed into the first hidden layer.
+func (c *controller1Deep) fedReads() []*memRead {
+	if c.cfg.noReadFeedback {
+		return nil
+	}
+	return c.Reads
+}
+
+func (c *controller1Deep) Weights(f func(*Unit)) {
+	c.memoryInitWeights(f)
+	doUnit2(c.Wyh, func(ids []int, u *Unit) { f(u) })
+	c.cfg.doHeadWeights(c.Wuh, c.MemoryM(), func(ids []int, u *Unit) { f(u) })
+	doUnit3(c.Wh, func(ids []int, u *Unit) { f(u) })
+	if c.temperature != nil {
+		f(c.temperature)
+	}
+}
+
+// WeightsVerbose is similar to Weights, but with additional information passed in.
+// Avoid using this function except for debugging, as it calls fmt.Sprintf many times which is a performance hog.
+func (c *controller1Deep) WeightsVerbose(f func(string, *Unit)) {
+	c.memoryInitWeightsVerbose(f)
+	tagify := func(tag string, ids []int) string {
+		s := tag
+		for i := len(ids) - 1; i >= 0; i-- {
+			s = fmt.Sprintf("%s[%d]", s, ids[i])
+		}
+		return s
+	}
+	doUnit2(c.Wyh, func(ids []int, u *Unit) { f(tagify("Wyh", ids), u) })
+	c.cfg.doHeadWeights(c.Wuh, c.MemoryM(), func(ids []int, u *Unit) { f(tagify("Wuh", ids), u) })
+	doUnit3(c.Wh, func(ids []int, u *Unit) { f(tagify("Wh", ids), u) })
+	if c.temperature != nil {
+		f("temperature[0]", c.temperature)
+	}
+}
+
+func (c *controller1Deep) weightGroup(group string, f func(*Unit)) {
+	switch group {
+	case GroupController:
+		doUnit2(c.Wyh, func(ids []int, u *Unit) { f(u) })
+		doUnit3(c.Wh, func(ids []int, u *Unit) { f(u) })
+		if c.temperature != nil {
+			f(c.temperature)
+		}
+	case GroupHeads:
+		c.cfg.doHeadWeights(c.Wuh, c.MemoryM(), func(ids []int, u *Unit) { f(u) })
+	case GroupMemoryInit:
+		c.memoryInitWeights(f)
+	}
+}
+
+func (c *controller1Deep) NumHeads() int {
+	return len(c.Wuh)
+}
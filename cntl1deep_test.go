@@ -0,0 +1,105 @@
+package ntm
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestController1Deep(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	times := 6
+	x := MakeTensor2(times, 4)
+	for i := 0; i < len(x); i++ {
+		for j := 0; j < len(x[i]); j++ {
+			x[i][j] = rnd.Float64()
+		}
+	}
+	y := MakeTensor2(times, 4)
+	for i := 0; i < len(y); i++ {
+		for j := 0; j < len(y[i]); j++ {
+			y[i][j] = rnd.Float64()
+		}
+	}
+	n := 3
+	m := 2
+	h1Sizes := []int{3, 2}
+	numHeads := 2
+	c := NewEmptyController1Deep(len(x[0]), len(y[0]), h1Sizes, numHeads, n, m)
+	c.Weights(func(u *Unit) { u.Val = 2 * rnd.Float64() })
+	ForwardBackward(c, x, y)
+
+	l := loss(c, Controller1DeepForward, x, y)
+	checkGradients(t, c, Controller1DeepForward, x, y, l)
+}
+
+func TestController1DeepNumWeights(t *testing.T) {
+	xSize, ySize, numHeads, n, m := 5, 4, 2, 7, 3
+	h1Sizes := []int{6, 3}
+	c := NewEmptyController1Deep(xSize, ySize, h1Sizes, numHeads, n, m)
+	headUnitsSize := len(NewHead(m).units)
+	expected := numHeads*n + n*m +
+		h1Sizes[0]*(numHeads*m+xSize+1) + h1Sizes[1]*(h1Sizes[0]+1) +
+		ySize*(h1Sizes[1]+1) + numHeads*headUnitsSize*(h1Sizes[1]+1)
+	if c.NumWeights() != expected {
+		t.Errorf("expected %d weights, got %d", expected, c.NumWeights())
+	}
+	count := 0
+	c.Weights(func(u *Unit) { count++ })
+	if count != expected {
+		t.Errorf("expected Weights to enumerate %d weights, got %d", expected, count)
+	}
+}
+
+func Controller1DeepForward(c1 Controller, reads [][]float64, x []float64) ([]float64, []*Head) {
+	c := c1.(*controller1Deep)
+	in := make([]float64, 0)
+	for _, r := range reads {
+		in = append(in, r...)
+	}
+	in = append(in, x...)
+	for _, whl := range c.Wh {
+		h := make([]float64, len(whl))
+		for i := range h {
+			var v float64 = 0
+			for j := range in {
+				v += whl[i][j].Val * in[j]
+			}
+			v += whl[i][len(in)].Val
+			h[i] = Tanh(v)
+		}
+		in = h
+	}
+	prediction := make([]float64, len(c.Wyh))
+	for i := range prediction {
+		var v float64 = 0
+		for j := range in {
+			v += c.Wyh[i][j].Val * in[j]
+		}
+		v += c.Wyh[i][len(in)].Val
+		prediction[i] = Sigmoid(v)
+	}
+	heads := make([]*Head, len(c.Wuh))
+	for i := range heads {
+		heads[i] = NewHead(c.MemoryM())
+		for j := range heads[i].units {
+			for k := range in {
+				heads[i].units[j].Val += c.Wuh[i][j][k].Val * in[k]
+			}
+			heads[i].units[j].Val += c.Wuh[i][j][len(in)].Val
+		}
+	}
+	return prediction, heads
+}
+
+func TestController1DeepHiddenSizes(t *testing.T) {
+	for _, h1Sizes := range [][]int{nil, {3, 0}, {-1}} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("no panic for hidden layers of sizes %v", h1Sizes)
+				}
+			}()
+			NewEmptyController1Deep(2, 2, h1Sizes, 1, 3, 2)
+		}()
+	}
+}
@@ -4,6 +4,40 @@ import (
 	"math/rand"
 )
 
+// Task is the copy task, in which the lengths of the sequences are uniformly distributed in [1, MaxSeqLen].
+type Task struct {
+	VectorSize int
+	MaxSeqLen  int
+}
+
+func (t Task) GenSeq() ([][]float64, [][]float64) {
+	return GenSeq(rand.Intn(t.MaxSeqLen)+1, t.VectorSize)
+}
+
+// GenSeqRand is similar to GenSeq, except that the sequences are drawn from r.
+func (t Task) GenSeqRand(r *rand.Rand) ([][]float64, [][]float64) {
+	opts := DefaultGenSeqOpts()
+	opts.Rand = r
+	return GenSeqWithOpts(r.Intn(t.MaxSeqLen)+1, t.VectorSize, opts)
+}
+
+func (t Task) InputSize() int {
+	return t.VectorSize + 2
+}
+
+func (t Task) OutputSize() int {
+	return t.VectorSize
+}
+
+func (t Task) Name() string {
+	return "copy"
+}
+
+// BaselineLoss returns 0, as the outputs are determined by the inputs seen so far.
+func (t Task) BaselineLoss() float64 {
+	return 0
+}
+
 func GenSeq(size, vectorSize int) ([][]float64, [][]float64) {
 	data := make([][]float64, size)
 	for i := 0; i < len(data); i++ {
@@ -12,18 +46,99 @@ func GenSeq(size, vectorSize int) ([][]float64, [][]float64) {
 			data[i][j] = float64(rand.Intn(2))
 		}
 	}
+	return layout(data, vectorSize)
+}
+
+// GenSeqContinuous is similar to GenSeq, except that the vectors to be copied have real values uniformly distributed in [0, 1].
+// The outputs are thus better scored by the mean squared error than by the cross-entropy.
+func GenSeqContinuous(size, vectorSize int, r *rand.Rand) ([][]float64, [][]float64) {
+	data := make([][]float64, size)
+	for i := 0; i < len(data); i++ {
+		data[i] = make([]float64, vectorSize)
+		for j := 0; j < len(data[i]); j++ {
+			data[i][j] = r.Float64()
+		}
+	}
+	return layout(data, vectorSize)
+}
+
+// GenSeqOpts configures the delimiters of the sequences generated by GenSeqWithOpts.
+type GenSeqOpts struct {
+	StartDelim bool    // whether the start of the sequence is marked on its own input channel
+	EndDelim   bool    // whether the end of the sequence is marked on its own input channel
+	DelimValue float64 // the value of the delimiter channels at the delimiter time instants
 
+	// Rand is the source of the sequence bits, or nil for the default source of math/rand.
+	Rand *rand.Rand
+}
+
+// DefaultGenSeqOpts returns the options used by GenSeq, in which both delimiters have their own channel set to 1.
+func DefaultGenSeqOpts() GenSeqOpts {
+	return GenSeqOpts{StartDelim: true, EndDelim: true, DelimValue: 1}
+}
+
+// InputSize returns the size of the inputs of sequences of vectors of size vectorSize, which has a channel for each delimiter.
+func (o GenSeqOpts) InputSize(vectorSize int) int {
+	n := vectorSize
+	if o.StartDelim {
+		n++
+	}
+	if o.EndDelim {
+		n++
+	}
+	return n
+}
+
+// GenSeqWithOpts is similar to GenSeq, except that the delimiters are configured by opts.
+// The time instants of the delimiters are kept when a delimiter is disabled, so that the outputs are the same as those of GenSeq.
+func GenSeqWithOpts(size, vectorSize int, opts GenSeqOpts) ([][]float64, [][]float64) {
+	intn := rand.Intn
+	if opts.Rand != nil {
+		intn = opts.Rand.Intn
+	}
+	data := make([][]float64, size)
+	for i := 0; i < len(data); i++ {
+		data[i] = make([]float64, vectorSize)
+		for j := 0; j < len(data[i]); j++ {
+			data[i][j] = float64(intn(2))
+		}
+	}
+	return layoutOpts(data, vectorSize, opts)
+}
+
+// layout returns the inputs and outputs of the copy task of data.
+// The input is a start delimiter, then data, then an end delimiter, followed by blanks during which the output must be data.
+func layout(data [][]float64, vectorSize int) ([][]float64, [][]float64) {
+	return layoutOpts(data, vectorSize, DefaultGenSeqOpts())
+}
+
+// layoutOpts is similar to layout, except that the delimiters are configured by opts.
+func layoutOpts(data [][]float64, vectorSize int, opts GenSeqOpts) ([][]float64, [][]float64) {
+	size := len(data)
+	startCh, endCh := -1, -1
+	ch := vectorSize
+	if opts.StartDelim {
+		startCh = ch
+		ch++
+	}
+	if opts.EndDelim {
+		endCh = ch
+	}
 	input := make([][]float64, size*2+2)
 	for i := 0; i < len(input); i++ {
-		input[i] = make([]float64, vectorSize+2)
+		input[i] = make([]float64, opts.InputSize(vectorSize))
 		if i == 0 {
-			input[i][vectorSize] = 1
+			if startCh >= 0 {
+				input[i][startCh] = opts.DelimValue
+			}
 		} else if i <= size {
 			for j := 0; j < vectorSize; j++ {
 				input[i][j] = data[i-1][j]
 			}
 		} else if i == size+1 {
-			input[i][vectorSize+1] = 1
+			if endCh >= 0 {
+				input[i][endCh] = opts.DelimValue
+			}
 		}
 	}
 
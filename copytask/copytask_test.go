@@ -0,0 +1,110 @@
+package copytask
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCopyGenSeqContinuous(t *testing.T) {
+	size, vectorSize := 4, 3
+	x, y := GenSeqContinuous(size, vectorSize, rand.New(rand.NewSource(1)))
+	if len(x) != 2*size+2 || len(y) != 2*size+2 {
+		t.Fatalf("wrong lengths %d %d", len(x), len(y))
+	}
+	nonBinary := false
+	for tt := range x {
+		start, end := x[tt][vectorSize], x[tt][vectorSize+1]
+		if start != boolFloat(tt == 0) || end != boolFloat(tt == size+1) {
+			t.Errorf("wrong delimiters at %d: %f %f", tt, start, end)
+		}
+		for j := 0; j < vectorSize; j++ {
+			v := x[tt][j]
+			if v < 0 || v > 1 {
+				t.Errorf("x[%d][%d] = %f not in [0, 1]", tt, j, v)
+			}
+			if v != 0 && v != 1 {
+				nonBinary = true
+			}
+			if (tt == 0 || tt > size) && v != 0 {
+				t.Errorf("x[%d][%d] = %f, expected 0", tt, j, v)
+			}
+			var expected float64
+			if tt >= size+2 {
+				expected = x[tt-size-1][j]
+			}
+			if y[tt][j] != expected {
+				t.Errorf("y[%d][%d] = %f, expected %f", tt, j, y[tt][j], expected)
+			}
+		}
+	}
+	if !nonBinary {
+		t.Errorf("all inputs are binary")
+	}
+}
+
+func TestCopyGenSeqWithOpts(t *testing.T) {
+	size, vectorSize := 5, 3
+	equal := func(a, b [][]float64) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if len(a[i]) != len(b[i]) {
+				return false
+			}
+			for j := range a[i] {
+				if math.Float64bits(a[i][j]) != math.Float64bits(b[i][j]) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	// The default options reproduce GenSeq bit for bit, which lays out bits drawn in the same order.
+	r := rand.New(rand.NewSource(21))
+	data := make([][]float64, size)
+	for i := range data {
+		data[i] = make([]float64, vectorSize)
+		for j := range data[i] {
+			data[i][j] = float64(r.Intn(2))
+		}
+	}
+	x, y := layout(data, vectorSize)
+	opts := DefaultGenSeqOpts()
+	opts.Rand = rand.New(rand.NewSource(21))
+	xo, yo := GenSeqWithOpts(size, vectorSize, opts)
+	if !equal(x, xo) || !equal(y, yo) {
+		t.Fatalf("default options differ from GenSeq:\n%v %v\n%v %v", x, y, xo, yo)
+	}
+	if x[0][vectorSize] != 1 || x[size+1][vectorSize+1] != 1 {
+		t.Errorf("wrong delimiters %v %v", x[0], x[size+1])
+	}
+
+	// A single end delimiter of magnitude 0.5 on the only extra channel.
+	opts = GenSeqOpts{EndDelim: true, DelimValue: 0.5, Rand: rand.New(rand.NewSource(22))}
+	xe, ye := GenSeqWithOpts(size, vectorSize, opts)
+	if len(xe[0]) != opts.InputSize(vectorSize) || opts.InputSize(vectorSize) != vectorSize+1 {
+		t.Fatalf("input size %d, expected %d", len(xe[0]), vectorSize+1)
+	}
+	for tt := range xe {
+		var expected float64
+		if tt == size+1 {
+			expected = 0.5
+		}
+		if xe[tt][vectorSize] != expected {
+			t.Errorf("[%d] delimiter %f, expected %f", tt, xe[tt][vectorSize], expected)
+		}
+		if tt >= size+2 && !equal(ye[tt:tt+1], [][]float64{xe[tt-size-1][:vectorSize]}) {
+			t.Errorf("[%d] output %v does not copy input %v", tt, ye[tt], xe[tt-size-1])
+		}
+	}
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
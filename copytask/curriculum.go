@@ -0,0 +1,35 @@
+package copytask
+
+// CurriculumPolicy decides the maximum length of the sequences sampled at a training step.
+type CurriculumPolicy interface {
+	// Length returns the maximum sequence length at step, which is within [1, maxLen].
+	Length(step, maxLen int) int
+}
+
+// LinearCurriculum starts with sequences of length 1, and raises the maximum length by one every StepsPerLength steps.
+type LinearCurriculum struct {
+	StepsPerLength int
+}
+
+// Length implements CurriculumPolicy.
+func (c LinearCurriculum) Length(step, maxLen int) int {
+	l := maxLen
+	if c.StepsPerLength > 0 {
+		l = 1 + step/c.StepsPerLength
+	}
+	if l > maxLen {
+		l = maxLen
+	}
+	if l < 1 {
+		l = 1
+	}
+	return l
+}
+
+// DefaultStepsPerLength is the number of training steps spent at each maximum length by CurriculumLength.
+const DefaultStepsPerLength = 1000
+
+// CurriculumLength returns the maximum sequence length at step under a LinearCurriculum of DefaultStepsPerLength.
+func CurriculumLength(step int, maxLen int) int {
+	return LinearCurriculum{StepsPerLength: DefaultStepsPerLength}.Length(step, maxLen)
+}
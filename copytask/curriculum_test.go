@@ -0,0 +1,33 @@
+package copytask
+
+import (
+	"testing"
+)
+
+func TestCurriculumLength(t *testing.T) {
+	maxLen := 20
+	for step := 0; step < DefaultStepsPerLength; step++ {
+		if l := CurriculumLength(step, maxLen); l != 1 {
+			t.Fatalf("step %d length %d, expected 1", step, l)
+		}
+	}
+	prev := 1
+	for step := 0; step < 100*DefaultStepsPerLength; step += 97 {
+		l := CurriculumLength(step, maxLen)
+		if l < prev || l > maxLen {
+			t.Fatalf("step %d length %d, previous %d, max %d", step, l, prev, maxLen)
+		}
+		prev = l
+	}
+	if prev != maxLen {
+		t.Errorf("late length %d, expected %d", prev, maxLen)
+	}
+
+	var c CurriculumPolicy = LinearCurriculum{StepsPerLength: 10}
+	if l := c.Length(25, maxLen); l != 3 {
+		t.Errorf("length %d, expected 3", l)
+	}
+	if l := (LinearCurriculum{}).Length(0, maxLen); l != maxLen {
+		t.Errorf("length without curriculum %d, expected %d", l, maxLen)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"math"
 	"math/rand"
 	"net/http"
 	"os"
@@ -18,7 +17,7 @@ import (
 var (
 	cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 
-	weightsChan    = make(chan chan []byte)
+	weights        ntm.WeightsStore
 	lossChan       = make(chan chan []float64)
 	printDebugChan = make(chan struct{})
 )
@@ -35,9 +34,7 @@ func main() {
 	}
 
 	http.HandleFunc("/Weights", func(w http.ResponseWriter, r *http.Request) {
-		c := make(chan []byte)
-		weightsChan <- c
-		w.Write(<-c)
+		json.NewEncoder(w).Encode(weights.Weights())
 	})
 	http.HandleFunc("/Loss", func(w http.ResponseWriter, r *http.Request) {
 		c := make(chan []float64)
@@ -55,55 +52,44 @@ func main() {
 		}
 	}()
 
-	var seed int64 = 8
-	rand.Seed(seed)
-	log.Printf("seed: %d", seed)
+	cfg := ntm.DefaultTrainConfig()
+	rand.Seed(cfg.Seed)
+	log.Printf("seed: %d", cfg.Seed)
 
 	vectorSize := 8
-	h1Size := 100
-	numHeads := 1
-	n := 128
-	m := 20
-	c := ntm.NewEmptyController1(vectorSize+2, vectorSize, h1Size, numHeads, n, m)
+	c := ntm.NewEmptyController1(vectorSize+2, vectorSize, cfg.H1Size, cfg.NumHeads, cfg.N, cfg.M)
 	c.Weights(func(u *ntm.Unit) { u.Val = 1 * (rand.Float64() - 0.5) })
 
-	losses := make([]float64, 0)
+	losses := ntm.NewLossTracker(1000)
 	doPrint := false
 
 	//sgd := ntm.NewSGDMomentum(c)
 	rmsp := ntm.NewRMSProp(c)
 	log.Printf("numweights: %d", c.NumWeights())
 	for i := 1; ; i++ {
-		x, y := copytask.GenSeq(rand.Intn(20)+1, vectorSize)
+		maxLen := copytask.CurriculumLength(i, cfg.MaxSeqLen-cfg.MinSeqLen+1)
+		x, y := copytask.GenSeq(rand.Intn(maxLen)+cfg.MinSeqLen, vectorSize)
 		//machines := sgd.Train(x, y, 1e-4, 0.9)
-		machines := rmsp.Train(x, y, 0.95, 0.5, 1e-3, 1e-3)
-		l := ntm.Loss(y, machines)
-		if i%1000 == 0 {
-			bpc := l / float64(len(y)*len(y[0]))
-			losses = append(losses, bpc)
-			log.Printf("%d, bpc: %f, seq length: %d", i, bpc, len(y))
+		machines := rmsp.Train(x, y, cfg.Decay, cfg.Momentum, cfg.LearningRate, cfg.Epsilon)
+		if i%cfg.ReportInterval == 0 {
+			bpb := ntm.BitsPerBit(y, machines)
+			losses.Add(bpb)
+			log.Printf("%d, bits-per-bit: %f, moving average: %f, seq length: %d", i, bpb, losses.MovingAverage(10), len(y))
 		}
 
-		handleHTTP(c, losses, &doPrint)
+		weights.Update(c)
+		handleHTTP(losses, &doPrint)
 
-		if i%1000 == 0 && doPrint {
+		if i%cfg.ReportInterval == 0 && doPrint {
 			printDebug(y, machines)
 		}
 	}
 }
 
-func handleHTTP(c ntm.Controller, losses []float64, doPrint *bool) {
+func handleHTTP(losses *ntm.LossTracker, doPrint *bool) {
 	select {
-	case cn := <-weightsChan:
-		ws := make([]float64, 0, c.NumWeights())
-		c.Weights(func(u *ntm.Unit) { ws = append(ws, u.Val) })
-		b, err := json.Marshal(ws)
-		if err != nil {
-			log.Fatalf("%v", err)
-		}
-		cn <- b
 	case cn := <-lossChan:
-		cn <- losses
+		cn <- losses.Values()
 	case <-printDebugChan:
 		*doPrint = !*doPrint
 	default:
@@ -116,15 +102,10 @@ func printDebug(y [][]float64, machines []*ntm.NTM) {
 
 	log.Printf("pred: %s", ntm.Sprint2(ntm.Predictions(machines)))
 
-	n := machines[0].Controller.MemoryN()
-	//outputT := len(machines) - (len(machines) - 2) / 2
-	outputT := 0
-	for t := outputT; t < len(machines); t++ {
-		h := machines[t].Controller.Heads()[0]
-		beta := math.Exp(h.Beta().Val)
-		g := ntm.Sigmoid(h.G().Val)
-		shift := math.Mod(2*ntm.Sigmoid(h.S().Val)-1+float64(n), float64(n))
-		gamma := math.Log(math.Exp(h.Gamma().Val)+1) + 1
-		log.Printf("beta: %.3g(%v), g: %.3g(%v), s: %.3g(%v), gamma: %.3g(%v), erase: %+v, add: %+v, k: %+v", beta, h.Beta(), g, h.G(), shift, h.S(), gamma, h.Gamma(), h.EraseVector(), h.AddVector(), h.K())
+	for _, r := range ntm.HeadParams(machines) {
+		if r.Head != 0 {
+			continue
+		}
+		log.Printf("%+v", r)
 	}
 }
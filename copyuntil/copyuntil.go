@@ -0,0 +1,107 @@
+// Package copyuntil implements the copy-until task,
+// in which a network must repeatedly copy its input until a stop symbol arrives in a control channel during the output phase.
+// Unlike the repeat copy task, the number of copies is not given in advance,
+// so the network must react to the stop symbol when it arrives.
+package copyuntil
+
+import (
+	"math/rand"
+)
+
+// StopSteps is the number of time steps after the stop symbol arrives, including the one carrying it,
+// in which the output is all zeros.
+const StopSteps = 2
+
+// Task is the copy-until task, in which the lengths of the sequences are uniformly distributed in [1, MaxSeqLen],
+// and the stop symbol arrives after a number of output steps uniformly distributed in [1, MaxCopySteps].
+type Task struct {
+	VectorSize   int
+	MaxSeqLen    int
+	MaxCopySteps int
+}
+
+func (t Task) GenSeq() ([][]float64, [][]float64) {
+	return GenSeq(rand.Intn(t.MaxSeqLen)+1, rand.Intn(t.MaxCopySteps)+1, t.VectorSize)
+}
+
+// GenSeqRand is similar to GenSeq, except that the sequences are drawn from r.
+func (t Task) GenSeqRand(r *rand.Rand) ([][]float64, [][]float64) {
+	return genSeq(r.Intn(t.MaxSeqLen)+1, r.Intn(t.MaxCopySteps)+1, t.VectorSize, r.Intn)
+}
+
+// InputSize returns the size of the input vectors, which are made of the data, the start and end delimiters, and the stop channel.
+func (t Task) InputSize() int {
+	return t.VectorSize + 3
+}
+
+func (t Task) OutputSize() int {
+	return t.VectorSize
+}
+
+func (t Task) Name() string {
+	return "copyuntil"
+}
+
+// BaselineLoss returns 0, as the outputs are determined by the inputs seen so far.
+func (t Task) BaselineLoss() float64 {
+	return 0
+}
+
+// Mask returns the time steps of the sequences x and y generated by GenSeq that are scored, see the function Mask.
+// The length of the copied sequence is read off the end delimiter of x.
+func (t Task) Mask(x, y [][]float64) []bool {
+	length := 0
+	for x[length+1][t.VectorSize+1] != 1 {
+		length++
+	}
+	return Mask(length, len(x)-length-2-StopSteps)
+}
+
+// GenSeq generates a sequence of length random binary vectors between a start and an end delimiter,
+// followed by an output phase of copySteps+StopSteps time steps.
+// During the first copySteps time steps of the output phase, the output cycles through the vectors of the sequence.
+// The stop symbol is then given in the last input channel, after which the output is all zeros.
+func GenSeq(length, copySteps, vectorSize int) ([][]float64, [][]float64) {
+	return genSeq(length, copySteps, vectorSize, rand.Intn)
+}
+
+func genSeq(length, copySteps, vectorSize int, intn func(int) int) ([][]float64, [][]float64) {
+	outputStart := length + 2
+	size := outputStart + copySteps + StopSteps
+	input := make([][]float64, size)
+	output := make([][]float64, size)
+	for t := range input {
+		input[t] = make([]float64, vectorSize+3)
+		output[t] = make([]float64, vectorSize)
+	}
+
+	input[0][vectorSize] = 1
+	for t := 1; t <= length; t++ {
+		for j := 0; j < vectorSize; j++ {
+			input[t][j] = float64(intn(2))
+		}
+	}
+	input[length+1][vectorSize+1] = 1
+	input[StopTime(length, copySteps)][vectorSize+2] = 1
+
+	for s := 0; s < copySteps; s++ {
+		copy(output[outputStart+s], input[1+s%length][:vectorSize])
+	}
+	return input, output
+}
+
+// StopTime returns the time step at which the stop symbol arrives in a sequence generated with GenSeq.
+func StopTime(length, copySteps int) int {
+	return length + 2 + copySteps
+}
+
+// Mask returns the time steps of a sequence generated with GenSeq that should be scored,
+// which are those in the output phase, including the ones after the stop symbol.
+// The result is intended to be passed to ntm.ForwardBackwardMasked and ntm.BitsPerBitMasked.
+func Mask(length, copySteps int) []bool {
+	mask := make([]bool, StopTime(length, copySteps)+StopSteps)
+	for t := length + 2; t < len(mask); t++ {
+		mask[t] = true
+	}
+	return mask
+}
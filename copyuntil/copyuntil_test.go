@@ -0,0 +1,84 @@
+package copyuntil
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCopyUntilGenSeq(t *testing.T) {
+	length, copySteps, vectorSize := 3, 7, 4
+	x, y := GenSeq(length, copySteps, vectorSize)
+	stop := StopTime(length, copySteps)
+	if len(x) != stop+StopSteps || len(y) != len(x) {
+		t.Fatalf("wrong lengths %d %d", len(x), len(y))
+	}
+	for tt := range x {
+		if s := x[tt][vectorSize+2]; s != boolFloat(tt == stop) {
+			t.Errorf("stop channel at %d is %f", tt, s)
+		}
+		if start, end := x[tt][vectorSize], x[tt][vectorSize+1]; start != boolFloat(tt == 0) || end != boolFloat(tt == length+1) {
+			t.Errorf("wrong delimiters at %d: %f %f", tt, start, end)
+		}
+		for j := 0; j < vectorSize; j++ {
+			if (tt == 0 || tt > length) && x[tt][j] != 0 {
+				t.Errorf("x[%d][%d] = %f, expected 0", tt, j, x[tt][j])
+			}
+			var expected float64
+			if tt >= length+2 && tt < stop {
+				expected = x[1+(tt-length-2)%length][j]
+			}
+			if y[tt][j] != expected {
+				t.Errorf("y[%d][%d] = %f, expected %f", tt, j, y[tt][j], expected)
+			}
+		}
+	}
+
+	mask := Mask(length, copySteps)
+	if len(mask) != len(y) {
+		t.Fatalf("mask length %d, expected %d", len(mask), len(y))
+	}
+	for tt, scored := range mask {
+		if scored != (tt >= length+2) {
+			t.Errorf("mask[%d] = %t", tt, scored)
+		}
+	}
+
+	// The stop symbol arrives at a random time.
+	task := Task{VectorSize: vectorSize, MaxSeqLen: 1, MaxCopySteps: 10}
+	r := rand.New(rand.NewSource(30))
+	stops := make(map[int]bool)
+	for i := 0; i < 50; i++ {
+		x, _ := task.GenSeqRand(r)
+		for tt := range x {
+			if x[tt][vectorSize+2] == 1 {
+				stops[tt] = true
+			}
+		}
+	}
+	if len(stops) < 2 {
+		t.Errorf("the stop symbol always arrives at %v", stops)
+	}
+
+	// The mask of a task is read off its sequences.
+	for _, lc := range [][2]int{{1, 1}, {1, 6}, {4, 2}, {5, 9}} {
+		x, y := genSeq(lc[0], lc[1], vectorSize, r.Intn)
+		expected := Mask(lc[0], lc[1])
+		mask := task.Mask(x, y)
+		if len(mask) != len(expected) {
+			t.Fatalf("length %d, copy steps %d: mask length %d, expected %d", lc[0], lc[1], len(mask), len(expected))
+		}
+		for tt := range mask {
+			if mask[tt] != expected[tt] {
+				t.Errorf("length %d, copy steps %d: mask %v, expected %v", lc[0], lc[1], mask, expected)
+				break
+			}
+		}
+	}
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
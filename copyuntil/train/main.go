@@ -0,0 +1,50 @@
+// Command train trains a NTM on the loss of the output phase of the copy-until task,
+// reporting that loss in bits per bit.
+package main
+
+import (
+	"flag"
+	"log"
+	"math/rand"
+
+	"github.com/fumin/ntm"
+	"github.com/fumin/ntm/copyuntil"
+)
+
+var (
+	defaults = ntm.DefaultTrainConfig()
+
+	vectorSize   = flag.Int("vectorSize", 8, "size of the vectors to be copied")
+	maxSeqLen    = flag.Int("maxSeqLen", 10, "maximum length of the sequences to be copied")
+	maxCopySteps = flag.Int("maxCopySteps", 30, "maximum number of output steps before the stop symbol arrives")
+	steps        = flag.Int("steps", defaults.Steps, "number of training steps")
+	seed         = flag.Int64("seed", defaults.Seed, "random seed")
+)
+
+func main() {
+	flag.Parse()
+	cfg := defaults
+	rand.Seed(*seed)
+	log.Printf("seed: %d", *seed)
+
+	t := copyuntil.Task{VectorSize: *vectorSize, MaxSeqLen: *maxSeqLen, MaxCopySteps: *maxCopySteps}
+	c := ntm.NewEmptyController1(t.InputSize(), t.OutputSize(), cfg.H1Size, cfg.NumHeads, cfg.N, cfg.M)
+	c.Weights(func(u *ntm.Unit) { u.Val = 1 * (rand.Float64() - 0.5) })
+
+	losses := ntm.NewLossTracker(1000)
+	rmsp := ntm.NewRMSProp(c)
+	rmsp.Decay, rmsp.Momentum, rmsp.LearningRate, rmsp.Epsilon = cfg.Decay, cfg.Momentum, cfg.LearningRate, cfg.Epsilon
+	log.Printf("numweights: %d", c.NumWeights())
+	for i := 1; i <= *steps; i++ {
+		length, copySteps := rand.Intn(t.MaxSeqLen)+1, rand.Intn(t.MaxCopySteps)+1
+		x, y := copyuntil.GenSeq(length, copySteps, t.VectorSize)
+		mask := copyuntil.Mask(length, copySteps)
+		machines := ntm.ForwardBackwardMasked(c, x, y, mask)
+		rmsp.Step(machines)
+		if i%cfg.ReportInterval == 0 {
+			bpb := ntm.BitsPerBitMasked(y, machines, mask)
+			losses.Add(bpb)
+			log.Printf("%d, output phase bits-per-bit: %f, moving average: %f, seq length: %d, copy steps: %d", i, bpb, losses.MovingAverage(10), length, copySteps)
+		}
+	}
+}
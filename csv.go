@@ -0,0 +1,58 @@
+package ntm
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteCSV writes the matrix m to w in CSV format, one row of m per line.
+// Values are formatted with the minimal precision that parses back to the same float64.
+// Nothing is written if m is empty.
+func WriteCSV(w io.Writer, m [][]float64) error {
+	cw := csv.NewWriter(w)
+	for _, row := range m {
+		if err := cw.Write(formatRow(nil, row)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteHeadWeightsCSV writes the addressing weights of all memory heads across time to w in CSV format.
+// The first line is a header, and every following line holds the head index, the time instant,
+// and the weights of that head on every memory location, as returned by HeadWeights.
+// Nothing is written if machines is empty.
+func WriteHeadWeightsCSV(w io.Writer, machines []*NTM) error {
+	if len(machines) == 0 {
+		return nil
+	}
+	hws := HeadWeights(machines)
+	cw := csv.NewWriter(w)
+	header := []string{"head", "t"}
+	for j := range hws[0][0] {
+		header = append(header, fmt.Sprintf("w[%d]", j))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i, hw := range hws {
+		for t, weights := range hw {
+			if err := cw.Write(formatRow([]string{strconv.Itoa(i), strconv.Itoa(t)}, weights)); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatRow appends the formatted values of row to record.
+func formatRow(record []string, row []float64) []string {
+	for _, v := range row {
+		record = append(record, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return record
+}
@@ -0,0 +1,90 @@
+package ntm
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	m := MakeTensor2(3, 4)
+	for i := range m {
+		for j := range m[i] {
+			m[i][j] = rnd.NormFloat64()
+		}
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, m); err != nil {
+		t.Fatalf("%v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(records) != len(m) {
+		t.Fatalf("%d rows, expected %d", len(records), len(m))
+	}
+	for i, r := range records {
+		for j, s := range r {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if v != m[i][j] {
+				t.Errorf("[%d][%d] %v != %v", i, j, v, m[i][j])
+			}
+		}
+	}
+
+	buf.Reset()
+	if err := WriteCSV(&buf, nil); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("empty matrix written as %q", buf.String())
+	}
+}
+
+func TestWriteHeadWeightsCSV(t *testing.T) {
+	c := NewEmptyController1(2, 2, 3, 2, 4, 2)
+	rnd := rand.New(rand.NewSource(4))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	machines := ForwardBackward(c, [][]float64{{0, 1}, {1, 0}, {1, 1}}, [][]float64{{1, 0}, {0, 1}, {1, 1}})
+
+	var buf bytes.Buffer
+	if err := WriteHeadWeightsCSV(&buf, machines); err != nil {
+		t.Fatalf("%v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	hws := HeadWeights(machines)
+	if len(records) != 1+len(hws)*len(machines) {
+		t.Fatalf("%d rows, expected %d", len(records), 1+len(hws)*len(machines))
+	}
+	if records[0][0] != "head" || records[0][2] != "w[0]" {
+		t.Errorf("unexpected header %v", records[0])
+	}
+	for _, r := range records[1:] {
+		i, _ := strconv.Atoi(r[0])
+		tt, _ := strconv.Atoi(r[1])
+		for j, s := range r[2:] {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if v != hws[i][tt][j] {
+				t.Errorf("head %d t %d [%d] %v != %v", i, tt, j, v, hws[i][tt][j])
+			}
+		}
+	}
+
+	buf.Reset()
+	if err := WriteHeadWeightsCSV(&buf, nil); err != nil || buf.Len() != 0 {
+		t.Errorf("no machines: %v %q", err, buf.String())
+	}
+}
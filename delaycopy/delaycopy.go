@@ -0,0 +1,74 @@
+// Package delaycopy implements the delayed copy task,
+// in which a network must reproduce its input delayed by a fixed number of time steps.
+package delaycopy
+
+import (
+	"math/rand"
+)
+
+// Task is the delayed copy task, in which the lengths of the sequences are uniformly distributed in [1, MaxLength].
+type Task struct {
+	VectorSize int
+	MaxLength  int
+	Delay      int
+}
+
+func (t Task) GenSeq() ([][]float64, [][]float64) {
+	return GenSeq(rand.Intn(t.MaxLength)+1, t.Delay, t.VectorSize)
+}
+
+func (t Task) InputSize() int {
+	return t.VectorSize
+}
+
+func (t Task) OutputSize() int {
+	return t.VectorSize
+}
+
+func (t Task) Name() string {
+	return "delaycopy"
+}
+
+// Mask returns the time steps of the sequences x and y generated by GenSeq that are scored, see the function Mask.
+func (t Task) Mask(x, y [][]float64) []bool {
+	return Mask(len(x)-t.Delay, t.Delay)
+}
+
+// BaselineLoss returns 0, as the outputs are determined by the inputs seen so far.
+func (t Task) BaselineLoss() float64 {
+	return 0
+}
+
+// GenSeq generates a sequence of length random binary vectors, followed by delay zero vectors.
+// The output is all zeros for the first delay time steps, after which it echoes the input.
+func GenSeq(length, delay, vectorSize int) ([][]float64, [][]float64) {
+	input := make([][]float64, length+delay)
+	for t := range input {
+		input[t] = make([]float64, vectorSize)
+		if t < length {
+			for j := range input[t] {
+				input[t][j] = float64(rand.Intn(2))
+			}
+		}
+	}
+
+	output := make([][]float64, length+delay)
+	for t := range output {
+		output[t] = make([]float64, vectorSize)
+		if t >= delay {
+			copy(output[t], input[t-delay])
+		}
+	}
+	return input, output
+}
+
+// Mask returns the time steps of a sequence generated with GenSeq that should be scored,
+// which are those in the echo phase.
+// The result is intended to be passed to ntm.LossMasked.
+func Mask(length, delay int) []bool {
+	mask := make([]bool, length+delay)
+	for t := delay; t < len(mask); t++ {
+		mask[t] = true
+	}
+	return mask
+}
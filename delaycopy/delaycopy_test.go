@@ -0,0 +1,40 @@
+package delaycopy
+
+import (
+	"testing"
+)
+
+func TestDelayCopyGenSeq(t *testing.T) {
+	length, delay, vectorSize := 5, 3, 4
+	x, y := GenSeq(length, delay, vectorSize)
+	if len(x) != length+delay || len(y) != length+delay {
+		t.Fatalf("wrong lengths %d %d", len(x), len(y))
+	}
+	for tt := range y {
+		for j := 0; j < vectorSize; j++ {
+			var expected float64
+			if tt >= delay {
+				expected = x[tt-delay][j]
+			}
+			if y[tt][j] != expected {
+				t.Errorf("y[%d][%d] = %f, expected %f", tt, j, y[tt][j], expected)
+			}
+			if tt >= length && x[tt][j] != 0 {
+				t.Errorf("x[%d][%d] = %f, expected 0", tt, j, x[tt][j])
+			}
+		}
+	}
+
+	mask := Mask(length, delay)
+	for tt, scored := range mask {
+		if scored != (tt >= delay) {
+			t.Errorf("mask[%d] = %t", tt, scored)
+		}
+	}
+	task := Task{VectorSize: vectorSize, MaxLength: length, Delay: delay}
+	for tt, scored := range task.Mask(x, y) {
+		if scored != mask[tt] {
+			t.Errorf("task mask[%d] = %t, expected %t", tt, scored, mask[tt])
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package ntm
+
+import (
+	"math/rand"
+)
+
+// A dropoutConfig holds the state of dropout on the hidden layers of a controller.
+// It is shared by a controller and the controllers it forwards, so that SetTraining takes effect on all of them.
+type dropoutConfig struct {
+	keepProb  float64
+	rnd       *rand.Rand
+	inference bool
+}
+
+// WithDropout applies inverted dropout to the hidden layers of a controller during training,
+// keeping every hidden unit with probability keepProb and scaling the kept units by 1/keepProb.
+// The dropout masks are drawn from rnd, which must not be used concurrently elsewhere.
+// A keepProb of 1 disables dropout. Dropout is disabled at inference, see SetTraining.
+func WithDropout(keepProb float64, rnd *rand.Rand) ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.dropout = &dropoutConfig{keepProb: keepProb, rnd: rnd}
+	}
+}
+
+// A dropouter is a Controller that supports WithDropout.
+type dropouter interface {
+	// dropoutConfig returns the dropout state of the controller, or nil if it has no dropout.
+	dropoutConfig() *dropoutConfig
+}
+
+// SetTraining switches the dropout of a controller between training, the default, and inference, in which case dropout is disabled.
+// It is a no-op returning nil for controllers without dropout, including those that do not support WithDropout.
+func SetTraining(c Controller, training bool) error {
+	d, ok := c.(dropouter)
+	if !ok {
+		return nil
+	}
+	if dc := d.dropoutConfig(); dc != nil {
+		dc.inference = !training
+	}
+	return nil
+}
+
+// dropoutScales returns the scales applied to n hidden units by inverted dropout, each being either 0 or 1/keepProb.
+// It returns nil if dropout is disabled.
+func (cfg controllerConfig) dropoutScales(n int) []float64 {
+	d := cfg.dropout
+	if d == nil || d.inference || d.keepProb >= 1 {
+		return nil
+	}
+	scales := make([]float64, n)
+	for i := range scales {
+		if d.rnd.Float64() < d.keepProb {
+			scales[i] = 1 / d.keepProb
+		}
+	}
+	return scales
+}
+
+// cloned returns the configuration of a copy of a controller configured by cfg.
+// The copy draws its dropout masks from a new random source seeded by that of cfg, so that it can run concurrently with the original.
+func (cfg controllerConfig) cloned() controllerConfig {
+	if cfg.dropout != nil {
+		d := *cfg.dropout
+		d.rnd = rand.New(rand.NewSource(cfg.dropout.rnd.Int63()))
+		cfg.dropout = &d
+	}
+	return cfg
+}
+
+// dropoutGrad returns the activation before dropout of a hidden unit whose value is val,
+// together with the gradient flowing through dropout given the gradient grad of the unit.
+// scales are the dropout scales of the hidden layer, which may be nil.
+func dropoutGrad(scales []float64, i int, val, grad float64) (float64, float64) {
+	if scales == nil {
+		return val, grad
+	}
+	if scales[i] == 0 {
+		return 0, 0
+	}
+	return val / scales[i], grad * scales[i]
+}
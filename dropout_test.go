@@ -0,0 +1,143 @@
+package ntm
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func dropoutSeqs(rnd *rand.Rand, times, xSize, ySize int) (x, y [][]float64) {
+	x = MakeTensor2(times, xSize)
+	y = MakeTensor2(times, ySize)
+	for i := range x {
+		for j := range x[i] {
+			x[i][j] = rnd.Float64()
+		}
+		for j := range y[i] {
+			y[i][j] = rnd.Float64()
+		}
+	}
+	return x, y
+}
+
+func TestDropoutKeepAll(t *testing.T) {
+	x, y := dropoutSeqs(rand.New(rand.NewSource(1)), 5, 3, 2)
+	newControllers := []func(opts ...ControllerOption) Controller{
+		func(opts ...ControllerOption) Controller { return NewEmptyController1(3, 2, 4, 1, 3, 2, opts...) },
+		func(opts ...ControllerOption) Controller {
+			return NewEmptyController1Deep(3, 2, []int{4, 3}, 1, 3, 2, opts...)
+		},
+	}
+	for _, newController := range newControllers {
+		grads := func(opts ...ControllerOption) []float64 {
+			c := newController(opts...)
+			rnd := rand.New(rand.NewSource(2))
+			c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+			ForwardBackward(c, x, y)
+			var g []float64
+			c.Weights(func(u *Unit) { g = append(g, u.Grad) })
+			return g
+		}
+		expected := grads()
+		g := grads(WithDropout(1, rand.New(rand.NewSource(3))))
+		for i := range expected {
+			if g[i] != expected[i] {
+				t.Fatalf("gradient %d %f != %f", i, g[i], expected[i])
+			}
+		}
+
+		// Dropout is disabled at inference.
+		c := newController(WithDropout(0.5, rand.New(rand.NewSource(3))))
+		if err := SetTraining(c, false); err != nil {
+			t.Fatalf("%v", err)
+		}
+		rnd := rand.New(rand.NewSource(2))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+		ForwardBackward(c, x, y)
+		i := 0
+		c.Weights(func(u *Unit) {
+			if u.Grad != expected[i] {
+				t.Errorf("inference gradient %d %f != %f", i, u.Grad, expected[i])
+			}
+			i++
+		})
+	}
+
+	// SetTraining is a no-op for controllers without dropout support.
+	if err := SetTraining(struct{ Controller }{NewEmptyController1(3, 2, 4, 1, 3, 2)}, false); err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
+func TestDropoutHalf(t *testing.T) {
+	h1Size := 500
+	c := NewEmptyController1(3, 2, h1Size, 1, 3, 2, WithDropout(0.5, rand.New(rand.NewSource(4))))
+	rnd := rand.New(rand.NewSource(5))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := dropoutSeqs(rnd, 3, 3, 2)
+	machines := ForwardBackward(c, x, y)
+	for _, m := range machines {
+		cm := m.Controller.(*controller1)
+		zeros := 0
+		for _, h := range cm.H1 {
+			if h.Val == 0 {
+				zeros++
+			}
+		}
+		if f := float64(zeros) / float64(h1Size); math.Abs(f-0.5) > 0.1 {
+			t.Errorf("dropped out fraction %f", f)
+		}
+	}
+}
+
+func TestDropoutGradients(t *testing.T) {
+	x, y := dropoutSeqs(rand.New(rand.NewSource(6)), 4, 3, 2)
+	newControllers := []func(rnd *rand.Rand) Controller{
+		func(rnd *rand.Rand) Controller { return NewEmptyController1(3, 2, 5, 1, 3, 2, WithDropout(0.6, rnd)) },
+		func(rnd *rand.Rand) Controller {
+			return NewEmptyController1Deep(3, 2, []int{5, 4}, 1, 3, 2, WithDropout(0.6, rnd))
+		},
+	}
+	for _, newController := range newControllers {
+		rnd := rand.New(rand.NewSource(7))
+		c := newController(rnd)
+		wrnd := rand.New(rand.NewSource(8))
+		c.Weights(func(u *Unit) { u.Val = wrnd.Float64() - 0.5 })
+
+		// Reseed before every run so that all runs use the same dropout masks.
+		run := func() float64 {
+			rnd.Seed(9)
+			return Loss(y, ForwardBackward(c, x, y))
+		}
+		run()
+		var grads []float64
+		c.Weights(func(u *Unit) { grads = append(grads, u.Grad) })
+		i := 0
+		c.Weights(func(u *Unit) {
+			x := u.Val
+			h := 1e-5
+			u.Val = x + h
+			lp := run()
+			u.Val = x - h
+			lm := run()
+			u.Val = x
+			// Loss is in bits, whereas the gradients are of the loss in nats.
+			grad := (lp - lm) / (2 * h) * math.Ln2
+			if math.Abs(grad-grads[i]) > 1e-5 {
+				t.Errorf("gradient %d expected %f, got %f", i, grad, grads[i])
+			}
+			i++
+		})
+	}
+}
+
+func TestDropoutBatch(t *testing.T) {
+	c := NewEmptyController1(3, 2, 4, 1, 3, 2, WithDropout(0.5, rand.New(rand.NewSource(10))))
+	rnd := rand.New(rand.NewSource(11))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	batch := make([][2][][]float64, 4)
+	for i := range batch {
+		batch[i][0], batch[i][1] = dropoutSeqs(rnd, 3, 3, 2)
+	}
+	ForwardBackwardBatch(c, batch)
+}
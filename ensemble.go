@@ -0,0 +1,79 @@
+package ntm
+
+import (
+	"math"
+)
+
+// EnsemblePredict runs every controller of cs on the input x, and returns the predictions across time averaged over the controllers.
+// The controllers may differ in architecture, such as in their number of heads or memory size, but must share the same input and output sizes.
+// EnsemblePredict panics with a DimError if x does not match the input size of a controller,
+// or if the outputs of a controller differ in size from those of the first controller.
+// It does not modify the gradients of the controllers.
+func EnsemblePredict(cs []Controller, x [][]float64) [][]float64 {
+	var avg [][]float64
+	for i, c := range cs {
+		pdts := predict(c, x)
+		if i == 0 {
+			avg = pdts
+			continue
+		}
+		for t, p := range pdts {
+			if len(p) != len(avg[t]) {
+				panic(DimError{Axis: "y", Expected: len(avg[t]), Got: len(p)})
+			}
+			for j, v := range p {
+				avg[t][j] += v
+			}
+		}
+	}
+	for _, p := range avg {
+		for j := range p {
+			p[j] /= float64(len(cs))
+		}
+	}
+	return avg
+}
+
+// CompareControllers runs the controllers a and b on the input x, and compares their predictions across time.
+// perStep holds the largest absolute difference between the predictions of a and b at every time instant,
+// and maxAbsDiff is the largest of them.
+// CompareControllers is intended for checking that a modification of a NTM leaves its predictions unchanged,
+// and controllers with dropout differ in their predictions even from their own Clone.
+// It panics with a DimError if x does not match the input size of a controller, or if a and b differ in output size.
+// It does not modify the gradients of the controllers.
+func CompareControllers(a, b Controller, x [][]float64) (maxAbsDiff float64, perStep []float64) {
+	pa := predict(a, x)
+	pb := predict(b, x)
+	perStep = make([]float64, len(x))
+	for t := range pa {
+		if len(pb[t]) != len(pa[t]) {
+			panic(DimError{Axis: "y", Expected: len(pa[t]), Got: len(pb[t])})
+		}
+		for j, v := range pa[t] {
+			perStep[t] = math.Max(perStep[t], math.Abs(v-pb[t][j]))
+		}
+		maxAbsDiff = math.Max(maxAbsDiff, perStep[t])
+	}
+	return maxAbsDiff, perStep
+}
+
+// predict returns the predictions across time of c on the input x,
+// panicking with a DimError if x is empty or does not match the input size of c.
+func predict(c Controller, x [][]float64) [][]float64 {
+	checkInputSize(c, x)
+	return Predictions(forward(c, x))
+}
+
+// checkInputSize panics with a DimError if x is empty or does not match the input size of c.
+func checkInputSize(c Controller, x [][]float64) {
+	if len(x) == 0 {
+		panic(DimError{Axis: "T", Expected: 1, Got: 0})
+	}
+	if s, ok := c.(sizer); ok {
+		for t := range x {
+			if len(x[t]) != s.inputSize() {
+				panic(DimError{Axis: "x", Expected: s.inputSize(), Got: len(x[t])})
+			}
+		}
+	}
+}
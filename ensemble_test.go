@@ -0,0 +1,89 @@
+package ntm
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/fumin/ntm/copytask"
+)
+
+func TestEnsemblePredict(t *testing.T) {
+	vectorSize := 3
+	c := NewEmptyController1(vectorSize+2, vectorSize, 5, 1, 6, 3)
+	rnd := rand.New(rand.NewSource(28))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(3, vectorSize)
+	expected := Predictions(ForwardBackward(c, x, y))
+
+	pdts := EnsemblePredict([]Controller{c, Clone(c)}, x)
+	for tt := range expected {
+		for i := range expected[tt] {
+			if pdts[tt][i] != expected[tt][i] {
+				t.Errorf("[%d][%d] %f != %f", tt, i, pdts[tt][i], expected[tt][i])
+			}
+		}
+	}
+
+	// Controllers of different architectures average their predictions.
+	d := NewEmptyController1Deep(vectorSize+2, vectorSize, []int{4, 4}, 2, 5, 2)
+	d.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	other := Predictions(ForwardBackward(d, x, y))
+	pdts = EnsemblePredict([]Controller{c, d}, x)
+	for tt := range expected {
+		for i := range expected[tt] {
+			if avg := (expected[tt][i] + other[tt][i]) / 2; math.Abs(pdts[tt][i]-avg) > 1e-12 {
+				t.Errorf("[%d][%d] %f != %f", tt, i, pdts[tt][i], avg)
+			}
+		}
+	}
+
+	defer func() {
+		if e, ok := recover().(DimError); !ok || e.Axis != "y" {
+			t.Errorf("expected a DimError on y, got %v", e)
+		}
+	}()
+	EnsemblePredict([]Controller{c, NewEmptyController1(vectorSize+2, vectorSize+1, 5, 1, 6, 3)}, x)
+}
+
+func TestCompareControllers(t *testing.T) {
+	vectorSize := 3
+	c := NewEmptyController1(vectorSize+2, vectorSize, 5, 2, 6, 3)
+	rnd := rand.New(rand.NewSource(41))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, _ := copytask.GenSeq(3, vectorSize)
+
+	maxDiff, perStep := CompareControllers(c, Clone(c), x)
+	if maxDiff != 0 {
+		t.Errorf("a controller differs from its clone by %g", maxDiff)
+	}
+	if len(perStep) != len(x) {
+		t.Fatalf("%d steps, expected %d", len(perStep), len(x))
+	}
+	for tt, d := range perStep {
+		if d != 0 {
+			t.Errorf("[%d] %g != 0", tt, d)
+		}
+	}
+
+	// Perturbing a weight of the clone shows up as a difference in its predictions.
+	d := Clone(c)
+	d.Weights(func(u *Unit) { u.Val += 0.1 })
+	expected := Predictions(forward(c, x))
+	other := Predictions(forward(d, x))
+	maxDiff, perStep = CompareControllers(c, d, x)
+	var expectedMax float64
+	for tt := range expected {
+		var diff float64
+		for i := range expected[tt] {
+			diff = math.Max(diff, math.Abs(expected[tt][i]-other[tt][i]))
+		}
+		if perStep[tt] != diff {
+			t.Errorf("[%d] %g != %g", tt, perStep[tt], diff)
+		}
+		expectedMax = math.Max(expectedMax, diff)
+	}
+	if maxDiff != expectedMax || maxDiff == 0 {
+		t.Errorf("max difference %g, expected %g", maxDiff, expectedMax)
+	}
+}
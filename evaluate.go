@@ -0,0 +1,55 @@
+package ntm
+
+// An EvalReport summarizes the performance of a controller on a dataset.
+type EvalReport struct {
+	Sequences int     // the number of sequences evaluated
+	Loss      float64 // the cross-entropy loss in bits of a sequence, averaged over sequences
+	// BitsPerBit is the cross-entropy loss in bits averaged over every output of every time instant of all sequences.
+	BitsPerBit float64
+	// ExactMatch is the fraction of sequences whose outputs are all correctly predicted after thresholding as in DecodeBits, see ExactMatch.
+	ExactMatch float64
+}
+
+// Evaluate runs a controller on every sequence generated by dataset, until it returns more as false,
+// and reports the aggregated performance of the controller.
+// The x and y returned together with more as false are not evaluated.
+// Evaluate does not modify the gradients of the controller.
+func Evaluate(c Controller, dataset func() (x, y [][]float64, more bool), threshold float64) EvalReport {
+	var r EvalReport
+	var loss float64 = 0
+	outputs := 0
+	var matches float64 = 0
+	for {
+		x, y, more := dataset()
+		if !more {
+			break
+		}
+		machines := forward(c, x)
+		loss += Loss(y, machines)
+		outputs += len(y) * len(y[0])
+		matches += ExactMatch(y, DecodeBits(machines, threshold))
+		r.Sequences++
+	}
+	if r.Sequences == 0 {
+		return r
+	}
+	r.Loss = loss / float64(r.Sequences)
+	r.BitsPerBit = loss / float64(outputs)
+	r.ExactMatch = matches / float64(r.Sequences)
+	return r
+}
+
+// ErrorMap marks the outputs of a NTM that are predicted wrong after thresholding as in DecodeBits,
+// where errs[t][i] reports whether the i-th output at time t differs from the ground truth y[t][i].
+// It is intended for locating systematic failures on binary sequences, such as the last bits of long copies.
+func ErrorMap(y [][]float64, machines []*NTM, threshold float64) [][]bool {
+	bits := DecodeBits(machines, threshold)
+	errs := make([][]bool, len(y))
+	for t := range y {
+		errs[t] = make([]bool, len(y[t]))
+		for i, v := range y[t] {
+			errs[t][i] = float64(bits[t][i]) != v
+		}
+	}
+	return errs
+}
@@ -0,0 +1,101 @@
+package ntm
+
+import (
+	"math"
+	"testing"
+)
+
+// fixedController is a controller whose outputs are given by predict, regardless of its weights.
+type fixedController struct {
+	*controller1
+	predict func(x []float64) []float64
+	y       []Unit
+}
+
+func (c *fixedController) Forward(reads []*memRead, x []float64) Controller {
+	d := fixedController{
+		controller1: c.controller1.Forward(reads, x).(*controller1),
+		predict:     c.predict,
+	}
+	for _, v := range c.predict(x) {
+		d.y = append(d.y, Unit{Val: v})
+	}
+	return &d
+}
+
+func (c *fixedController) Y() []Unit {
+	return c.y
+}
+
+func TestEvaluate(t *testing.T) {
+	// The controller predicts 0.75 for inputs of 1, and 0.25 otherwise.
+	c := &fixedController{
+		controller1: NewEmptyController1(2, 2, 3, 1, 4, 2),
+		predict: func(x []float64) []float64 {
+			p := make([]float64, len(x))
+			for i, v := range x {
+				p[i] = 0.25
+				if v == 1 {
+					p[i] = 0.75
+				}
+			}
+			return p
+		},
+	}
+	seqs := [][2][][]float64{
+		{{{1, 0}, {0, 1}}, {{1, 0}, {0, 1}}}, // predicted exactly
+		{{{1, 1}, {0, 0}}, {{1, 1}, {0, 1}}}, // one wrong output
+	}
+	i := 0
+	dataset := func() ([][]float64, [][]float64, bool) {
+		if i == len(seqs) {
+			return nil, nil, false
+		}
+		i++
+		return seqs[i-1][0], seqs[i-1][1], true
+	}
+	r := Evaluate(c, dataset, 0.5)
+
+	right, wrong := -math.Log2(0.75), -math.Log2(0.25)
+	expectedLoss := (4*right + (3*right + wrong)) / 2
+	if r.Sequences != 2 {
+		t.Errorf("%d sequences", r.Sequences)
+	}
+	if math.Abs(r.Loss-expectedLoss) > 1e-12 {
+		t.Errorf("loss %f, expected %f", r.Loss, expectedLoss)
+	}
+	if math.Abs(r.BitsPerBit-expectedLoss*2/8) > 1e-12 {
+		t.Errorf("bits-per-bit %f, expected %f", r.BitsPerBit, expectedLoss*2/8)
+	}
+	if r.ExactMatch != 0.5 {
+		t.Errorf("exact match %f, expected 0.5", r.ExactMatch)
+	}
+	c.Weights(func(u *Unit) {
+		if u.Grad != 0 {
+			t.Fatalf("Evaluate modified the gradients")
+		}
+	})
+
+	if r := Evaluate(c, func() ([][]float64, [][]float64, bool) { return nil, nil, false }, 0.5); r.Sequences != 0 || r.Loss != 0 {
+		t.Errorf("empty dataset %+v", r)
+	}
+}
+
+func TestErrorMap(t *testing.T) {
+	y := [][]float64{{0, 1, 1}, {1, 0, 0}, {0, 0, 1}}
+	pdts := [][]float64{{0.1, 0.9, 0.4}, {0.8, 0.2, 0.6}, {0.3, 0.1, 0.7}}
+	expected := [][]bool{{false, false, true}, {false, false, true}, {false, false, false}}
+	errs := ErrorMap(y, predictionMachines(pdts), 0.5)
+	for tt := range expected {
+		for i := range expected[tt] {
+			if errs[tt][i] != expected[tt][i] {
+				t.Errorf("[%d][%d] %t != %t", tt, i, errs[tt][i], expected[tt][i])
+			}
+		}
+	}
+
+	// A higher threshold turns the prediction 0.8 into a 0.
+	if errs := ErrorMap(y, predictionMachines(pdts), 0.85); !errs[1][0] || errs[1][2] {
+		t.Errorf("errors %v at a threshold of 0.85", errs)
+	}
+}
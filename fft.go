@@ -0,0 +1,127 @@
+package ntm
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// fftMinShifts is the smallest number of shifts of a softmax shift for which the circular convolution is computed by FFT.
+// Below it, the direct method which is O(n) per shift is faster, see BenchmarkShiftConvolution.
+const fftMinShifts = 16
+
+// useFFT reports whether the circular convolution of weights over n memory locations with a distribution over numShifts shifts should be computed by FFT.
+func useFFT(n, numShifts int) bool {
+	return numShifts >= fftMinShifts && isPowerOfTwo(n)
+}
+
+func isPowerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+// fft computes in place the discrete Fourier transform of a, whose length must be a power of two.
+// If inverse is true, the inverse transform including the 1/n normalization is computed instead.
+func fft(a []complex128, inverse bool) {
+	n := len(a)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Rect(1, sign*2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			var wk complex128 = 1
+			for k := 0; k < size/2; k++ {
+				u := a[start+k]
+				v := a[start+k+size/2] * wk
+				a[start+k] = u + v
+				a[start+k+size/2] = u - v
+				wk *= w
+			}
+		}
+	}
+
+	if inverse {
+		for i := range a {
+			a[i] /= complex(float64(n), 0)
+		}
+	}
+}
+
+// circularConvolve returns the circular convolution c[i] = sum_j a[j]*b[(i-j) mod n] of two real sequences
+// whose length n is a power of two.
+// Both sequences are transformed by a single complex FFT, by packing them into the real and imaginary parts of one sequence.
+func circularConvolve(a, b []float64) []float64 {
+	n := len(a)
+	z := make([]complex128, n)
+	for i := range z {
+		z[i] = complex(a[i], b[i])
+	}
+	fft(z, false)
+
+	prod := make([]complex128, n)
+	for k := range prod {
+		zk, znk := z[k], cmplx.Conj(z[(n-k)%n])
+		ak := (zk + znk) / 2
+		bk := (zk - znk) / complex(0, 2)
+		prod[k] = ak * bk
+	}
+	fft(prod, true)
+
+	c := make([]float64, n)
+	for i, v := range prod {
+		c[i] = real(v)
+	}
+	return c
+}
+
+// shiftDirect returns the weights w shifted by the distribution p, where p[k] is the probability of shifting by k-r locations, r = (len(p)-1)/2.
+// It takes O(len(w)*len(p)) time.
+func shiftDirect(w, p []float64) []float64 {
+	n := len(w)
+	r := (len(p) - 1) / 2
+	top := make([]float64, n)
+	for i := range top {
+		for k, pk := range p {
+			top[i] += pk * w[mod(i-(k-r), n)]
+		}
+	}
+	return top
+}
+
+// shiftFFT is the same as shiftDirect, except that it takes O(n*log(n)) time by FFT.
+// The length of w must be a power of two.
+func shiftFFT(w, p []float64) []float64 {
+	return circularConvolve(w, shiftKernel(p, len(w)))
+}
+
+// shiftKernel returns the kernel over n memory locations of the shift distribution p,
+// where p[k] is the probability of shifting by k-r locations, r = (len(p)-1)/2.
+func shiftKernel(p []float64, n int) []float64 {
+	r := (len(p) - 1) / 2
+	s := make([]float64, n)
+	for k, pk := range p {
+		s[mod(k-r, n)] += pk
+	}
+	return s
+}
+
+// reversed returns the sequence x[(-i) mod n].
+func reversed(x []float64) []float64 {
+	n := len(x)
+	rev := make([]float64, n)
+	for i := range rev {
+		rev[i] = x[mod(-i, n)]
+	}
+	return rev
+}
@@ -0,0 +1,131 @@
+package ntm
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCircularConvolve(t *testing.T) {
+	rnd := rand.New(rand.NewSource(17))
+	for _, n := range []int{1, 2, 8, 64} {
+		a := make([]float64, n)
+		b := make([]float64, n)
+		for i := range a {
+			a[i] = rnd.Float64() - 0.5
+			b[i] = rnd.Float64() - 0.5
+		}
+		c := circularConvolve(a, b)
+		for i := range c {
+			var expected float64 = 0
+			for j := range a {
+				expected += a[j] * b[mod(i-j, n)]
+			}
+			if math.Abs(c[i]-expected) > 1e-12 {
+				t.Errorf("n %d: c[%d] %f, expected %f", n, i, c[i], expected)
+			}
+		}
+	}
+}
+
+func TestLogitShiftFFT(t *testing.T) {
+	rnd := rand.New(rand.NewSource(18))
+	n := 64
+	for _, numShifts := range []int{33, 65, 129} {
+		if !useFFT(n, numShifts) {
+			t.Fatalf("FFT is not used for %d shifts", numShifts)
+		}
+		w := make([]float64, n)
+		p := make([]float64, numShifts)
+		for i := range w {
+			w[i] = rnd.Float64()
+		}
+		for k := range p {
+			p[k] = rnd.Float64()
+		}
+		direct := shiftDirect(w, p)
+		for i, v := range shiftFFT(w, p) {
+			if math.Abs(v-direct[i]) > 1e-12 {
+				t.Errorf("%d shifts: [%d] %f, expected %f", numShifts, i, v, direct[i])
+			}
+		}
+
+		wg := &gatedWeighting{Top: make([]Unit, n)}
+		for i := range wg.Top {
+			wg.Top[i].Val = w[i]
+		}
+		logits := make([]Unit, numShifts)
+		for k := range logits {
+			logits[k].Val = math.Log(p[k])
+		}
+		sw := newLogitShiftedWeighting(logits, wg)
+		for i := range sw.Top {
+			sw.Top[i].Grad = float64(i%7) - 3
+		}
+		sw.Backward()
+		fftW, fftL := unitGrads(wg.Top), unitGrads(logits)
+
+		// Compare the gradients against the direct method.
+		g := unitGrads(sw.Top)
+		var sum float64 = 0
+		for _, pk := range p {
+			sum += pk
+		}
+		probs := make([]float64, numShifts)
+		for k := range probs {
+			probs[k] = p[k] / sum
+		}
+		r := (numShifts - 1) / 2
+		for j := range fftW {
+			var expected float64 = 0
+			for k, pk := range probs {
+				expected += pk * g[mod(j+(k-r), n)]
+			}
+			if math.Abs(fftW[j]-expected) > 1e-12 {
+				t.Errorf("%d shifts: weight grad [%d] %f, expected %f", numShifts, j, fftW[j], expected)
+			}
+		}
+		pGrad := make([]float64, numShifts)
+		var mean float64 = 0
+		for k := range pGrad {
+			for i := range g {
+				pGrad[k] += g[i] * w[mod(i-(k-r), n)]
+			}
+			mean += probs[k] * pGrad[k]
+		}
+		for k := range fftL {
+			expected := probs[k] * (pGrad[k] - mean)
+			if math.Abs(fftL[k]-expected) > 1e-12 {
+				t.Errorf("%d shifts: logit grad [%d] %f, expected %f", numShifts, k, fftL[k], expected)
+			}
+		}
+	}
+}
+
+// BenchmarkShiftConvolution compares the direct and FFT methods of softmax shifting at a memory size of 1024.
+// The FFT method wins for about 16 shifts or more, which determines fftMinShifts.
+func BenchmarkShiftConvolution(b *testing.B) {
+	n := 1024
+	rnd := rand.New(rand.NewSource(19))
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = rnd.Float64()
+	}
+	for _, numShifts := range []int{3, 9, 17, 33, 65, 129} {
+		p := make([]float64, numShifts)
+		for k := range p {
+			p[k] = 1 / float64(numShifts)
+		}
+		b.Run(fmt.Sprintf("direct/%d", numShifts), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				shiftDirect(w, p)
+			}
+		})
+		b.Run(fmt.Sprintf("fft/%d", numShifts), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				shiftFFT(w, p)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package ntm
+
+import (
+	"fmt"
+)
+
+// A flopCounter estimates the number of multiply-adds of a controller at a time instant.
+type flopCounter interface {
+	// stepFLOPs returns the multiply-adds of the dense layers of the controller, and those of the memory operations of its heads.
+	stepFLOPs() (dense, addressing int64)
+}
+
+// ForwardFLOPs estimates the number of multiply-adds in a forward pass of c over seqLen time instants,
+// counting one multiply-add for every weight of the dense layers of c,
+// and those of the memory addressing, reads and writes of every head on the n×m memory.
+// The estimate of the memory operations assumes DefaultAddresser, and counts an exponential, logarithm or power as a single operation.
+// ForwardFLOPs panics if c does not support estimating its FLOPs.
+func ForwardFLOPs(c Controller, seqLen int) int64 {
+	fc, ok := c.(flopCounter)
+	if !ok {
+		panic(fmt.Sprintf("ntm: %T does not support ForwardFLOPs", c))
+	}
+	dense, addressing := fc.stepFLOPs()
+	return int64(seqLen) * (dense + addressing)
+}
+
+// headFLOPs returns the multiply-adds of the dense projection of cols inputs onto the units of numHeads heads on a memory whose rows have size m.
+// Heads sharing their weights still compute their units separately.
+func (cfg controllerConfig) headFLOPs(numHeads, m, cols int) int64 {
+	var flops int64
+	for i := 0; i < numHeads; i++ {
+		flops += int64(cfg.headConfig(i).numUnits(m) * cols)
+	}
+	return flops
+}
+
+// addressingFLOPs returns the multiply-adds of the memory operations of numHeads heads on a n×m memory at a time instant.
+func (cfg controllerConfig) addressingFLOPs(numHeads, n, m int) int64 {
+	var flops int64
+	for i := 0; i < numHeads; i++ {
+		h := cfg.headConfig(i)
+		k := h.keyWidth(m)
+		// Content addressing computes the similarity of the key with every memory row, followed by a softmax.
+		sim := 3 * k
+		if h.similarity == DotProductSimilarity {
+			sim = k
+		}
+		addr := n * (sim + 2)
+		if h.mode != ContentOnly {
+			// Gating, shifting and sharpening.
+			shift := 2
+			if h.shiftLogits {
+				shift = h.numShiftUnits()
+			}
+			addr += n * (1 + shift + 2)
+		}
+		// Every weighting is addressed and read, and the first one also erases and adds.
+		flops += int64(h.numWeightings() * (addr + n*m))
+		flops += int64(2 * n * m)
+	}
+	return flops
+}
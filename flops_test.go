@@ -0,0 +1,23 @@
+package ntm
+
+import (
+	"testing"
+)
+
+func TestForwardFLOPs(t *testing.T) {
+	c := NewEmptyController1(4, 2, 3, 1, 4, 3)
+	// The dense layers have 3*(3+4+1) hidden, 2*(3+1) output and 13*(3+1) head weights.
+	// Addressing takes 4*(9+2) multiply-adds for content, 4*5 for location, and 3*4*3 for reading and writing.
+	if f := ForwardFLOPs(c, 5); f != 5*(84+100) {
+		t.Errorf("%d FLOPs, expected %d", f, 5*(84+100))
+	}
+	if d := NewEmptyController1Deep(4, 2, []int{3}, 1, 4, 3); ForwardFLOPs(d, 5) != ForwardFLOPs(c, 5) {
+		t.Errorf("a single layer controller1Deep has %d FLOPs, expected %d", ForwardFLOPs(d, 5), ForwardFLOPs(c, 5))
+	}
+
+	small, _ := NewEmptyController1(10, 8, 100, 1, 128, 20).stepFLOPs()
+	large, _ := NewEmptyController1(10, 8, 200, 1, 128, 20).stepFLOPs()
+	if r := float64(large) / float64(small); r < 1.95 || r > 2.05 {
+		t.Errorf("doubling the hidden layer multiplies the dense FLOPs by %f", r)
+	}
+}
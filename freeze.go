@@ -0,0 +1,99 @@
+package ntm
+
+import (
+	"fmt"
+)
+
+// The weight groups of a controller that can be frozen by SetTrainable.
+const (
+	// GroupController is the group of the weights of the controller network, excluding those projecting onto the memory heads.
+	GroupController = "controller"
+	// GroupHeads is the group of the weights projecting onto the memory heads.
+	GroupHeads = "heads"
+	// GroupMemoryInit is the group of the bias values of the initial memory and head weights.
+	GroupMemoryInit = "memoryInit"
+)
+
+// A weightGrouper is a Controller whose weights are divided into the groups GroupController, GroupHeads and GroupMemoryInit.
+type weightGrouper interface {
+	// weightGroup calls f with every weight of a group.
+	weightGroup(group string, f func(*Unit))
+	// frozenGroups returns the set of frozen groups, which the caller may modify.
+	frozenGroups() map[string]bool
+}
+
+// SetTrainable sets whether the weights of a group, one of GroupController, GroupHeads and GroupMemoryInit,
+// are updated by the optimizers of this package.
+// The gradients of frozen weights are zeroed before each update, and their values are restored after it,
+// so that the momentum accumulated before freezing does not move them either.
+func SetTrainable(c Controller, group string, trainable bool) error {
+	g, err := groupedController(c, group)
+	if err != nil {
+		return err
+	}
+	if trainable {
+		delete(g.frozenGroups(), group)
+	} else {
+		g.frozenGroups()[group] = true
+	}
+	return nil
+}
+
+// groupedController returns c as a weightGrouper, or an error if c does not support weight groups or group is unknown.
+func groupedController(c Controller, group string) (weightGrouper, error) {
+	switch group {
+	case GroupController, GroupHeads, GroupMemoryInit:
+	default:
+		return nil, fmt.Errorf("unknown weight group %q", group)
+	}
+	g, ok := c.(weightGrouper)
+	if !ok {
+		return nil, fmt.Errorf("controller %T does not support weight groups", c)
+	}
+	return g, nil
+}
+
+// zeroFrozenGrads zeroes the gradients of the frozen and pruned weights of a controller.
+func zeroFrozenGrads(c Controller) {
+	zeroPrunedWeights(c)
+	g, ok := c.(weightGrouper)
+	if !ok {
+		return
+	}
+	for group := range g.frozenGroups() {
+		g.weightGroup(group, func(u *Unit) { u.Grad = 0 })
+	}
+}
+
+// frozenVals returns the values of the frozen weights of a controller, in the order of the groups
+// GroupController, GroupHeads and GroupMemoryInit, or nil if no weights are frozen.
+func frozenVals(c Controller) []float64 {
+	g, ok := c.(weightGrouper)
+	if !ok || len(g.frozenGroups()) == 0 {
+		return nil
+	}
+	var vals []float64
+	for _, group := range []string{GroupController, GroupHeads, GroupMemoryInit} {
+		if g.frozenGroups()[group] {
+			g.weightGroup(group, func(u *Unit) { vals = append(vals, u.Val) })
+		}
+	}
+	return vals
+}
+
+// restoreFrozen sets the values of the frozen weights of a controller to vals, as returned by frozenVals.
+func restoreFrozen(c Controller, vals []float64) {
+	if vals == nil {
+		return
+	}
+	g := c.(weightGrouper)
+	i := 0
+	for _, group := range []string{GroupController, GroupHeads, GroupMemoryInit} {
+		if g.frozenGroups()[group] {
+			g.weightGroup(group, func(u *Unit) {
+				u.Val = vals[i]
+				i++
+			})
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package ntm
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSetTrainable(t *testing.T) {
+	controllers := []Controller{
+		NewEmptyController1(3, 2, 4, 2, 5, 3, WithTiedEraseAdd()),
+		NewEmptyController1Deep(3, 2, []int{4, 3}, 1, 5, 3),
+	}
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}, {1, 1, 0}}
+	y := [][]float64{{0, 1}, {1, 0}, {1, 1}}
+	groups := []string{GroupController, GroupHeads, GroupMemoryInit}
+	for _, c := range controllers {
+		rnd := rand.New(rand.NewSource(14))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+		g := c.(weightGrouper)
+		numWeights := 0
+		for _, group := range groups {
+			g.weightGroup(group, func(u *Unit) { numWeights++ })
+		}
+		if numWeights != c.NumWeights() {
+			t.Fatalf("%T: %d weights in groups, expected %d", c, numWeights, c.NumWeights())
+		}
+
+		if err := SetTrainable(c, GroupController, false); err != nil {
+			t.Fatalf("%v", err)
+		}
+		before := make(map[string][]float64)
+		for _, group := range groups {
+			g.weightGroup(group, func(u *Unit) { before[group] = append(before[group], u.Val) })
+		}
+		NewRMSProp(c).Train(x, y, 0.95, 0.5, 1e-3, 1e-3)
+		for _, group := range groups {
+			changed := 0
+			i := 0
+			g.weightGroup(group, func(u *Unit) {
+				if u.Val != before[group][i] {
+					changed++
+				}
+				i++
+			})
+			if group == GroupController && changed != 0 {
+				t.Errorf("%T: %d frozen weights changed", c, changed)
+			}
+			if group != GroupController && changed == 0 {
+				t.Errorf("%T: no weights of group %s changed", c, group)
+			}
+		}
+
+		if err := SetTrainable(c, GroupController, true); err != nil {
+			t.Fatalf("%v", err)
+		}
+		i := 0
+		NewRMSProp(c).Train(x, y, 0.95, 0.5, 1e-3, 1e-3)
+		changed := 0
+		g.weightGroup(GroupController, func(u *Unit) {
+			if u.Val != before[GroupController][i] {
+				changed++
+			}
+			i++
+		})
+		if changed == 0 {
+			t.Errorf("%T: unfrozen weights did not change", c)
+		}
+	}
+
+	if err := SetTrainable(controllers[0], "memory", false); err == nil {
+		t.Errorf("expected error for unknown group")
+	}
+}
+
+func TestSetTrainableAfterMomentum(t *testing.T) {
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}, {1, 1, 0}}
+	y := [][]float64{{0, 1}, {1, 0}, {1, 1}}
+	optimizers := []func(Controller) Optimizer{
+		func(c Controller) Optimizer { return NewSGDMomentum(c) },
+		func(c Controller) Optimizer { return NewRMSProp(c) },
+	}
+	for _, newOpt := range optimizers {
+		var c Controller = NewEmptyController1(3, 2, 4, 2, 5, 3)
+		rnd := rand.New(rand.NewSource(52))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+		opt := newOpt(c)
+		for i := 0; i < 3; i++ {
+			opt.Step(ForwardBackward(c, x, y))
+		}
+
+		if err := SetTrainable(c, GroupController, false); err != nil {
+			t.Fatalf("%v", err)
+		}
+		g := c.(weightGrouper)
+		var before []float64
+		g.weightGroup(GroupController, func(u *Unit) { before = append(before, u.Val) })
+		valsBefore := Snapshot(c)
+		opt.Step(ForwardBackward(c, x, y))
+		i := 0
+		g.weightGroup(GroupController, func(u *Unit) {
+			if u.Val != before[i] {
+				t.Errorf("%s: frozen weight %d changed from %f to %f", opt.Name(), i, before[i], u.Val)
+			}
+			i++
+		})
+		changed := 0
+		for i, v := range Snapshot(c) {
+			if v != valsBefore[i] {
+				changed++
+			}
+		}
+		if changed == 0 {
+			t.Errorf("%s: no weights changed", opt.Name())
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package ntm
+
+import (
+	"math"
+	"runtime"
+	"sync"
+)
+
+// A GradientCheck compares the gradient of a weight computed by backpropagation with its finite difference estimate.
+type GradientCheck struct {
+	Analytic float64 // the gradient computed by ForwardBackward
+	Numeric  float64 // the forward difference estimate of the gradient
+}
+
+// Error returns the absolute difference between the analytic and numeric gradients,
+// relative to the larger of their magnitudes if it exceeds 1.
+func (g GradientCheck) Error() float64 {
+	return math.Abs(g.Analytic-g.Numeric) / math.Max(1, math.Max(math.Abs(g.Analytic), math.Abs(g.Numeric)))
+}
+
+// CheckGradients compares the gradients of the loss of c on the input x and output y with forward difference estimates.
+// The checks are in the order of Controller.Weights.
+// CheckGradients overwrites the gradients of c, and restores its weights after perturbing them.
+// Controllers with dropout give meaningless estimates, as every forward pass draws new dropout masks.
+func CheckGradients(c Controller, x, y [][]float64) []GradientCheck {
+	checks := analyticGradients(c, x, y)
+	l := Loss(y, forward(c, x))
+	for i, u := range weightUnits(c) {
+		checks[i].Numeric = numericGradient(c, u, x, y, l)
+	}
+	return checks
+}
+
+// CheckGradientsParallel is similar to CheckGradients, except that the weights are perturbed concurrently on GOMAXPROCS workers.
+// Each worker perturbs the weights of its own copy of the controller, and the results are identical to those of CheckGradients.
+// Controllers which cannot be copied are checked sequentially.
+func CheckGradientsParallel(c Controller, x, y [][]float64) []GradientCheck {
+	cl, ok := c.(cloner)
+	numWorkers := runtime.GOMAXPROCS(0)
+	if !ok || numWorkers <= 1 {
+		return CheckGradients(c, x, y)
+	}
+
+	checks := analyticGradients(c, x, y)
+	l := Loss(y, forward(c, x))
+	jobs := make(chan int, len(checks))
+	for i := range checks {
+		jobs <- i
+	}
+	close(jobs)
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		// Clone outside of the worker, as cloning may draw from the random source of the controller.
+		wc := cl.clone()
+		go func() {
+			defer wg.Done()
+			units := weightUnits(wc)
+			for i := range jobs {
+				checks[i].Numeric = numericGradient(wc, units[i], x, y, l)
+			}
+		}()
+	}
+	wg.Wait()
+	return checks
+}
+
+// analyticGradients returns checks holding the gradients of the loss of c on x and y computed by ForwardBackward.
+func analyticGradients(c Controller, x, y [][]float64) []GradientCheck {
+	ForwardBackward(c, x, y)
+	checks := make([]GradientCheck, 0, c.NumWeights())
+	c.Weights(func(u *Unit) { checks = append(checks, GradientCheck{Analytic: u.Grad}) })
+	return checks
+}
+
+// weightUnits returns the weights of c in the order of Controller.Weights.
+func weightUnits(c Controller) []*Unit {
+	units := make([]*Unit, 0, c.NumWeights())
+	c.Weights(func(u *Unit) { units = append(units, u) })
+	return units
+}
+
+// numericGradient returns the forward difference estimate of the gradient of the loss of c with respect to the weight u,
+// where l is the loss in bits at the current weights.
+func numericGradient(c Controller, u *Unit, x, y [][]float64, l float64) float64 {
+	v := u.Val
+	h := machineEpsilonSqrt * math.Max(math.Abs(v), 1)
+	vph := v + h
+	u.Val = vph
+	lph := Loss(y, forward(c, x))
+	u.Val = v
+	// ForwardBackward computes the gradients of the loss in nats.
+	return (lph - l) * math.Ln2 / (vph - v)
+}
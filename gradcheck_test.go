@@ -0,0 +1,53 @@
+package ntm
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/fumin/ntm/copytask"
+)
+
+func TestCheckGradients(t *testing.T) {
+	vectorSize := 2
+	c := NewEmptyController1(vectorSize+2, vectorSize, 3, 2, 4, 3)
+	rnd := rand.New(rand.NewSource(25))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(2, vectorSize)
+	before := Snapshot(c)
+
+	checks := CheckGradients(c, x, y)
+	if len(checks) != c.NumWeights() {
+		t.Fatalf("%d checks, expected %d", len(checks), c.NumWeights())
+	}
+	for i, g := range checks {
+		if g.Error() > 1e-5 {
+			t.Errorf("weight %d: analytic gradient %f, numeric %f", i, g.Analytic, g.Numeric)
+		}
+	}
+	for i, v := range Snapshot(c) {
+		if v != before[i] {
+			t.Fatalf("weight %d changed from %f to %f", i, before[i], v)
+		}
+	}
+}
+
+func TestCheckGradientsParallel(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	vectorSize := 2
+	c := NewEmptyController1(vectorSize+2, vectorSize, 3, 2, 4, 3)
+	rnd := rand.New(rand.NewSource(26))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(2, vectorSize)
+
+	serial := CheckGradients(c, x, y)
+	parallel := CheckGradientsParallel(c, x, y)
+	if len(parallel) != len(serial) {
+		t.Fatalf("%d checks, expected %d", len(parallel), len(serial))
+	}
+	for i := range serial {
+		if parallel[i] != serial[i] {
+			t.Errorf("weight %d: parallel %+v, serial %+v", i, parallel[i], serial[i])
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package ntm
+
+import (
+	"math"
+	"math/rand"
+)
+
+// GradientNoise adds annealed Gaussian noise to the gradients of a controller, as described in
+// Neelakantan, A., et al. (2015). Adding gradient noise improves learning for very deep networks. arXiv preprint arXiv:1511.06807.
+// At step t, the noise has variance Eta / (1+t)^Gamma.
+type GradientNoise struct {
+	Eta   float64
+	Gamma float64
+	Rand  *rand.Rand
+	T     int // the number of steps taken so far
+}
+
+// NewGradientNoise returns a GradientNoise that draws its noise from r.
+func NewGradientNoise(eta, gamma float64, r *rand.Rand) *GradientNoise {
+	n := GradientNoise{
+		Eta:   eta,
+		Gamma: gamma,
+		Rand:  r,
+	}
+	return &n
+}
+
+// Variance returns the variance of the noise at the current step.
+func (n *GradientNoise) Variance() float64 {
+	return n.Eta / math.Pow(1+float64(n.T), n.Gamma)
+}
+
+// Apply adds noise to the gradients of all weights of c, and then advances the step counter.
+func (n *GradientNoise) Apply(c Controller) {
+	sigma := math.Sqrt(n.Variance())
+	c.Weights(func(w *Unit) { w.Grad += sigma * n.Rand.NormFloat64() })
+	n.T++
+}
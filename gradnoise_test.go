@@ -0,0 +1,53 @@
+package ntm
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/fumin/ntm/copytask"
+)
+
+func TestGradientNoiseDecays(t *testing.T) {
+	n := NewGradientNoise(0.3, 0.55, rand.New(rand.NewSource(1)))
+	c := NewEmptyController1(2, 2, 2, 1, 2, 2)
+	prev := n.Variance()
+	for i := 0; i < 10; i++ {
+		n.Apply(c)
+		if n.T != i+1 {
+			t.Fatalf("expected step %d, got %d", i+1, n.T)
+		}
+		v := n.Variance()
+		if v >= prev {
+			t.Fatalf("variance did not decay at step %d: %f >= %f", n.T, v, prev)
+		}
+		prev = v
+	}
+}
+
+func TestGradientNoiseZeroEta(t *testing.T) {
+	vectorSize := 3
+	x, y := copytask.GenSeq(3, vectorSize)
+	newController := func() Controller {
+		rnd := rand.New(rand.NewSource(4))
+		c := NewEmptyController1(vectorSize+2, vectorSize, 5, 1, 6, 3)
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+		return c
+	}
+
+	plain := NewRMSProp(newController())
+	noisy := NewRMSProp(newController())
+	noisy.Noise = NewGradientNoise(0, 0.55, rand.New(rand.NewSource(1)))
+	for i := 0; i < 3; i++ {
+		plain.Train(x, y, 0.95, 0.5, 1e-3, 1e-3)
+		noisy.Train(x, y, 0.95, 0.5, 1e-3, 1e-3)
+	}
+	ws := make([]float64, 0, plain.C.NumWeights())
+	plain.C.Weights(func(u *Unit) { ws = append(ws, u.Val) })
+	i := 0
+	noisy.C.Weights(func(u *Unit) {
+		if u.Val != ws[i] {
+			t.Fatalf("weight %d differs with zero noise: %v != %v", i, u.Val, ws[i])
+		}
+		i++
+	})
+}
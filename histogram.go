@@ -0,0 +1,48 @@
+package ntm
+
+import (
+	"math"
+)
+
+// WeightHistogram bins the values of all internal weights of a controller into bins equally wide bins
+// spanning the smallest to the largest weight.
+// It returns the bins+1 bin edges and the number of weights in each bin.
+// The last bin includes its right edge.
+func WeightHistogram(c Controller, bins int) (edges []float64, counts []int) {
+	return histogram(c, bins, func(u *Unit) float64 { return u.Val })
+}
+
+// GradHistogram is like WeightHistogram, except that it bins the gradients of the weights.
+// It is intended to be called after a backward pass such as ForwardBackward.
+func GradHistogram(c Controller, bins int) (edges []float64, counts []int) {
+	return histogram(c, bins, func(u *Unit) float64 { return u.Grad })
+}
+
+func histogram(c Controller, bins int, value func(*Unit) float64) ([]float64, []int) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	c.Weights(func(u *Unit) {
+		v := value(u)
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	})
+
+	edges := make([]float64, bins+1)
+	width := (hi - lo) / float64(bins)
+	for i := range edges {
+		edges[i] = lo + float64(i)*width
+	}
+	edges[bins] = hi
+
+	counts := make([]int, bins)
+	c.Weights(func(u *Unit) {
+		i := 0
+		if width > 0 {
+			i = int((value(u) - lo) / width)
+		}
+		if i >= bins {
+			i = bins - 1
+		}
+		counts[i]++
+	})
+	return edges, counts
+}
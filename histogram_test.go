@@ -0,0 +1,42 @@
+package ntm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWeightHistogram(t *testing.T) {
+	c := NewEmptyController1(2, 2, 3, 1, 4, 2)
+	i := 0
+	expectedCounts := make([]int, 3)
+	c.Weights(func(u *Unit) {
+		// Weights are -1, 0, 1, 2, -1, 0, 1, 2...
+		v := i%4 - 1
+		u.Val = float64(v)
+		u.Grad = 0.5
+		if v == 2 {
+			// The last bin includes its right edge.
+			v = 1
+		}
+		expectedCounts[v+1]++
+		i++
+	})
+
+	edges, counts := WeightHistogram(c, 3)
+	expectedEdges := []float64{-1, 0, 1, 2}
+	for i, e := range edges {
+		if math.Abs(e-expectedEdges[i]) > 1e-12 {
+			t.Errorf("edge %d: %f, expected %f", i, e, expectedEdges[i])
+		}
+	}
+	for i, n := range counts {
+		if n != expectedCounts[i] {
+			t.Errorf("count %d: %d, expected %d", i, n, expectedCounts[i])
+		}
+	}
+
+	_, counts = GradHistogram(c, 2)
+	if counts[0] != c.NumWeights() || counts[1] != 0 {
+		t.Errorf("constant gradients binned as %v", counts)
+	}
+}
@@ -0,0 +1,78 @@
+package ntm
+
+import (
+	"math"
+	"sort"
+)
+
+// A LossTracker keeps the most recent losses of a training process in a ring buffer.
+type LossTracker struct {
+	buf  []float64
+	next int // the index in buf of the next loss to be added
+	full bool
+}
+
+// NewLossTracker returns a LossTracker that keeps the most recent capacity losses.
+func NewLossTracker(capacity int) *LossTracker {
+	return &LossTracker{buf: make([]float64, capacity)}
+}
+
+// Add records a loss, discarding the oldest one if the tracker is full.
+func (lt *LossTracker) Add(loss float64) {
+	lt.buf[lt.next] = loss
+	lt.next++
+	if lt.next == len(lt.buf) {
+		lt.next = 0
+		lt.full = true
+	}
+}
+
+// Len returns the number of losses kept.
+func (lt *LossTracker) Len() int {
+	if lt.full {
+		return len(lt.buf)
+	}
+	return lt.next
+}
+
+// Values returns the losses kept, from the oldest to the most recent.
+func (lt *LossTracker) Values() []float64 {
+	vals := make([]float64, 0, lt.Len())
+	if lt.full {
+		vals = append(vals, lt.buf[lt.next:]...)
+	}
+	return append(vals, lt.buf[:lt.next]...)
+}
+
+// MovingAverage returns the mean of the most recent window losses.
+// If fewer than window losses are kept, the mean of all of them is returned.
+// MovingAverage returns NaN if no losses are kept.
+func (lt *LossTracker) MovingAverage(window int) float64 {
+	vals := lt.Values()
+	if window < len(vals) {
+		vals = vals[len(vals)-window:]
+	}
+	if len(vals) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, v := range vals {
+		sum += v
+	}
+	return sum / float64(len(vals))
+}
+
+// Percentile returns the p-th percentile, 0 <= p <= 100, of the losses kept.
+// Values between the closest ranks are linearly interpolated.
+// Percentile returns NaN if no losses are kept.
+func (lt *LossTracker) Percentile(p float64) float64 {
+	vals := lt.Values()
+	if len(vals) == 0 {
+		return math.NaN()
+	}
+	sort.Float64s(vals)
+	rank := p / 100 * float64(len(vals)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return vals[lo] + (rank-float64(lo))*(vals[hi]-vals[lo])
+}
@@ -0,0 +1,60 @@
+package ntm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLossTrackerMovingAverage(t *testing.T) {
+	lt := NewLossTracker(4)
+	if !math.IsNaN(lt.MovingAverage(2)) {
+		t.Errorf("expected NaN for an empty tracker")
+	}
+	for _, l := range []float64{1, 2, 3} {
+		lt.Add(l)
+	}
+	if a := lt.MovingAverage(2); a != 2.5 {
+		t.Errorf("moving average %f, expected 2.5", a)
+	}
+	if a := lt.MovingAverage(10); a != 2 {
+		t.Errorf("moving average %f, expected 2", a)
+	}
+
+	for _, l := range []float64{4, 5, 6} {
+		lt.Add(l)
+	}
+	if lt.Len() != 4 {
+		t.Errorf("length %d, expected 4", lt.Len())
+	}
+	expected := []float64{3, 4, 5, 6}
+	for i, v := range lt.Values() {
+		if v != expected[i] {
+			t.Errorf("[%d] %f != %f", i, v, expected[i])
+		}
+	}
+	if a := lt.MovingAverage(4); a != 4.5 {
+		t.Errorf("moving average %f, expected 4.5", a)
+	}
+}
+
+func TestLossTrackerPercentile(t *testing.T) {
+	lt := NewLossTracker(5)
+	for _, l := range []float64{9, 1, 7, 3, 5} {
+		lt.Add(l)
+	}
+	tests := []struct {
+		p        float64
+		expected float64
+	}{
+		{0, 1},
+		{25, 3},
+		{50, 5},
+		{62.5, 6},
+		{100, 9},
+	}
+	for _, test := range tests {
+		if v := lt.Percentile(test.p); v != test.expected {
+			t.Errorf("percentile %f: %f, expected %f", test.p, v, test.expected)
+		}
+	}
+}
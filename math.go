@@ -1,20 +1,98 @@
 package ntm
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"math"
+	"sort"
 )
 
-const (
-	machineEpsilon     = 2.2e-16
-	machineEpsilonSqrt = 1e-8 // math.Sqrt(machineEpsilon)
-)
+// MachineEpsilon is approximately the difference between 1 and the next larger float64,
+// and is the scale of the guards keeping the memory addressing of a NTM numerically stable.
+// It is added to the denominators of the cosine similarity and of normalized keys so that they stay finite for zero vectors,
+// and is the default threshold below which shifted weights are ignored when backpropagating through sharpening, see WithRefocusEpsilon.
+// Addressing components written outside of this package should guard their divisions alike to behave consistently.
+const MachineEpsilon = 2.2e-16
+
+const machineEpsilonSqrt = 1e-8 // math.Sqrt(MachineEpsilon)
+
+// argmax returns the index of the largest element of xs.
+// Ties are resolved to the lowest index, and NaNs are never chosen unless xs[0] is NaN.
+func argmax(xs []float64) int {
+	idx := 0
+	for i, x := range xs {
+		if x > xs[idx] {
+			idx = i
+		}
+	}
+	return idx
+}
+
+// topK returns the indices of the k largest elements of xs in descending order of the elements.
+// Ties are resolved to the lowest index, and all indices are returned if k exceeds the length of xs.
+func topK(xs []float64, k int) []int {
+	idx := make([]int, len(xs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return xs[idx[i]] > xs[idx[j]] })
+	if k < 0 {
+		k = 0
+	}
+	if k < len(idx) {
+		idx = idx[:k]
+	}
+	return idx
+}
 
 // Sigmoid computes 1 / (1 + math.Exp(-x))
 func Sigmoid(x float64) float64 {
 	return 1.0 / (1 + math.Exp(-x))
 }
 
+// SigmoidGrad computes the derivative of Sigmoid at x.
+func SigmoidGrad(x float64) float64 {
+	s := Sigmoid(x)
+	return s * (1 - s)
+}
+
+// Tanh computes the hyperbolic tangent of x.
+func Tanh(x float64) float64 {
+	return math.Tanh(x)
+}
+
+// TanhGrad computes the derivative of Tanh at x.
+func TanhGrad(x float64) float64 {
+	t := math.Tanh(x)
+	return 1 - t*t
+}
+
+// Softplus computes math.Log(1 + math.Exp(x)), whose derivative is Sigmoid.
+// It is computed as max(x, 0) + log(1 + exp(-|x|)), which does not overflow for large x.
+func Softplus(x float64) float64 {
+	return math.Max(x, 0) + math.Log1p(math.Exp(-math.Abs(x)))
+}
+
+// A kahanSum accumulates float64 values with Kahan compensated summation,
+// whose rounding error does not grow with the number of values, unlike that of naive summation.
+type kahanSum struct {
+	sum float64
+	c   float64 // the running compensation of the low order bits lost in sum
+}
+
+func (k *kahanSum) add(x float64) {
+	y := x - k.c
+	t := k.sum + y
+	k.c = (t - k.sum) - y
+	k.sum = t
+}
+
+func (k *kahanSum) value() float64 {
+	return k.sum
+}
+
 func cosineSimilarity(u, v []float64) float64 {
 	var sum float64 = 0
 	var usum float64 = 0
@@ -24,7 +102,15 @@ func cosineSimilarity(u, v []float64) float64 {
 		usum += u[i] * u[i]
 		vsum += v[i] * v[i]
 	}
-	return sum / math.Sqrt(usum*vsum)
+	return sum / (math.Sqrt(usum)*math.Sqrt(vsum) + MachineEpsilon)
+}
+
+func dotProduct(u, v []float64) float64 {
+	var sum float64 = 0
+	for i := 0; i < len(u); i++ {
+		sum += u[i] * v[i]
+	}
+	return sum
 }
 
 // MakeTensor2 makes a 2 dimensional tensor.
@@ -45,16 +131,55 @@ func MakeTensor3(n, m, p int) [][][]float64 {
 	return t
 }
 
+// CopyTensor2 returns a copy of a 2 dimensional tensor, which shares no backing storage with t.
+func CopyTensor2(t [][]float64) [][]float64 {
+	c := make([][]float64, len(t))
+	for i := range t {
+		c[i] = make([]float64, len(t[i]))
+		copy(c[i], t[i])
+	}
+	return c
+}
+
 // Sprint2 pretty prints a 2 dimensional tensor.
 func Sprint2(t [][]float64) string {
-	s := "["
+	return Sprint2Prec(t, 2)
+}
+
+// Sprint2Prec is similar to Sprint2, but prints prec digits after the decimal point.
+func Sprint2Prec(t [][]float64, prec int) string {
+	var b bytes.Buffer
+	fprint2(&b, t, prec)
+	return b.String()
+}
+
+// Fprint2 is similar to Sprint2, but writes to w.
+// Use Fprint2 when printing large tensors, as it does not build the entire string in memory.
+func Fprint2(w io.Writer, t [][]float64) error {
+	bw := bufio.NewWriter(w)
+	if err := fprint2(bw, t, 2); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func fprint2(w io.Writer, t [][]float64, prec int) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
 	for _, t1 := range t {
-		s += "["
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
 		for _, t2 := range t1 {
-			s += fmt.Sprintf(" %.2f", t2)
+			if _, err := fmt.Fprintf(w, " %.*f", prec, t2); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, "]"); err != nil {
+			return err
 		}
-		s += "]"
 	}
-	s += "]"
-	return s
+	_, err := io.WriteString(w, "]")
+	return err
 }
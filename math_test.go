@@ -0,0 +1,137 @@
+package ntm
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestActivationGrads(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(float64) float64
+		grad func(float64) float64
+	}{
+		{name: "Sigmoid", f: Sigmoid, grad: SigmoidGrad},
+		{name: "Tanh", f: Tanh, grad: TanhGrad},
+		{name: "Softplus", f: Softplus, grad: Sigmoid},
+	}
+	for _, test := range tests {
+		for _, x := range []float64{-5, -1.3, -0.2, 0, 0.7, 2.1, 6} {
+			h := machineEpsilonSqrt * math.Max(math.Abs(x), 1)
+			xph := x + h
+			grad := (test.f(xph) - test.f(x)) / (xph - x)
+			if math.IsNaN(grad) || math.Abs(grad-test.grad(x)) > 1e-5 {
+				t.Errorf("wrong %s gradient at %f expected %f, got %f", test.name, x, grad, test.grad(x))
+			}
+		}
+	}
+}
+
+func TestSoftplusLarge(t *testing.T) {
+	for _, x := range []float64{710, 1e4, math.MaxFloat64} {
+		if s := Softplus(x); s != x {
+			t.Errorf("Softplus(%g) = %g, expected %g", x, s, x)
+		}
+		if s := Softplus(-x); !(s >= 0 && s < 1e-300) {
+			t.Errorf("Softplus(%g) = %g, expected about 0", -x, s)
+		}
+	}
+	if s := Softplus(-40); math.Abs(s-math.Exp(-40)) > 1e-30 {
+		t.Errorf("Softplus(-40) = %g, expected %g", s, math.Exp(-40))
+	}
+}
+
+func TestCopyTensor2(t *testing.T) {
+	m := MakeTensor2(2, 3)
+	m[1][2] = 4
+	c := CopyTensor2(m)
+	if len(c) != 2 || len(c[0]) != 3 || c[1][2] != 4 {
+		t.Fatalf("wrong copy %v", c)
+	}
+	c[1][2] = 5
+	c[0][0] = 6
+	if m[1][2] != 4 || m[0][0] != 0 {
+		t.Errorf("modifying the copy changed the original %v", m)
+	}
+
+	t3 := MakeTensor3(2, 3, 4)
+	if len(t3) != 2 || len(t3[1]) != 3 || len(t3[1][2]) != 4 {
+		t.Fatalf("wrong tensor3 shape")
+	}
+	t3[0][1][2] = 1
+	if t3[1][1][2] != 0 || t3[0][0][2] != 0 {
+		t.Errorf("tensor3 rows share storage")
+	}
+}
+
+func TestKahanSum(t *testing.T) {
+	n := 1024
+	rnd := rand.New(rand.NewSource(38))
+	exact := new(big.Float).SetPrec(512)
+	var naive float64 = 0
+	var k kahanSum
+	for i := 0; i < n; i++ {
+		// Exponentials of widely ranging logits, as summed by the softmax of content addressing.
+		x := math.Exp(20 * (rnd.Float64() - 0.5))
+		exact.Add(exact, new(big.Float).SetPrec(512).SetFloat64(x))
+		naive += x
+		k.add(x)
+	}
+	ref, _ := exact.Float64()
+	naiveErr := math.Abs(naive-ref) / ref
+	kahanErr := math.Abs(k.value()-ref) / ref
+	if kahanErr > MachineEpsilon {
+		t.Errorf("kahan relative error %g", kahanErr)
+	}
+	if kahanErr >= naiveErr {
+		t.Errorf("kahan relative error %g is not smaller than naive %g", kahanErr, naiveErr)
+	}
+}
+
+func TestSprint2Prec(t *testing.T) {
+	m := [][]float64{{0.12345, 1}, {-2.5, 3.14159}}
+	if s := Sprint2Prec(m, 1); s != "[[ 0.1 1.0][ -2.5 3.1]]" {
+		t.Errorf("wrong precision 1 output %s", s)
+	}
+	if s := Sprint2Prec(m, 3); s != "[[ 0.123 1.000][ -2.500 3.142]]" {
+		t.Errorf("wrong precision 3 output %s", s)
+	}
+	if s := Sprint2(m); s != "[[ 0.12 1.00][ -2.50 3.14]]" {
+		t.Errorf("wrong default output %s", s)
+	}
+
+	var b bytes.Buffer
+	if err := Fprint2(&b, m); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if b.String() != Sprint2(m) {
+		t.Errorf("Fprint2 output %s differs from Sprint2 %s", b.String(), Sprint2(m))
+	}
+}
+
+func TestArgmax(t *testing.T) {
+	tests := []struct {
+		xs       []float64
+		expected int
+	}{
+		{[]float64{1}, 0},
+		{[]float64{1, 3, 3, 2}, 1},
+		{[]float64{2, 2, 2}, 0},
+		{[]float64{-1, math.NaN(), -1, 0}, 3},
+		{[]float64{math.Inf(1), math.Inf(1)}, 0},
+	}
+	for _, test := range tests {
+		if i := argmax(test.xs); i != test.expected {
+			t.Errorf("argmax(%v) = %d, expected %d", test.xs, i, test.expected)
+		}
+	}
+
+	machines := predictionMachines([][]float64{{0.3, 0.7, 0.7}, {0.5, 0.5, 0.1}})
+	idxs := DecodeArgmax(machines)
+	if idxs[0] != 1 || idxs[1] != 0 {
+		t.Errorf("ties not resolved to the lowest index, got %v", idxs)
+	}
+}
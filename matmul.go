@@ -0,0 +1,40 @@
+package ntm
+
+// A MatMul computes the matrix-vector products of the linear layers of a controller.
+// Implementations may call optimized libraries such as BLAS, see WithMatMul.
+type MatMul interface {
+	// MulVecAdd adds to dst[i] the sum over j of w[i][j].Val * x[j], for every row i of w.
+	// Columns of w beyond the length of x, such as those of the biases, are ignored.
+	MulVecAdd(dst []float64, w [][]Unit, x []float64)
+}
+
+// goMatMul is the default MatMul, which accumulates the products in the order of j.
+type goMatMul struct{}
+
+func (goMatMul) MulVecAdd(dst []float64, w [][]Unit, x []float64) {
+	for i, wi := range w {
+		v := dst[i]
+		for j, wij := range wi[:len(x)] {
+			v += wij.Val * x[j]
+		}
+		dst[i] = v
+	}
+}
+
+// WithMatMul makes a controller compute the matrix-vector products of its linear layers with mm,
+// in place of the default pure Go implementation.
+// Only the forward pass is affected.
+func WithMatMul(mm MatMul) ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.matMul = mm
+	}
+}
+
+// mulVecAdd calls the MatMul of a controller, which defaults to goMatMul.
+func (cfg controllerConfig) mulVecAdd(dst []float64, w [][]Unit, x []float64) {
+	if cfg.matMul == nil {
+		goMatMul{}.MulVecAdd(dst, w, x)
+		return
+	}
+	cfg.matMul.MulVecAdd(dst, w, x)
+}
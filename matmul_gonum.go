@@ -0,0 +1,29 @@
+//go:build gonum
+
+package ntm
+
+import (
+	"gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/blas/blas64"
+)
+
+// GonumMatMul is a MatMul that computes matrix-vector products with the BLAS implementation registered with gonum's blas64 package.
+// It is only available when building with the gonum build tag.
+// Since the weights of a controller are not stored contiguously, every product first packs the weights into a dense matrix.
+// The matrix is allocated anew by every product, so that a GonumMatMul can be shared by controllers running concurrently.
+type GonumMatMul struct{}
+
+func (g *GonumMatMul) MulVecAdd(dst []float64, w [][]Unit, x []float64) {
+	if len(w) == 0 || len(x) == 0 {
+		return
+	}
+	rows, cols := len(w), len(x)
+	data := make([]float64, rows*cols)
+	for i, wi := range w {
+		for j := range x {
+			data[i*cols+j] = wi[j].Val
+		}
+	}
+	a := blas64.General{Rows: rows, Cols: cols, Stride: cols, Data: data}
+	blas64.Gemv(blas.NoTrans, 1, a, blas64.Vector{N: cols, Data: x, Inc: 1}, 1, blas64.Vector{N: rows, Data: dst, Inc: 1})
+}
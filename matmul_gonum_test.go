@@ -0,0 +1,48 @@
+//go:build gonum
+
+package ntm
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestGonumMatMul(t *testing.T) {
+	testMatMul(t, &GonumMatMul{})
+}
+
+// TestGonumMatMulBatch checks that the copies of a controller run by ForwardBackwardBatch can share a GonumMatMul.
+// Run it with -race to detect concurrent writes.
+func TestGonumMatMulBatch(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(2))
+	rnd := rand.New(rand.NewSource(50))
+	batch := make([][2][][]float64, 0)
+	for _, size := range []int{3, 5, 2, 4} {
+		x, y := dropoutSeqs(rnd, size, 3, 2)
+		batch = append(batch, [2][][]float64{x, y})
+	}
+	c := NewEmptyController1(3, 2, 6, 2, 4, 3, WithMatMul(&GonumMatMul{}))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	expected := make([][][]float64, len(batch))
+	for i, seq := range batch {
+		expected[i] = Predictions(ForwardBackward(c, seq[0], seq[1]))
+	}
+
+	machines := ForwardBackwardBatch(c, batch)
+	for i := range batch {
+		pdts := Predictions(machines[i])
+		for tt := range pdts {
+			for k := range pdts[tt] {
+				if math.Abs(pdts[tt][k]-expected[i][tt][k]) > 1e-12 {
+					t.Errorf("prediction [%d][%d][%d] %f != %f", i, tt, k, pdts[tt][k], expected[i][tt][k])
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkController1ForwardGonum(b *testing.B) {
+	benchmarkController1Forward(b, WithMatMul(&GonumMatMul{}))
+}
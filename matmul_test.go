@@ -0,0 +1,80 @@
+package ntm
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// reverseMatMul is a MatMul that accumulates the products in reverse order, and thus rounds differently from goMatMul.
+type reverseMatMul struct{}
+
+func (reverseMatMul) MulVecAdd(dst []float64, w [][]Unit, x []float64) {
+	for i, wi := range w {
+		var v float64 = 0
+		for j := len(x) - 1; j >= 0; j-- {
+			v += wi[j].Val * x[j]
+		}
+		dst[i] += v
+	}
+}
+
+func TestMatMul(t *testing.T) {
+	testMatMul(t, reverseMatMul{})
+}
+
+// testMatMul checks that controllers using mm produce the same outputs and gradients as those with the default MatMul.
+func testMatMul(t *testing.T, mm MatMul) {
+	x, y := dropoutSeqs(rand.New(rand.NewSource(14)), 5, 3, 2)
+	newControllers := []func(opts ...ControllerOption) Controller{
+		func(opts ...ControllerOption) Controller { return NewEmptyController1(3, 2, 6, 2, 4, 3, opts...) },
+		func(opts ...ControllerOption) Controller {
+			return NewEmptyController1Deep(3, 2, []int{6, 5}, 2, 4, 3, opts...)
+		},
+	}
+	for _, newController := range newControllers {
+		run := func(opts ...ControllerOption) ([][]float64, []float64) {
+			c := newController(opts...)
+			rnd := rand.New(rand.NewSource(15))
+			c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+			machines := ForwardBackward(c, x, y)
+			var grads []float64
+			c.Weights(func(u *Unit) { grads = append(grads, u.Grad) })
+			return Predictions(machines), grads
+		}
+		expectedPreds, expectedGrads := run()
+		preds, grads := run(WithMatMul(mm))
+		for i := range expectedPreds {
+			for j := range expectedPreds[i] {
+				if math.Abs(preds[i][j]-expectedPreds[i][j]) > 1e-12 {
+					t.Errorf("prediction [%d][%d] %f != %f", i, j, preds[i][j], expectedPreds[i][j])
+				}
+			}
+		}
+		for i := range expectedGrads {
+			if math.Abs(grads[i]-expectedGrads[i]) > 1e-9 {
+				t.Errorf("gradient %d %f != %f", i, grads[i], expectedGrads[i])
+			}
+		}
+	}
+}
+
+func BenchmarkController1Forward(b *testing.B) {
+	benchmarkController1Forward(b)
+}
+
+func benchmarkController1Forward(b *testing.B, opts ...ControllerOption) {
+	vectorSize := 8
+	c := NewEmptyController1(vectorSize+2, vectorSize, 100, 1, 128, 20, opts...)
+	rnd := rand.New(rand.NewSource(16))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	empty, _ := initialNTM(c)
+	x := make([]float64, vectorSize+2)
+	for i := range x {
+		x[i] = rnd.Float64()
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Forward(empty.memOp.R, x)
+	}
+}
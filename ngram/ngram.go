@@ -5,19 +5,59 @@ import (
 	"math/rand"
 )
 
+const (
+	order  = 5   // the number of previous bits on which each bit depends
+	seqLen = 200 // the length of the generated sequences
+)
+
+// Task is the dynamic n-gram task, in which each sequence is generated from a freshly sampled lookup table.
+type Task struct{}
+
+func (t Task) GenSeq() ([][]float64, [][]float64) {
+	return GenSeq(GenProb())
+}
+
+func (t Task) InputSize() int {
+	return 1
+}
+
+func (t Task) OutputSize() int {
+	return 1
+}
+
+func (t Task) Name() string {
+	return "ngram"
+}
+
+// BaselineLoss returns the entropy of the source in bits per output bit, given its lookup table.
+// As the probabilities of the table are drawn from Beta(1/2, 1/2),
+// the expected entropy of each random output is 2 - 1/ln(2) bits,
+// while the first n-1 outputs, which are always 0, carry no entropy.
+// This is a lower bound that cannot be reached by a predictor which must infer the table from each sequence.
+func (t Task) BaselineLoss() float64 {
+	return (2 - 1/math.Ln2) * float64(seqLen-order+1) / float64(seqLen)
+}
+
 // GenProb generates a probability lookup table for a n-gram model.
 func GenProb() []float64 {
-	n := 5
-	probs := make([]float64, 1<<uint(n))
+	return genProb(rand.NormFloat64)
+}
+
+// GenProbRand is similar to GenProb, except that the probabilities are drawn from r.
+func GenProbRand(r *rand.Rand) []float64 {
+	return genProb(r.NormFloat64)
+}
+
+func genProb(normFloat64 func() float64) []float64 {
+	probs := make([]float64, 1<<uint(order))
 	for i := range probs {
-		probs[i] = beta()
+		probs[i] = beta(normFloat64)
 	}
 	return probs
 }
 
 func GenSeq(prob []float64) ([][]float64, [][]float64) {
 	n := int(math.Log2(float64(len(prob))))
-	seqLen := 200
 
 	input := make([][]float64, seqLen+1)
 	for i := 0; i < n; i++ {
@@ -48,15 +88,15 @@ func Binarize(seq [][]float64) int {
 	return idx
 }
 
-// beta generates a random number from the Beta(1/2, 1/2) distribution.
-func beta() float64 {
-	x := gamma()
-	y := gamma()
+// beta generates a random number from the Beta(1/2, 1/2) distribution, given a source of standard normal numbers.
+func beta(normFloat64 func() float64) float64 {
+	x := gamma(normFloat64)
+	y := gamma(normFloat64)
 	return x / (x + y)
 }
 
-// gamma generates a random number from the Gamma(1/2, 1) distribution.
-func gamma() float64 {
-	n := rand.NormFloat64()
+// gamma generates a random number from the Gamma(1/2, 1) distribution, given a source of standard normal numbers.
+func gamma(normFloat64 func() float64) float64 {
+	n := normFloat64()
 	return 0.5 * n * n
 }
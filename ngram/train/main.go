@@ -54,24 +54,20 @@ func main() {
 		}
 	}()
 
-	var seed int64 = 8
-	rand.Seed(seed)
+	cfg := ntm.DefaultTrainConfig()
+	rand.Seed(cfg.Seed)
 
-	h1Size := 100
-	numHeads := 1
-	n := 128
-	m := 20
-	c := ntm.NewEmptyController1(1, 1, h1Size, numHeads, n, m)
+	c := ntm.NewEmptyController1(1, 1, cfg.H1Size, cfg.NumHeads, cfg.N, cfg.M)
 	c.Weights(func(u *ntm.Unit) { u.Val = 1 * (rand.Float64() - 0.5) })
 
 	losses := make([]float64, 0)
 	doPrint := false
 
 	rmsp := ntm.NewRMSProp(c)
-	log.Printf("seed: %d, numweights: %d, numHeads: %d", seed, c.NumWeights(), c.NumHeads())
+	log.Printf("seed: %d, numweights: %d, numHeads: %d", cfg.Seed, c.NumWeights(), c.NumHeads())
 	for i := 1; ; i++ {
 		x, y := ngram.GenSeq(ngram.GenProb())
-		machines := rmsp.Train(x, y, 0.95, 0.5, 1e-3, 1e-3)
+		machines := rmsp.Train(x, y, cfg.Decay, cfg.Momentum, cfg.LearningRate, cfg.Epsilon)
 
 		if i%10000 == 0 {
 			prob := ngram.GenProb()
@@ -80,11 +76,11 @@ func main() {
 			for j := 0; j < samn; j++ {
 				x, y = ngram.GenSeq(prob)
 				machines = ntm.ForwardBackward(c, x, y)
-				l += ntm.Loss(y, machines)
+				l += ntm.BitsPerSequence(y, machines)
 			}
 			l = l / float64(samn)
 			losses = append(losses, l)
-			log.Printf("%d, bits-per-seq: %f", i, l)
+			log.Printf("%d, bits-per-sequence: %f", i, l)
 		}
 
 		handleHTTP(c, losses, &doPrint)
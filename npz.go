@@ -0,0 +1,157 @@
+package ntm
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A ParamGroup is a named array of the internal weights of a controller.
+type ParamGroup struct {
+	Name  string
+	Shape []int
+	Units []*Unit // in row-major order
+}
+
+// ParamGroups groups the internal weights of a controller into arrays by the tags passed in by Controller.WeightsVerbose.
+// Jagged arrays, such as those of memory heads with different configurations, are split along their first dimension,
+// in which case the name of each part is suffixed with its index, for example "Wuh1[0]".
+func ParamGroups(c Controller) []ParamGroup {
+	names := make([]string, 0)
+	entries := make(map[string][]paramEntry)
+	c.WeightsVerbose(func(tag string, u *Unit) {
+		i := strings.IndexByte(tag, '[')
+		name := tag[:i]
+		ids := make([]int, 0, 3)
+		for _, s := range strings.Split(tag[i+1:len(tag)-1], "][") {
+			id, err := strconv.Atoi(s)
+			if err != nil {
+				panic(fmt.Sprintf("malformed weight tag %q", tag))
+			}
+			ids = append(ids, id)
+		}
+		if _, ok := entries[name]; !ok {
+			names = append(names, name)
+		}
+		entries[name] = append(entries[name], paramEntry{ids: ids, u: u})
+	})
+
+	groups := make([]ParamGroup, 0, len(names))
+	for _, name := range names {
+		groups = appendParamGroups(groups, name, entries[name])
+	}
+	return groups
+}
+
+// A Param is a named array of the internal weights of a controller, whose Values reference the Units of the controller.
+type Param struct {
+	Name   string
+	Shape  []int
+	Values []*Unit // in row-major order
+}
+
+// Parameters returns the internal weights of a controller as named arrays, one for each group of ParamGroups.
+// Setting a Unit of Values changes the controller.
+func Parameters(c Controller) []Param {
+	groups := ParamGroups(c)
+	params := make([]Param, len(groups))
+	for i, g := range groups {
+		params[i] = Param{Name: g.Name, Shape: g.Shape, Values: g.Units}
+	}
+	return params
+}
+
+type paramEntry struct {
+	ids []int
+	u   *Unit
+}
+
+func appendParamGroups(groups []ParamGroup, name string, entries []paramEntry) []ParamGroup {
+	shape := make([]int, len(entries[0].ids))
+	for _, e := range entries {
+		for d, id := range e.ids {
+			if id+1 > shape[d] {
+				shape[d] = id + 1
+			}
+		}
+	}
+	size := 1
+	for _, s := range shape {
+		size *= s
+	}
+
+	if size == len(entries) {
+		g := ParamGroup{Name: name, Shape: shape, Units: make([]*Unit, size)}
+		for _, e := range entries {
+			idx := 0
+			for d, id := range e.ids {
+				idx = idx*shape[d] + id
+			}
+			g.Units[idx] = e.u
+		}
+		return append(groups, g)
+	}
+
+	parts := make([][]paramEntry, shape[0])
+	for _, e := range entries {
+		parts[e.ids[0]] = append(parts[e.ids[0]], paramEntry{ids: e.ids[1:], u: e.u})
+	}
+	for i, p := range parts {
+		// Parts without weights are rows that Weights skips, such as rows shared with other rows.
+		if len(p) == 0 {
+			continue
+		}
+		groups = appendParamGroups(groups, fmt.Sprintf("%s[%d]", name, i), p)
+	}
+	return groups
+}
+
+// ExportWeightsNPZ writes the internal weights of a controller in the NumPy .npz format.
+// Each ParamGroup is stored as a float64 array named after the group.
+func ExportWeightsNPZ(c Controller, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for _, g := range ParamGroups(c) {
+		f, err := zw.Create(g.Name + ".npy")
+		if err != nil {
+			return err
+		}
+		if err := writeNPY(f, g); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeNPY writes a ParamGroup in version 1.0 of the .npy format.
+func writeNPY(w io.Writer, g ParamGroup) error {
+	dims := make([]string, len(g.Shape))
+	for i, s := range g.Shape {
+		dims[i] = strconv.Itoa(s)
+	}
+	shape := strings.Join(dims, ", ")
+	if len(dims) == 1 {
+		shape += ","
+	}
+	header := fmt.Sprintf("{'descr': '<f8', 'fortran_order': False, 'shape': (%s), }", shape)
+	// The magic string, version and header length take 10 bytes,
+	// and the header is padded with spaces and terminated by a newline to align the data to 64 bytes.
+	header += strings.Repeat(" ", 63-(10+len(header))%64) + "\n"
+
+	if _, err := io.WriteString(w, "\x93NUMPY\x01\x00"); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(header))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	vals := make([]float64, len(g.Units))
+	for i, u := range g.Units {
+		vals[i] = u.Val
+	}
+	return binary.Write(w, binary.LittleEndian, vals)
+}
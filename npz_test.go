@@ -0,0 +1,173 @@
+package ntm
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParamGroups(t *testing.T) {
+	controllers := []Controller{
+		NewEmptyController1(3, 2, 4, 2, 5, 3, WithTiedEraseAdd()),
+		NewEmptyController1Deep(3, 2, []int{4, 3}, 1, 5, 3),
+	}
+	for _, c := range controllers {
+		groups := ParamGroups(c)
+		numWeights := 0
+		for _, g := range groups {
+			size := 1
+			for _, s := range g.Shape {
+				size *= s
+			}
+			if size != len(g.Units) {
+				t.Errorf("%T: group %s of shape %v has %d units", c, g.Name, g.Shape, len(g.Units))
+			}
+			numWeights += len(g.Units)
+		}
+		if numWeights != c.NumWeights() {
+			t.Errorf("%T: %d weights in groups, expected %d", c, numWeights, c.NumWeights())
+		}
+
+		for _, g := range groups {
+			g.Units[len(g.Units)-1].Val = 1
+		}
+		set := 0
+		for _, v := range Snapshot(c) {
+			if v == 1 {
+				set++
+			}
+		}
+		if set != len(groups) {
+			t.Errorf("%T: %d weights set through the groups, expected %d", c, set, len(groups))
+		}
+
+		params := Parameters(c)
+		if len(params) != len(groups) {
+			t.Fatalf("%T: %d parameters, expected %d", c, len(params), len(groups))
+		}
+		numValues := 0
+		for i, p := range params {
+			if p.Name != groups[i].Name || !reflect.DeepEqual(p.Shape, groups[i].Shape) {
+				t.Errorf("%T: parameter %s of shape %v, expected %s of shape %v", c, p.Name, p.Shape, groups[i].Name, groups[i].Shape)
+			}
+			numValues += len(p.Values)
+			p.Values[0].Val = 2
+		}
+		if numValues != c.NumWeights() {
+			t.Errorf("%T: %d values in parameters, expected %d", c, numValues, c.NumWeights())
+		}
+		set = 0
+		for _, v := range Snapshot(c) {
+			if v == 2 {
+				set++
+			}
+		}
+		if set != len(params) {
+			t.Errorf("%T: %d weights set through the parameters, expected %d", c, set, len(params))
+		}
+	}
+}
+
+func TestExportWeightsNPZ(t *testing.T) {
+	c := NewEmptyController1(3, 2, 4, 2, 5, 3, WithAddressingModes(ContentAndLocation, ContentOnly))
+	rnd := rand.New(rand.NewSource(7))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() })
+
+	groups := ParamGroups(c)
+	numWeights := 0
+	names := make(map[string]bool)
+	for _, g := range groups {
+		numWeights += len(g.Units)
+		names[g.Name] = true
+	}
+	if numWeights != c.NumWeights() {
+		t.Errorf("%d weights in groups, expected %d", numWeights, c.NumWeights())
+	}
+	for _, name := range []string{"Wh1r", "Wh1x", "Wh1b", "Wyh1", "Wuh1[0]", "Wuh1[1]", "wtm1", "mtm1"} {
+		if !names[name] {
+			t.Errorf("missing group %s in %v", name, names)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ExportWeightsNPZ(c, &buf); err != nil {
+		t.Fatalf("%v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(zr.File) != len(groups) {
+		t.Fatalf("%d arrays, expected %d", len(zr.File), len(groups))
+	}
+	for i, f := range zr.File {
+		g := groups[i]
+		if f.Name != g.Name+".npy" {
+			t.Errorf("array %d named %s, expected %s.npy", i, f.Name, g.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		shape, vals := readNPY(t, rc)
+		rc.Close()
+		if len(shape) != len(g.Shape) {
+			t.Fatalf("%s: shape %v, expected %v", g.Name, shape, g.Shape)
+		}
+		for d := range shape {
+			if shape[d] != g.Shape[d] {
+				t.Errorf("%s: shape %v, expected %v", g.Name, shape, g.Shape)
+			}
+		}
+		for j, u := range g.Units {
+			if vals[j] != u.Val {
+				t.Errorf("%s[%d]: %f != %f", g.Name, j, vals[j], u.Val)
+			}
+		}
+	}
+}
+
+func readNPY(t *testing.T, r io.Reader) ([]int, []float64) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(b[:8]) != "\x93NUMPY\x01\x00" {
+		t.Fatalf("bad magic %q", b[:8])
+	}
+	headerLen := int(binary.LittleEndian.Uint16(b[8:10]))
+	if (10+headerLen)%64 != 0 {
+		t.Errorf("data is not aligned, header length %d", headerLen)
+	}
+	header := string(b[10 : 10+headerLen])
+	if !strings.Contains(header, "'descr': '<f8'") || !strings.Contains(header, "'fortran_order': False") {
+		t.Fatalf("unexpected header %s", header)
+	}
+	s := header[strings.Index(header, "'shape': (")+len("'shape': ("):]
+	s = s[:strings.IndexByte(s, ')')]
+	shape := make([]int, 0)
+	for _, d := range strings.Split(s, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			t.Fatalf("bad shape %s", s)
+		}
+		shape = append(shape, n)
+	}
+
+	data := b[10+headerLen:]
+	vals := make([]float64, len(data)/8)
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, vals); err != nil {
+		t.Fatalf("%v", err)
+	}
+	return shape, vals
+}
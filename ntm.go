@@ -8,6 +8,7 @@ where A is the number indicating the seed provided to rand.Seed in the training
 package ntm
 
 import (
+	"fmt"
 	"math"
 )
 
@@ -17,13 +18,26 @@ type Head struct {
 	units []Unit
 	Wtm1  *refocus // the weights at time t-1
 	M     int      // size of a row in the memory
+
+	cfg    headConfig
+	offset int     // the offset of the addressing units among units, which is 0 except for additional read weightings
+	reads  []*Head // the additional read weightings, each a head that shares units and only addresses the memory, see WithReadWeightings
 }
 
 // NewHead creates a new memory head.
 func NewHead(m int) *Head {
+	return newHead(m, headConfig{})
+}
+
+func newHead(m int, cfg headConfig) *Head {
 	h := Head{
-		units: make([]Unit, 3*m+4),
+		units: make([]Unit, cfg.numUnits(m)),
 		M:     m,
+		cfg:   cfg,
+	}
+	for j := 0; j < cfg.numWeightings()-1; j++ {
+		rcfg, offset := cfg.readWeighting(j, m)
+		h.reads = append(h.reads, &Head{units: h.units, M: m, cfg: rcfg, offset: offset})
 	}
 	return &h
 }
@@ -40,27 +54,62 @@ func (h *Head) AddVector() []Unit {
 
 // K returns a head's key vector, which is the target data in the content addressing step.
 func (h *Head) K() []Unit {
-	return h.units[2*h.M : 3*h.M]
+	return h.units[2*h.M+h.offset : h.beta()]
 }
 
 // Beta returns the key strength of a content addressing step.
 func (h *Head) Beta() *Unit {
-	return &h.units[3*h.M]
+	return &h.units[h.beta()]
+}
+
+// beta returns the index of the key strength among the units of a head, which follow the erase, add and key vectors.
+func (h *Head) beta() int {
+	return 2*h.M + h.offset + h.cfg.keyWidth(h.M)
 }
 
 // G returns the degree in which we want to choose content-addressing over location-based-addressing.
+// G returns nil for heads that do only content addressing.
 func (h *Head) G() *Unit {
-	return &h.units[3*h.M+1]
+	if h.cfg.mode == ContentOnly {
+		return nil
+	}
+	return &h.units[h.beta()+1]
 }
 
 // S returns a value indicating how much the weightings are rotated in a location-based-addressing step.
+// S returns nil for heads that do only content addressing, and for heads that emit shift logits.
 func (h *Head) S() *Unit {
-	return &h.units[3*h.M+2]
+	if h.cfg.mode == ContentOnly || h.cfg.shiftLogits {
+		return nil
+	}
+	return &h.units[h.beta()+2]
+}
+
+// ShiftLogits returns the unnormalized log probabilities of rotating the weightings by -r, ..., r locations,
+// where r is the shift range of the head.
+// ShiftLogits returns nil unless the head is configured by WithShiftLogits.
+func (h *Head) ShiftLogits() []Unit {
+	if h.cfg.mode == ContentOnly || !h.cfg.shiftLogits {
+		return nil
+	}
+	return h.units[h.beta()+2 : h.beta()+2+h.cfg.numShiftUnits()]
 }
 
 // Gamma returns the degree in which the addressing weights are sharpened.
+// Gamma returns nil for heads that do only content addressing.
 func (h *Head) Gamma() *Unit {
-	return &h.units[3*h.M+3]
+	if h.cfg.mode == ContentOnly {
+		return nil
+	}
+	return &h.units[h.beta()+2+h.cfg.numShiftUnits()]
+}
+
+// WriteGate returns the degree in which a head writes to memory, or nil if the head always writes.
+func (h *Head) WriteGate() *Unit {
+	if !h.cfg.writeGate {
+		return nil
+	}
+	return &h.units[2*h.M+h.cfg.numAddressingUnits(h.M)]
 }
 
 // The Controller interface is implemented by NTM controller networks that wish to operate with memory banks in a NTM.
@@ -89,63 +138,245 @@ type Controller interface {
 
 	NumWeights() int
 	NumHeads() int
+	// MemoryN returns the number of memory locations.
 	MemoryN() int
+	// MemoryM returns the size of each memory location.
 	MemoryM() int
 }
 
+// MemoryShape returns the number of memory locations n and the size m of each location of a controller's memory.
+func MemoryShape(c Controller) (n, m int) {
+	return c.MemoryN(), c.MemoryM()
+}
+
+// InitialHeadWeights returns the addressing weights of every memory head at time t-1 of the first time instant,
+// which are the softmax of the bias values returned by Controller.Wtm1BiasV.
+// The bias values are enumerated by Controller.Weights in the group GroupMemoryInit, so the initial weights are learned with the other weights.
+func InitialHeadWeights(c Controller) [][]float64 {
+	ws := make([][]float64, c.NumHeads())
+	for i, biases := range c.Wtm1BiasV() {
+		ws[i] = unitVals(newContentAddressing(biases, false).Top)
+	}
+	return ws
+}
+
+// SetInitialHeadWeights sets the bias values of the i-th memory head, such that its initial addressing weights are w normalized to sum to 1.
+// Zero weights are replaced by MachineEpsilon, as the bias values must be finite.
+func SetInitialHeadWeights(c Controller, i int, w []float64) error {
+	if i < 0 || i >= c.NumHeads() {
+		return fmt.Errorf("ntm: head %d out of range [0, %d)", i, c.NumHeads())
+	}
+	if len(w) != c.MemoryN() {
+		return fmt.Errorf("ntm: %d weights, expected %d", len(w), c.MemoryN())
+	}
+	var sum float64 = 0
+	for j, v := range w {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("ntm: invalid weight %f at location %d", v, j)
+		}
+		sum += v
+	}
+	if sum == 0 {
+		return fmt.Errorf("ntm: weights sum to zero")
+	}
+	for j, b := range c.Wtm1BiasV()[i] {
+		b.Top.Val = math.Log(math.Max(w[j]/sum, MachineEpsilon))
+	}
+	return nil
+}
+
 // A NTM is a neural turing machine as described in A.Graves, G. Wayne, and I. Danihelka. arXiv preprint arXiv:1410.5401, 2014.
 type NTM struct {
 	Controller Controller
 	memOp      *memOp
 }
 
-func newNTM(old *NTM, x []float64) *NTM {
+func newNTM(old *NTM, x []float64, tp *tape) *NTM {
 	m := NTM{
 		Controller: old.Controller.Forward(old.memOp.R, x),
 	}
-	for i := 0; i < len(m.Controller.Heads()); i++ {
-		m.Controller.Heads()[i].Wtm1 = old.memOp.W[i]
+	for i, h := range m.Controller.Heads() {
+		h.Wtm1 = old.memOp.W[i]
+		for j, rh := range h.reads {
+			rh.Wtm1 = old.memOp.RW[i][j]
+		}
 	}
-	m.memOp = newMemOp(m.Controller.Heads(), old.memOp.WM)
+	m.memOp = newMemOp(m.Controller.Heads(), old.memOp.WM, tp)
 	return &m
 }
 
+// MemoryRow returns a copy of the content of the memory at location i after the writes of m.
+// It panics if i is not a memory location.
+func (m *NTM) MemoryRow(i int) []float64 {
+	return m.memOp.WM.Row(i)
+}
+
 func (m *NTM) backward() {
 	m.memOp.Backward()
 	m.Controller.Backward()
 }
 
-// ForwardBackward computes a controller's prediction and gradients with respect to the given ground truth input and output values.
-func ForwardBackward(c Controller, in, out [][]float64) []*NTM {
-	// Set memory and head weights to their bias values.
+// A readWeighter is a Controller whose memory heads may read with several weightings, see WithReadWeightings.
+type readWeighter interface {
+	// numReadWeightings returns the number of weightings with which every memory head reads.
+	numReadWeightings() int
+}
+
+// initialNTM returns an empty NTM whose memory and head weights are set to their bias values,
+// together with the content addressing circuits that produced the head weights.
+// The additional read weightings of a head share the weights of the head, whose gradients thus accumulate those of every weighting.
+func initialNTM(c Controller) (*NTM, []*contentAddressing) {
 	wtm1s := make([]*refocus, c.NumHeads())
 	reads := make([]*memRead, c.NumHeads())
 	cas := make([]*contentAddressing, c.NumHeads())
+	var rws [][]*refocus
+	k := 1
+	if rw, ok := c.(readWeighter); ok {
+		k = rw.numReadWeightings()
+	}
 	for i := range reads {
-		cas[i] = newContentAddressing(c.Wtm1BiasV()[i])
+		cas[i] = newContentAddressing(c.Wtm1BiasV()[i], false)
 		wtm1s[i] = &refocus{Top: make([]Unit, c.MemoryN())}
 		for j := range wtm1s[i].Top {
 			wtm1s[i].Top[j].Val = cas[i].Top[j].Val
 		}
-		reads[i] = newMemRead(wtm1s[i], c.Mtm1BiasV())
+		ws := wtm1s[i : i+1 : i+1]
+		if k > 1 {
+			if rws == nil {
+				rws = make([][]*refocus, len(reads))
+			}
+			rws[i] = make([]*refocus, k-1)
+			for j := range rws[i] {
+				rws[i][j] = wtm1s[i]
+			}
+			ws = append([]*refocus{wtm1s[i]}, rws[i]...)
+		}
+		reads[i] = newMemReadMulti(ws, c.Mtm1BiasV())
 	}
-	machines := make([]*NTM, len(in))
 	empty := &NTM{
 		Controller: c,
-		memOp:      &memOp{W: wtm1s, R: reads, WM: c.Mtm1BiasV()},
+		memOp:      &memOp{W: wtm1s, RW: rws, R: reads, WM: c.Mtm1BiasV()},
+	}
+	return empty, cas
+}
+
+// A DimError reports that the inputs or outputs given to ForwardBackward do not match the dimensions of a controller.
+// An input sequence without time instants is reported with Axis "T", Expected 1 and Got 0.
+type DimError struct {
+	Axis     string // "x" or "y" for the size of an input or output vector, "T" for the number of time instants
+	Expected int
+	Got      int
+}
+
+func (e DimError) Error() string {
+	switch e.Axis {
+	case "T":
+		if e.Expected == 1 && e.Got == 0 {
+			return "ntm: no time instants, expected at least 1"
+		}
+		return fmt.Sprintf("ntm: %d output time instants, expected %d", e.Got, e.Expected)
+	default:
+		return fmt.Sprintf("ntm: %s has size %d, expected %d", e.Axis, e.Got, e.Expected)
+	}
+}
+
+// A sizer is a Controller that knows the sizes of its inputs and outputs.
+type sizer interface {
+	inputSize() int
+	outputSize() int
+}
+
+// CheckDims returns a DimError if the input and output sequences do not match the dimensions of a controller.
+// Only the number of time instants is checked for controllers that do not know the sizes of their inputs and outputs.
+func CheckDims(c Controller, in, out [][]float64) error {
+	if len(in) == 0 {
+		return DimError{Axis: "T", Expected: 1, Got: 0}
+	}
+	if len(out) != len(in) {
+		return DimError{Axis: "T", Expected: len(in), Got: len(out)}
+	}
+	s, ok := c.(sizer)
+	if !ok {
+		return nil
+	}
+	for t := range in {
+		if len(in[t]) != s.inputSize() {
+			return DimError{Axis: "x", Expected: s.inputSize(), Got: len(in[t])}
+		}
+		if len(out[t]) != s.outputSize() {
+			return DimError{Axis: "y", Expected: s.outputSize(), Got: len(out[t])}
+		}
+	}
+	return nil
+}
+
+// ForwardBackward computes a controller's prediction and gradients with respect to the given ground truth input and output values.
+// It panics with a DimError if the inputs or outputs do not match the dimensions of the controller, see CheckDims.
+func ForwardBackward(c Controller, in, out [][]float64) []*NTM {
+	c.Weights(func(u *Unit) { u.Grad = 0 })
+	return forwardBackward(c, in, out)
+}
+
+// ForwardBackwardChecked is similar to ForwardBackward, except that it returns the DimError, *NaNError or *DegenerateError ForwardBackward panics with.
+// Controllers configured with WithNaNCheck report the first component producing a NaN or infinite value.
+func ForwardBackwardChecked(c Controller, in, out [][]float64) (machines []*NTM, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch e := r.(type) {
+			case DimError:
+				err = e
+			case *NaNError:
+				err = e
+			case *DegenerateError:
+				err = e
+			default:
+				panic(r)
+			}
+		}
+	}()
+	return ForwardBackward(c, in, out), nil
+}
+
+// ForwardBackwardMasked is similar to ForwardBackward, except that only the outputs at the time instants t for which mask[t] is true
+// contribute to the gradients, in the same way as they contribute to LossMasked.
+// A nil mask scores every time instant.
+// It panics with a DimError of Axis "T" if mask is not nil and does not have a value for every time instant.
+func ForwardBackwardMasked(c Controller, in, out [][]float64, mask []bool) []*NTM {
+	if mask != nil && len(mask) != len(in) {
+		panic(DimError{Axis: "T", Expected: len(in), Got: len(mask)})
 	}
+	c.Weights(func(u *Unit) { u.Grad = 0 })
+	return runTape(nil, c, in, out, mask)
+}
+
+// forwardBackward is similar to ForwardBackward, except that it adds to the existing gradients of the controller weights instead of overwriting them.
+func forwardBackward(c Controller, in, out [][]float64) []*NTM {
+	return runTape(nil, c, in, out, nil)
+}
+
+// runTape is similar to forwardBackward, except that the buffers of the memory operations are allocated from tp, which may be nil,
+// and only the time instants scored by mask contribute to the gradients, see ForwardBackwardMasked.
+func runTape(tp *tape, c Controller, in, out [][]float64, mask []bool) []*NTM {
+	if err := CheckDims(c, in, out); err != nil {
+		panic(err)
+	}
+	// Set memory and head weights to their bias values.
+	empty, cas := initialNTM(c)
+	reads := empty.memOp.R
+	machines := make([]*NTM, len(in))
 
 	// Backpropagation through time.
-	machines[0] = newNTM(empty, in[0])
+	machines[0] = newNTM(empty, in[0], tp)
 	for t := 1; t < len(in); t++ {
-		machines[t] = newNTM(machines[t-1], in[t])
+		machines[t] = newNTM(machines[t-1], in[t], tp)
 	}
-	c.Weights(func(u *Unit) { u.Grad = 0 })
 	for t := len(in) - 1; t >= 0; t-- {
 		m := machines[t]
-		y := out[t]
-		for i := 0; i < len(y); i++ {
-			m.Controller.Y()[i].Grad = m.Controller.Y()[i].Val - y[i]
+		if mask == nil || mask[t] {
+			y := out[t]
+			for i := 0; i < len(y); i++ {
+				m.Controller.Y()[i].Grad = m.Controller.Y()[i].Val - y[i]
+			}
 		}
 		m.backward()
 	}
@@ -153,8 +384,8 @@ func ForwardBackward(c Controller, in, out [][]float64) []*NTM {
 	// Compute gradients for the bias values of the initial memory and weights.
 	for i := range reads {
 		reads[i].Backward()
-		for j := range reads[i].W.Top {
-			cas[i].Top[j].Grad += reads[i].W.Top[j].Grad
+		for j := range reads[i].Ws[0].Top {
+			cas[i].Top[j].Grad += reads[i].Ws[0].Top[j].Grad
 		}
 		cas[i].Backward()
 	}
@@ -162,19 +393,258 @@ func ForwardBackward(c Controller, in, out [][]float64) []*NTM {
 	return machines
 }
 
-// Loss returns the cross-entropy loss of a NTM.
+// forward runs a controller on the inputs in without computing gradients, and returns the NTMs at every time instant.
+func forward(c Controller, in [][]float64) []*NTM {
+	if len(in) == 0 {
+		panic(DimError{Axis: "T", Expected: 1, Got: 0})
+	}
+	empty, _ := initialNTM(c)
+	machines := make([]*NTM, len(in))
+	machines[0] = newNTM(empty, in[0], nil)
+	for t := 1; t < len(in); t++ {
+		machines[t] = newNTM(machines[t-1], in[t], nil)
+	}
+	return machines
+}
+
+// An OnlineNTM runs a NTM on a stream of inputs, one time instant at a time.
+// Unlike ForwardBackward, an OnlineNTM does not keep the history of the NTM, and thus cannot compute gradients.
+type OnlineNTM struct {
+	C Controller
+	m *NTM
+}
+
+// NewOnlineNTM returns an OnlineNTM whose memory and head weights are set to the bias values of c.
+func NewOnlineNTM(c Controller) *OnlineNTM {
+	m, _ := initialNTM(c)
+	o := OnlineNTM{C: c, m: m}
+	return &o
+}
+
+// Step advances the OnlineNTM by one time instant with input x, and returns the prediction at that instant.
+func (o *OnlineNTM) Step(x []float64) []float64 {
+	m := newNTM(o.m, x, nil)
+	o.m = m.detach()
+	return unitVals(m.Controller.Y())
+}
+
+// GenerateFreeRunning runs a NTM on prefix, and then for steps time instants feeds the NTM its own decoded prediction as the next input.
+// Predictions are decoded into bits greater than 0.5, or into a one-hot vector of the largest prediction for controllers with SoftmaxOutput.
+// It returns the decoded predictions made after the last input of prefix, the first of which is the prediction at the last instant of prefix.
+// The inputs and outputs of c must have the same size, and prefix must not be empty.
+func GenerateFreeRunning(c Controller, prefix [][]float64, steps int) [][]float64 {
+	if len(prefix) == 0 {
+		panic("ntm: empty prefix")
+	}
+	mode := SigmoidOutput
+	if om, ok := c.(outputModer); ok {
+		mode = om.outputMode()
+	}
+	o := NewOnlineNTM(c)
+	var y []float64
+	for _, x := range prefix {
+		y = o.Step(x)
+	}
+	if len(y) != len(prefix[0]) {
+		panic(DimError{Axis: "x", Expected: len(prefix[0]), Got: len(y)})
+	}
+	generated := make([][]float64, steps)
+	for t := range generated {
+		x := make([]float64, len(y))
+		switch mode {
+		case SoftmaxOutput:
+			x[argmax(y)] = 1
+		default:
+			for i, v := range y {
+				if v > 0.5 {
+					x[i] = 1
+				}
+			}
+		}
+		generated[t] = x
+		if t < steps-1 {
+			y = o.Step(x)
+		}
+	}
+	return generated
+}
+
+// detach returns a copy of m that holds only the values needed to advance m to the next time instant,
+// allowing the history of m to be garbage collected.
+func (m *NTM) detach() *NTM {
+	op := memOp{
+		W:  make([]*refocus, len(m.memOp.W)),
+		R:  make([]*memRead, len(m.memOp.R)),
+		WM: &writtenMemory{},
+	}
+	for i, w := range m.memOp.W {
+		op.W[i] = &refocus{Top: make([]Unit, len(w.Top))}
+		copy(op.W[i].Top, w.Top)
+	}
+	if m.memOp.RW != nil {
+		op.RW = make([][]*refocus, len(m.memOp.RW))
+		for i, rws := range m.memOp.RW {
+			op.RW[i] = make([]*refocus, len(rws))
+			for j, w := range rws {
+				op.RW[i][j] = &refocus{Top: make([]Unit, len(w.Top))}
+				copy(op.RW[i][j].Top, w.Top)
+			}
+		}
+	}
+	for i, r := range m.memOp.R {
+		op.R[i] = &memRead{Top: make([]Unit, len(r.Top))}
+		copy(op.R[i].Top, r.Top)
+	}
+	op.WM.data, op.WM.Top = makeFlatTensorUnit2(len(m.memOp.WM.Top), len(m.memOp.WM.Top[0]))
+	for i, row := range m.memOp.WM.Top {
+		copy(op.WM.Top[i], row)
+	}
+	d := NTM{Controller: m.Controller, memOp: &op}
+	return &d
+}
+
+// Loss returns the cross-entropy loss of a NTM in bits.
+// The loss is the binary cross-entropy of every output, or the categorical cross-entropy for controllers with SoftmaxOutput.
 func Loss(output [][]float64, ms []*NTM) float64 {
 	var l float64 = 0
 	for t := 0; t < len(output); t++ {
-		for i := 0; i < len(output[t]); i++ {
-			y := output[t][i]
-			p := ms[t].Controller.Y()[i].Val
-			l += y*math.Log2(p) + (1-y)*math.Log2(1-p)
+		l += stepLoss(output[t], ms[t].Controller)
+	}
+	return l
+}
+
+// An outputModer is a Controller that supports output modes other than SigmoidOutput.
+type outputModer interface {
+	outputMode() OutputMode
+}
+
+// stepLoss returns the cross-entropy loss in bits of the outputs of a controller at a single time instant.
+func stepLoss(y []float64, c Controller) float64 {
+	mode := SigmoidOutput
+	if om, ok := c.(outputModer); ok {
+		mode = om.outputMode()
+	}
+	var l float64 = 0
+	for i, v := range y {
+		p := c.Y()[i].Val
+		switch mode {
+		case SoftmaxOutput:
+			if v != 0 {
+				l += v * math.Log2(p)
+			}
+		default:
+			l += v*math.Log2(p) + (1-v)*math.Log2(1-p)
 		}
 	}
 	return -l
 }
 
+// LossMasked returns the cross-entropy loss of a NTM, counting only the time instants t for which mask[t] is true.
+func LossMasked(output [][]float64, ms []*NTM, mask []bool) float64 {
+	var l float64 = 0
+	for t := 0; t < len(output); t++ {
+		if !mask[t] {
+			continue
+		}
+		l += stepLoss(output[t], ms[t].Controller)
+	}
+	return l
+}
+
+// BitsPerBitMasked is similar to BitsPerBit, except that only the time instants t for which mask[t] is true are counted,
+// both in the loss and in the denominator, see LossMasked.
+// It returns 0 if no outputs are scored.
+func BitsPerBitMasked(output [][]float64, ms []*NTM, mask []bool) float64 {
+	scored := 0
+	for t := range output {
+		if mask[t] {
+			scored += len(output[t])
+		}
+	}
+	if scored == 0 {
+		return 0
+	}
+	return LossMasked(output, ms, mask) / float64(scored)
+}
+
+// BitsPerSequence returns the cross-entropy loss of a NTM in bits, summed over every output of every time instant.
+// It is the same as Loss.
+func BitsPerSequence(output [][]float64, ms []*NTM) float64 {
+	return Loss(output, ms)
+}
+
+// BitsPerBit returns the cross-entropy loss of a NTM in bits, averaged over every output of every time instant.
+// The denominator is the number of time instants multiplied by the output size,
+// and BitsPerBit returns 0 if there are no outputs.
+func BitsPerBit(output [][]float64, ms []*NTM) float64 {
+	if len(output) == 0 || len(output[0]) == 0 {
+		return 0
+	}
+	return Loss(output, ms) / float64(len(output)*len(output[0]))
+}
+
+// MeanSquaredError returns the squared error between the predictions of a NTM and output,
+// averaged over every output of every time instant.
+// It is suitable for tasks with real valued outputs such as copytask.GenSeqContinuous.
+// It returns 0 if output has no outputs, like BitsPerBit.
+func MeanSquaredError(output [][]float64, ms []*NTM) float64 {
+	if len(output) == 0 || len(output[0]) == 0 {
+		return 0
+	}
+	var sum float64 = 0
+	for t, y := range output {
+		for i, v := range y {
+			d := ms[t].Controller.Y()[i].Val - v
+			sum += d * d
+		}
+	}
+	return sum / float64(len(output)*len(output[0]))
+}
+
+// An outputBiaser is a Controller whose outputs before activation have bias weights.
+type outputBiaser interface {
+	// outputBiases returns the bias weights of every output.
+	outputBiases() []*Unit
+}
+
+// InitOutputBias sets the bias weights of the outputs of c to the logit of baseRate,
+// so that a controller whose other weights are small predicts about baseRate for every output.
+// For tasks with skewed outputs, this saves the training steps needed to learn the base rate.
+// InitOutputBias is meant for SigmoidOutput, since the softmax of equal biases is uniform whatever their value.
+// It panics if baseRate is not in (0, 1), or if c does not expose its output biases.
+func InitOutputBias(c Controller, baseRate float64) {
+	if !(baseRate > 0 && baseRate < 1) {
+		panic(fmt.Sprintf("ntm: base rate %f not in (0, 1)", baseRate))
+	}
+	b, ok := c.(outputBiaser)
+	if !ok {
+		panic(fmt.Sprintf("ntm: %T does not support InitOutputBias", c))
+	}
+	for _, u := range b.outputBiases() {
+		u.Val = math.Log(baseRate / (1 - baseRate))
+	}
+}
+
+// A logiter is a Controller that keeps its outputs before activation.
+type logiter interface {
+	outputLogits() []float64
+}
+
+// Logits returns the outputs of a NTM across time before the output activation,
+// such that applying Sigmoid, or the softmax under SoftmaxOutput, to them gives Predictions.
+// Logits panics if the controller does not keep its pre-activation outputs.
+func Logits(machines []*NTM) [][]float64 {
+	logits := make([][]float64, len(machines))
+	for t, m := range machines {
+		l, ok := m.Controller.(logiter)
+		if !ok {
+			panic(fmt.Sprintf("ntm: %T does not support Logits", m.Controller))
+		}
+		logits[t] = append([]float64(nil), l.outputLogits()...)
+	}
+	return logits
+}
+
 // Predictions returns the predictions of a NTM across time.
 func Predictions(machines []*NTM) [][]float64 {
 	pdts := make([][]float64, len(machines))
@@ -188,6 +658,57 @@ func Predictions(machines []*NTM) [][]float64 {
 	return pdts
 }
 
+// DecodeBits thresholds the predictions of a NTM across time into bits.
+// A prediction greater than threshold is decoded as 1, and otherwise 0.
+func DecodeBits(machines []*NTM, threshold float64) [][]int {
+	bits := make([][]int, len(machines))
+	for t := range bits {
+		y := machines[t].Controller.Y()
+		bits[t] = make([]int, len(y))
+		for i, v := range y {
+			if v.Val > threshold {
+				bits[t][i] = 1
+			}
+		}
+	}
+	return bits
+}
+
+// DecodeArgmax returns the index of the largest prediction of a NTM at every time instant.
+// It is intended for tasks with categorical outputs.
+// Ties are resolved to the lowest index, and nil is returned if there are no time instants.
+func DecodeArgmax(machines []*NTM) []int {
+	if len(machines) == 0 {
+		return nil
+	}
+	idxs := make([]int, len(machines))
+	for t := range idxs {
+		idxs[t] = argmax(unitVals(machines[t].Controller.Y()))
+	}
+	return idxs
+}
+
+// ExactMatch returns the fraction of perfectly reconstructed sequences among the sequence y,
+// which is 1 if every output of decoded matches the ground truth y, and 0 otherwise.
+// Averaging ExactMatch over a dataset thus gives the fraction of its sequences that are perfectly reconstructed.
+// decoded is typically the output of DecodeBits, and a decoded sequence whose shape differs from that of y is not a match.
+func ExactMatch(y [][]float64, decoded [][]int) float64 {
+	if len(decoded) != len(y) {
+		return 0
+	}
+	for t := range y {
+		if len(decoded[t]) != len(y[t]) {
+			return 0
+		}
+		for i, v := range y[t] {
+			if v != float64(decoded[t][i]) {
+				return 0
+			}
+		}
+	}
+	return 1
+}
+
 // HeadWeights returns the addressing weights of all memory heads across time.
 // The top level elements represent each head.
 // The second level elements represent every time instant.
@@ -205,30 +726,247 @@ func HeadWeights(machines []*NTM) [][][]float64 {
 	return hws
 }
 
+// ReadVectors returns the vectors read by all memory heads across time.
+// The top level elements represent every time instant.
+// The second level elements represent each head.
+func ReadVectors(machines []*NTM) [][][]float64 {
+	reads := make([][][]float64, len(machines))
+	for t, m := range machines {
+		reads[t] = m.memOp.ReadVals()
+	}
+	return reads
+}
+
+// AddressEntropy returns the Shannon entropy in nats of the addressing weights of all memory heads across time.
+// The top level elements represent every time instant.
+// The second level elements represent each head.
+// A low entropy indicates that a head is sharply focused on a few memory locations.
+func AddressEntropy(machines []*NTM) [][]float64 {
+	ents := make([][]float64, len(machines))
+	for t, m := range machines {
+		ents[t] = make([]float64, len(m.memOp.W))
+		for i, w := range m.memOp.W {
+			var h float64
+			for _, p := range w.Top {
+				if p.Val > 0 {
+					h -= p.Val * math.Log(p.Val)
+				}
+			}
+			ents[t][i] = h
+		}
+	}
+	return ents
+}
+
+// MemoryUsage returns the usage of every memory location across time.
+// As in the Differentiable Neural Computer, the usage u of a location is updated after each write by u += w - u*w,
+// where w is the write weight of a head on the location, scaled by the head's write gate.
+// Usage thus grows towards 1 for locations that are written to, and stays 0 for untouched locations.
+// The top level elements represent every time instant.
+// The second level elements represent each memory location.
+func MemoryUsage(machines []*NTM) [][]float64 {
+	usage := make([][]float64, len(machines))
+	var prev []float64
+	for t, m := range machines {
+		usage[t] = make([]float64, len(m.memOp.WM.Top))
+		copy(usage[t], prev)
+		for _, w := range m.memOp.WM.w {
+			for i, u := range usage[t] {
+				usage[t][i] = u + w[i] - u*w[i]
+			}
+		}
+		prev = usage[t]
+	}
+	return usage
+}
+
+// ReadFocus returns the k memory locations that each memory head reads the most across time,
+// in descending order of the read weights summed over all time instants, and over all weightings of heads configured by WithReadWeightings.
+// The top level elements represent each head.
+// ReadFocus returns nil if there are no machines, and no locations if k is not positive.
+func ReadFocus(machines []*NTM, k int) [][]int {
+	if len(machines) == 0 {
+		return nil
+	}
+	focus := make([][]int, len(machines[0].memOp.W))
+	for i := range focus {
+		ws := make([]*refocus, 0, len(machines))
+		for _, m := range machines {
+			ws = append(ws, m.memOp.W[i])
+			if m.memOp.RW != nil {
+				ws = append(ws, m.memOp.RW[i]...)
+			}
+		}
+		focus[i] = topK(sumWeights(ws), k)
+	}
+	return focus
+}
+
+// WriteFocus returns the k memory locations with the largest write weights of every memory head at every time instant,
+// in descending order of the weights.
+// The top level elements represent every time instant.
+// The second level elements represent each head.
+// The write weights of a head are the weights with which it erases and adds to the memory, before the write gate if any.
+// Like ReadFocus, WriteFocus returns nil if there are no machines, and no locations if k is not positive.
+func WriteFocus(machines []*NTM, k int) [][][]int {
+	if len(machines) == 0 {
+		return nil
+	}
+	focus := make([][][]int, len(machines))
+	for t, m := range machines {
+		focus[t] = make([][]int, len(m.memOp.W))
+		for i, w := range m.memOp.W {
+			focus[t][i] = topK(unitVals(w.Top), k)
+		}
+	}
+	return focus
+}
+
+// WriteInterference returns the write weight mass that falls outside of the most written memory location at every time instant,
+// which is 1 minus the largest write weight, averaged over the memory heads.
+// The write weights are those of WriteFocus.
+// A high interference means that writes blur into neighbouring locations, corrupting their content.
+func WriteInterference(machines []*NTM) []float64 {
+	interference := make([]float64, len(machines))
+	for t, m := range machines {
+		for _, w := range m.memOp.W {
+			vals := unitVals(w.Top)
+			interference[t] += 1 - vals[argmax(vals)]
+		}
+		interference[t] /= float64(len(m.memOp.W))
+	}
+	return interference
+}
+
+// MemoryChangeMask reports whether the content of the memory changed at every time instant, as determined by ContentHash.
+// The memory at the first time instant is compared against the initial memory.
+func MemoryChangeMask(machines []*NTM) []bool {
+	mask := make([]bool, len(machines))
+	var prev uint64
+	for t, m := range machines {
+		h := m.memOp.WM.ContentHash()
+		if t == 0 {
+			mask[t] = m.memOp.WM.Mtm1 == nil || h != m.memOp.WM.Mtm1.ContentHash()
+		} else {
+			mask[t] = h != prev
+		}
+		prev = h
+	}
+	return mask
+}
+
+// GradientNormsPerStep returns the L2 norm of the gradient entering the controller at every time instant,
+// which is the gradient with respect to the controller outputs and the units of its memory heads.
+// It is intended to be called after ForwardBackward, for diagnosing vanishing or exploding gradients through time.
+func GradientNormsPerStep(machines []*NTM) []float64 {
+	norms := make([]float64, len(machines))
+	for t, m := range machines {
+		var sum float64 = 0
+		for _, y := range m.Controller.Y() {
+			sum += y.Grad * y.Grad
+		}
+		for _, h := range m.Controller.Heads() {
+			for _, u := range h.units {
+				sum += u.Grad * u.Grad
+			}
+		}
+		norms[t] = math.Sqrt(sum)
+	}
+	return norms
+}
+
+// MemoryGradNorms returns the L2 norm of the gradient entering the memory at every time instant,
+// which is the gradient with respect to the memory read and written by the memory heads, before the write of the time instant.
+// It is intended to be called after ForwardBackward, for checking that gradients reach the early writes through time.
+// The memory at the first time instant is the initial memory of the controller, whose gradients accumulate as the other weights until the next optimizer step.
+func MemoryGradNorms(machines []*NTM) []float64 {
+	norms := make([]float64, len(machines))
+	for t, m := range machines {
+		var sum float64 = 0
+		for _, row := range m.memOp.WM.Mtm1.Top {
+			for _, u := range row {
+				sum += u.Grad * u.Grad
+			}
+		}
+		norms[t] = math.Sqrt(sum)
+	}
+	return norms
+}
+
 // SGDMomentum implements stochastic gradient descent with momentum.
 type SGDMomentum struct {
 	C     Controller
 	PrevD []float64
+	Noise *GradientNoise // if not nil, the noise added to the gradients before each update
+	// GradTransform, if not nil, is called on every weight after the noise is added and before each update,
+	// allowing custom clipping, masking or noise.
+	GradTransform func(*Unit)
+
+	// The hyperparameters used by Step.
+	LearningRate float64
+	Momentum     float64
+
+	// Nesterov enables Nesterov momentum, which applies the momentum to the velocity after it is updated with the gradient,
+	// as in the reformulation of Bengio, Y., Boulanger-Lewandowski, N., & Pascanu, R. (2013). Advances in optimizing recurrent networks. ICASSP.
+	Nesterov bool
+
+	lrMults lrMultipliers
 }
 
+// NewSGDMomentum returns a SGDMomentum for the controller c, with a learning rate of 1e-4 and a momentum of 0.9.
 func NewSGDMomentum(c Controller) *SGDMomentum {
 	s := SGDMomentum{
-		C:     c,
-		PrevD: make([]float64, c.NumWeights()),
+		C:            c,
+		PrevD:        make([]float64, c.NumWeights()),
+		LearningRate: 1e-4,
+		Momentum:     0.9,
 	}
 	return &s
 }
 
 func (s *SGDMomentum) Train(x, y [][]float64, alpha, mt float64) []*NTM {
 	machines := ForwardBackward(s.C, x, y)
+	s.update(alpha, mt)
+	return machines
+}
+
+// Step updates the weights with the gradients computed by ForwardBackward, using the hyperparameters of s.
+func (s *SGDMomentum) Step(machines []*NTM) {
+	s.update(s.LearningRate, s.Momentum)
+}
+
+func (s *SGDMomentum) Name() string {
+	return "sgdmomentum"
+}
+
+// SetLRMultiplier scales the learning rate of the weights of a group, one of GroupController, GroupHeads and GroupMemoryInit, by mult.
+// The weights of groups without a multiplier use the learning rate unscaled.
+func (s *SGDMomentum) SetLRMultiplier(group string, mult float64) error {
+	return s.lrMults.set(s.C, group, mult)
+}
+
+func (s *SGDMomentum) update(alpha, mt float64) {
+	if s.Noise != nil {
+		s.Noise.Apply(s.C)
+	}
+	if s.GradTransform != nil {
+		s.C.Weights(s.GradTransform)
+	}
+	zeroFrozenGrads(s.C)
+	frozen := frozenVals(s.C)
 	i := 0
 	s.C.Weights(func(w *Unit) {
-		d := -alpha*w.Grad + mt*s.PrevD[i]
-		w.Val += d
+		d := -alpha*s.lrMults.scale(i)*w.Grad + mt*s.PrevD[i]
+		if s.Nesterov {
+			w.Val += -alpha*s.lrMults.scale(i)*w.Grad + mt*d
+		} else {
+			w.Val += d
+		}
 		s.PrevD[i] = d
 		i++
 	})
-	return machines
+	restoreFrozen(s.C, frozen)
+	zeroPrunedWeights(s.C)
 }
 
 // RMSProp implements the rmsprop algorithm. The detailed updating equations are given in
@@ -238,27 +976,161 @@ type RMSProp struct {
 	N []float64
 	G []float64
 	D []float64
+
+	Noise *GradientNoise // if not nil, the noise added to the gradients before each update
+	// GradTransform, if not nil, is called on every weight after the noise is added and before each update,
+	// allowing custom clipping, masking or noise.
+	GradTransform func(*Unit)
+
+	// The hyperparameters used by Step, which are the parameters a, b, c and d of Train respectively.
+	Decay        float64
+	Momentum     float64
+	LearningRate float64
+	Epsilon      float64
+
+	lrMults lrMultipliers
 }
 
+// NewRMSProp returns a RMSProp for the controller c, with the hyperparameters used by the training programs of the subpackages:
+// a decay of 0.95, a momentum of 0.5, a learning rate of 1e-3 and an epsilon of 1e-3.
 func NewRMSProp(c Controller) *RMSProp {
 	r := RMSProp{
-		C: c,
-		N: make([]float64, c.NumWeights()),
-		G: make([]float64, c.NumWeights()),
-		D: make([]float64, c.NumWeights()),
+		C:            c,
+		N:            make([]float64, c.NumWeights()),
+		G:            make([]float64, c.NumWeights()),
+		D:            make([]float64, c.NumWeights()),
+		Decay:        0.95,
+		Momentum:     0.5,
+		LearningRate: 1e-3,
+		Epsilon:      1e-3,
 	}
 	return &r
 }
 
 func (r *RMSProp) Train(x, y [][]float64, a, b, c, d float64) []*NTM {
 	machines := ForwardBackward(r.C, x, y)
+	r.update(a, b, c, d)
+	return machines
+}
+
+// Step updates the weights with the gradients computed by ForwardBackward, using the hyperparameters of r.
+func (r *RMSProp) Step(machines []*NTM) {
+	r.update(r.Decay, r.Momentum, r.LearningRate, r.Epsilon)
+}
+
+func (r *RMSProp) Name() string {
+	return "rmsprop"
+}
+
+// SetLRMultiplier scales the learning rate of the weights of a group, see SGDMomentum.SetLRMultiplier.
+func (r *RMSProp) SetLRMultiplier(group string, mult float64) error {
+	return r.lrMults.set(r.C, group, mult)
+}
+
+func (r *RMSProp) update(a, b, c, d float64) {
+	if r.Noise != nil {
+		r.Noise.Apply(r.C)
+	}
+	if r.GradTransform != nil {
+		r.C.Weights(r.GradTransform)
+	}
+	zeroFrozenGrads(r.C)
+	frozen := frozenVals(r.C)
 	i := 0
 	r.C.Weights(func(w *Unit) {
 		r.N[i] = a*r.N[i] + (1-a)*w.Grad*w.Grad
 		r.G[i] = a*r.G[i] + (1-a)*w.Grad
-		r.D[i] = b*r.D[i] - c*w.Grad/math.Sqrt(r.N[i]-r.G[i]*r.G[i]+d)
+		r.D[i] = b*r.D[i] - c*r.lrMults.scale(i)*w.Grad/math.Sqrt(r.N[i]-r.G[i]*r.G[i]+d)
 		w.Val += r.D[i]
 		i++
 	})
+	restoreFrozen(r.C, frozen)
+	zeroPrunedWeights(r.C)
+}
+
+// MeanSquare returns a copy of the running averages of the squared gradients of every weight, in the order of Controller.Weights.
+// Averages collapsing toward zero make the effective learning rate huge.
+func (r *RMSProp) MeanSquare() []float64 {
+	ms := make([]float64, len(r.N))
+	copy(ms, r.N)
+	return ms
+}
+
+// Variance returns the running variances of the gradients of every weight, in the order of Controller.Weights,
+// computed from the running averages of the gradients and their squares.
+// The square root of the variance plus the regularization constant d of Train scales the update of each weight.
+func (r *RMSProp) Variance() []float64 {
+	v := make([]float64, len(r.N))
+	for i := range v {
+		v[i] = r.N[i] - r.G[i]*r.G[i]
+	}
+	return v
+}
+
+// AdaGrad implements the adagrad algorithm, which scales the learning rate of each weight by the inverse of the square root of its accumulated squared gradients.
+// The detailed updating equations are given in
+// Duchi, J., Hazan, E., & Singer, Y. (2011). Adaptive subgradient methods for online learning and stochastic optimization. JMLR, 12, 2121-2159.
+type AdaGrad struct {
+	C     Controller
+	Accum []float64
+	Noise *GradientNoise // if not nil, the noise added to the gradients before each update
+	// GradTransform, if not nil, is called on every weight after the noise is added and before each update,
+	// allowing custom clipping, masking or noise.
+	GradTransform func(*Unit)
+
+	// The hyperparameters used by Step.
+	LearningRate float64
+	Epsilon      float64
+
+	lrMults lrMultipliers
+}
+
+// NewAdaGrad returns an AdaGrad for the controller c, with a learning rate of 1e-2 and an epsilon of 1e-8.
+func NewAdaGrad(c Controller) *AdaGrad {
+	a := AdaGrad{
+		C:            c,
+		Accum:        make([]float64, c.NumWeights()),
+		LearningRate: 1e-2,
+		Epsilon:      1e-8,
+	}
+	return &a
+}
+
+func (a *AdaGrad) Train(x, y [][]float64, lr, epsilon float64) []*NTM {
+	machines := ForwardBackward(a.C, x, y)
+	a.update(lr, epsilon)
 	return machines
 }
+
+// Step updates the weights with the gradients computed by ForwardBackward, using the hyperparameters of a.
+func (a *AdaGrad) Step(machines []*NTM) {
+	a.update(a.LearningRate, a.Epsilon)
+}
+
+func (a *AdaGrad) Name() string {
+	return "adagrad"
+}
+
+// SetLRMultiplier scales the learning rate of the weights of a group, see SGDMomentum.SetLRMultiplier.
+func (a *AdaGrad) SetLRMultiplier(group string, mult float64) error {
+	return a.lrMults.set(a.C, group, mult)
+}
+
+func (a *AdaGrad) update(lr, epsilon float64) {
+	if a.Noise != nil {
+		a.Noise.Apply(a.C)
+	}
+	if a.GradTransform != nil {
+		a.C.Weights(a.GradTransform)
+	}
+	zeroFrozenGrads(a.C)
+	frozen := frozenVals(a.C)
+	i := 0
+	a.C.Weights(func(w *Unit) {
+		a.Accum[i] += w.Grad * w.Grad
+		w.Val -= lr * a.lrMults.scale(i) / (math.Sqrt(a.Accum[i]) + epsilon) * w.Grad
+		i++
+	})
+	restoreFrozen(a.C, frozen)
+	zeroPrunedWeights(a.C)
+}
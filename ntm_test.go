@@ -0,0 +1,803 @@
+package ntm
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fumin/ntm/copytask"
+)
+
+func TestMain(m *testing.M) {
+	// The gradient checks in this package are sensitive to their random inputs.
+	// Fix the seed, as newer versions of Go no longer seed math/rand deterministically.
+	rand.Seed(1)
+	os.Exit(m.Run())
+}
+
+func predictionMachines(pdts [][]float64) []*NTM {
+	machines := make([]*NTM, len(pdts))
+	for t, p := range pdts {
+		c := &controller1{controllerCore: controllerCore{y: make([]Unit, len(p))}}
+		for i, v := range p {
+			c.y[i].Val = v
+		}
+		machines[t] = &NTM{Controller: c}
+	}
+	return machines
+}
+
+func TestAddressEntropy(t *testing.T) {
+	n := 8
+	oneHot := make([]Unit, n)
+	oneHot[3].Val = 1
+	uniform := make([]Unit, n)
+	for i := range uniform {
+		uniform[i].Val = 1 / float64(n)
+	}
+	machines := []*NTM{
+		{memOp: &memOp{W: []*refocus{{Top: oneHot}, {Top: uniform}}}},
+		{memOp: &memOp{W: []*refocus{{Top: uniform}, {Top: oneHot}}}},
+	}
+	ents := AddressEntropy(machines)
+	expected := [][]float64{{0, math.Log(float64(n))}, {math.Log(float64(n)), 0}}
+	for tt := range expected {
+		for i := range expected[tt] {
+			if math.Abs(ents[tt][i]-expected[tt][i]) > 1e-12 {
+				t.Errorf("[%d][%d] %f != %f", tt, i, ents[tt][i], expected[tt][i])
+			}
+		}
+	}
+}
+
+func TestWriteInterference(t *testing.T) {
+	n := 4
+	oneHot := make([]Unit, n)
+	oneHot[2].Val = 1
+	uniform := make([]Unit, n)
+	for i := range uniform {
+		uniform[i].Val = 1 / float64(n)
+	}
+	machines := []*NTM{
+		{memOp: &memOp{W: []*refocus{{Top: oneHot}, {Top: oneHot}}}},
+		{memOp: &memOp{W: []*refocus{{Top: oneHot}, {Top: uniform}}}},
+	}
+	expected := []float64{0, 0.375}
+	for tt, v := range WriteInterference(machines) {
+		if math.Abs(v-expected[tt]) > 1e-12 {
+			t.Errorf("[%d] %f != %f", tt, v, expected[tt])
+		}
+	}
+}
+
+func TestMemoryChangeMask(t *testing.T) {
+	n, m := 4, 3
+	rnd := rand.New(rand.NewSource(5))
+	mtm1 := &writtenMemory{}
+	mtm1.data, mtm1.Top = makeFlatTensorUnit2(n, m)
+	for i := range mtm1.data {
+		mtm1.data[i].Val = rnd.Float64() - 0.5
+	}
+	// write returns the memory after a write whose write gate unit is gate.
+	write := func(mtm1 *writtenMemory, gate float64) *writtenMemory {
+		h := newHead(m, headConfig{writeGate: true})
+		for i := range h.units {
+			h.units[i].Val = rnd.Float64() - 0.5
+		}
+		h.WriteGate().Val = gate
+		return newWrittenMemory([]*refocus{randomRefocus(n)}, []*Head{h}, mtm1, nil)
+	}
+	wm1 := write(mtm1, 5)
+	wm2 := write(wm1, -1000)
+	wm3 := write(wm2, 5)
+	if wm2.ContentHash() != wm1.ContentHash() {
+		t.Errorf("a step without writes changed the memory hash")
+	}
+
+	machines := []*NTM{{memOp: &memOp{WM: wm1}}, {memOp: &memOp{WM: wm2}}, {memOp: &memOp{WM: wm3}}}
+	mask := MemoryChangeMask(machines)
+	expected := []bool{true, false, true}
+	for i := range expected {
+		if mask[i] != expected[i] {
+			t.Errorf("[%d] %t != %t", i, mask[i], expected[i])
+		}
+	}
+}
+
+func TestMemoryRow(t *testing.T) {
+	n, m, loc := 4, 3, 2
+	rnd := rand.New(rand.NewSource(9))
+	mtm1 := &writtenMemory{}
+	mtm1.data, mtm1.Top = makeFlatTensorUnit2(n, m)
+	for i := range mtm1.data {
+		mtm1.data[i].Val = rnd.Float64()
+	}
+	// Fully erase location loc and add 0.5 = Sigmoid(0) to it.
+	h := newHead(m, headConfig{})
+	for i := range h.EraseVector() {
+		h.EraseVector()[i].Val = 1000
+	}
+	w := &refocus{Top: make([]Unit, n)}
+	w.Top[loc].Val = 1
+	wm := newWrittenMemory([]*refocus{w}, []*Head{h}, mtm1, nil)
+	machine := &NTM{memOp: &memOp{WM: wm}}
+
+	for i := 0; i < n; i++ {
+		expected := unitVals(mtm1.Top[i])
+		if i == loc {
+			expected = []float64{0.5, 0.5, 0.5}
+		}
+		row := machine.MemoryRow(i)
+		for j := range expected {
+			if row[j] != expected[j] {
+				t.Errorf("[%d][%d] %f != %f", i, j, row[j], expected[j])
+			}
+		}
+	}
+	machine.MemoryRow(loc)[0] = -1
+	if wm.Top[loc][0].Val != 0.5 {
+		t.Errorf("row is not a copy")
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("no panic for location %d", n)
+		}
+	}()
+	machine.MemoryRow(n)
+}
+
+func TestMemoryGradNorms(t *testing.T) {
+	vectorSize := 2
+	c := NewEmptyController1(vectorSize+2, vectorSize, 6, 1, 5, 3)
+	rnd := rand.New(rand.NewSource(20))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(2, vectorSize)
+	norms := MemoryGradNorms(ForwardBackward(c, x, y))
+	if len(norms) != len(x) {
+		t.Fatalf("%d norms, expected %d", len(norms), len(x))
+	}
+	// The reads of the last time instant feed no controller, so no gradient enters the memory then.
+	last := len(norms) - 1
+	if norms[last] != 0 {
+		t.Errorf("last norm %f, expected 0", norms[last])
+	}
+	for tt, n := range norms[:last] {
+		if !(n > 0) {
+			t.Errorf("[%d] norm %f is not positive", tt, n)
+		}
+	}
+}
+
+func TestLogits(t *testing.T) {
+	vectorSize := 2
+	c := NewEmptyController1(vectorSize+2, vectorSize, 6, 1, 5, 3)
+	rnd := rand.New(rand.NewSource(22))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(2, vectorSize)
+	machines := ForwardBackward(c, x, y)
+	logits := Logits(machines)
+	pdts := Predictions(machines)
+	if len(logits) != len(pdts) {
+		t.Fatalf("%d logits, expected %d", len(logits), len(pdts))
+	}
+	for tt := range pdts {
+		for i, p := range pdts[tt] {
+			if s := Sigmoid(logits[tt][i]); math.Abs(s-p) > 1e-12 {
+				t.Errorf("[%d][%d] Sigmoid(%f) = %f, prediction %f", tt, i, logits[tt][i], s, p)
+			}
+		}
+	}
+}
+
+func TestInitOutputBias(t *testing.T) {
+	vectorSize := 3
+	controllers := []Controller{
+		NewEmptyController1(vectorSize+2, vectorSize, 5, 1, 6, 3),
+		NewEmptyController1Deep(vectorSize+2, vectorSize, []int{5, 4}, 1, 6, 3),
+	}
+	for _, c := range controllers {
+		rnd := rand.New(rand.NewSource(35))
+		c.Weights(func(u *Unit) { u.Val = 0.01 * (rnd.Float64() - 0.5) })
+		baseRate := 0.1
+		InitOutputBias(c, baseRate)
+		x := MakeTensor2(4, vectorSize+2)
+		for tt, p := range Predictions(forward(c, x)) {
+			for i, v := range p {
+				if math.Abs(v-baseRate) > 0.01 {
+					t.Errorf("%T: prediction [%d][%d] %f, expected about %f", c, tt, i, v, baseRate)
+				}
+			}
+		}
+	}
+}
+
+func TestReadVectors(t *testing.T) {
+	vectorSize, numHeads, m := 2, 2, 3
+	c := NewEmptyController1(vectorSize+2, vectorSize, 6, numHeads, 5, m)
+	rnd := rand.New(rand.NewSource(24))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(2, vectorSize)
+	machines := ForwardBackward(c, x, y)
+	reads := ReadVectors(machines)
+	if len(reads) != len(machines) {
+		t.Fatalf("%d time instants, expected %d", len(reads), len(machines))
+	}
+	for tt, r := range reads {
+		if len(r) != numHeads {
+			t.Fatalf("[%d] %d heads, expected %d", tt, len(r), numHeads)
+		}
+		vals := machines[tt].memOp.ReadVals()
+		for i := range r {
+			if len(r[i]) != m {
+				t.Fatalf("[%d][%d] read size %d, expected %d", tt, i, len(r[i]), m)
+			}
+			for j, v := range r[i] {
+				if v != vals[i][j] {
+					t.Errorf("[%d][%d][%d] %f != %f", tt, i, j, v, vals[i][j])
+				}
+			}
+		}
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	newMachine := func(w ...[]float64) *NTM {
+		return &NTM{memOp: &memOp{WM: &writtenMemory{Top: makeTensorUnit2(len(w[0]), 1), w: w}}}
+	}
+	machines := []*NTM{
+		newMachine([]float64{1, 0, 0, 0}),
+		newMachine([]float64{0, 0.5, 0, 0}, []float64{0, 0.5, 0, 0}),
+		newMachine([]float64{0, 0, 0.2, 0}),
+	}
+	usage := MemoryUsage(machines)
+	expected := [][]float64{
+		{1, 0, 0, 0},
+		{1, 0.75, 0, 0},
+		{1, 0.75, 0.2, 0},
+	}
+	for tt := range expected {
+		for i := range expected[tt] {
+			if math.Abs(usage[tt][i]-expected[tt][i]) > 1e-12 {
+				t.Errorf("[%d][%d] %f != %f", tt, i, usage[tt][i], expected[tt][i])
+			}
+		}
+	}
+
+	// Check a real run, in which the untouched locations should be much less used.
+	c := NewEmptyController1(2, 2, 3, 1, 4, 2)
+	rnd := rand.New(rand.NewSource(9))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	machines = ForwardBackward(c, [][]float64{{0, 1}, {1, 0}}, [][]float64{{1, 0}, {0, 1}})
+	usage = MemoryUsage(machines)
+	w := machines[0].memOp.WM.w[0]
+	hi, lo := argmax(w), 0
+	for i := range w {
+		if w[i] < w[lo] {
+			lo = i
+		}
+	}
+	if usage[0][hi] <= usage[0][lo] {
+		t.Errorf("most written location has usage %f, least written %f", usage[0][hi], usage[0][lo])
+	}
+}
+
+func TestDecode(t *testing.T) {
+	y := [][]float64{{0, 1, 1}, {1, 0, 0}, {0, 0, 1}, {1, 1, 1}}
+	perfect := predictionMachines([][]float64{{0.1, 0.9, 0.8}, {0.7, 0.2, 0.4}, {0.3, 0.1, 0.6}, {0.9, 0.8, 0.55}})
+	if em := ExactMatch(y, DecodeBits(perfect, 0.5)); em != 1 {
+		t.Errorf("perfect predictions: expected exact match 1, got %f", em)
+	}
+
+	imperfect := predictionMachines([][]float64{{0.1, 0.9, 0.8}, {0.7, 0.6, 0.4}, {0.3, 0.1, 0.6}, {0.9, 0.8, 0.45}})
+	var em float64 = 0
+	for _, ms := range [][]*NTM{perfect, imperfect, perfect, imperfect} {
+		em += ExactMatch(y, DecodeBits(ms, 0.5))
+	}
+	if em /= 4; em != 0.5 {
+		t.Errorf("imperfect predictions: expected exact match 0.5, got %f", em)
+	}
+
+	// Truncated decodings do not match, rather than panic.
+	bits := DecodeBits(perfect, 0.5)
+	if em := ExactMatch(y, bits[:2]); em != 0 {
+		t.Errorf("decoding with fewer steps: expected exact match 0, got %f", em)
+	}
+	truncated := append([][]int{bits[0][:1]}, bits[1:]...)
+	if em := ExactMatch(y, truncated); em != 0 {
+		t.Errorf("decoding with fewer bits: expected exact match 0, got %f", em)
+	}
+
+	idxs := DecodeArgmax(imperfect)
+	expected := []int{1, 0, 2, 0}
+	for i := range expected {
+		if idxs[i] != expected[i] {
+			t.Errorf("expected argmax %v, got %v", expected, idxs)
+			break
+		}
+	}
+	if idxs := DecodeArgmax(nil); idxs != nil {
+		t.Errorf("expected nil argmax without time instants, got %v", idxs)
+	}
+}
+
+func TestAdaGrad(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	vectorSize := 3
+	x, y := copytask.GenSeq(4, vectorSize)
+	c := NewEmptyController1(vectorSize+2, vectorSize, 10, 1, 8, 4)
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+
+	a := NewAdaGrad(c)
+	prev := make([]float64, len(a.Accum))
+	initLoss := Loss(y, a.Train(x, y, 0.1, 1e-8))
+	var l float64
+	for i := 0; i < 50; i++ {
+		copy(prev, a.Accum)
+		l = Loss(y, a.Train(x, y, 0.1, 1e-8))
+		for j, acc := range a.Accum {
+			if acc < prev[j] {
+				t.Fatalf("accumulator %d decreased from %f to %f", j, prev[j], acc)
+			}
+		}
+	}
+	if l >= initLoss {
+		t.Errorf("loss did not improve, initial %f, final %f", initLoss, l)
+	}
+}
+
+func TestOnlineNTM(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+	vectorSize := 3
+	x, y := copytask.GenSeq(5, vectorSize)
+	c := NewEmptyController1(vectorSize+2, vectorSize, 6, 2, 8, 4)
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	expected := Predictions(ForwardBackward(c, x, y))
+
+	o := NewOnlineNTM(c)
+	for i, xi := range x {
+		pdt := o.Step(xi)
+		for j := range pdt {
+			if pdt[j] != expected[i][j] {
+				t.Fatalf("prediction at time %d differs: %v != %v", i, pdt, expected[i])
+			}
+		}
+	}
+}
+
+func TestMemoryShape(t *testing.T) {
+	controllers := []Controller{
+		NewEmptyController1(3, 2, 4, 2, 128, 20),
+		NewEmptyController1Deep(3, 2, []int{4, 3}, 2, 128, 20),
+	}
+	for _, c := range controllers {
+		if n, m := MemoryShape(c); n != 128 || m != 20 {
+			t.Errorf("%T: memory shape (%d, %d), expected (128, 20)", c, n, m)
+		}
+	}
+}
+
+func TestInitialHeadWeights(t *testing.T) {
+	vectorSize, n := 2, 4
+	c := NewEmptyController1(vectorSize+2, vectorSize, 5, 2, n, 3)
+	rnd := rand.New(rand.NewSource(34))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+
+	w := []float64{0.7, 0.1, 0.2, 0}
+	if err := SetInitialHeadWeights(c, 1, []float64{7, 1, 2, 0}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	ws := InitialHeadWeights(c)
+	for j, v := range ws[1] {
+		if math.Abs(v-w[j]) > 1e-12 {
+			t.Errorf("initial weight %d %f, expected %f", j, v, w[j])
+		}
+	}
+	if err := SetInitialHeadWeights(c, 2, w); err == nil {
+		t.Errorf("no error for a head out of range")
+	}
+	if err := SetInitialHeadWeights(c, 0, w[:3]); err == nil {
+		t.Errorf("no error for the wrong number of weights")
+	}
+	if err := SetInitialHeadWeights(c, 0, []float64{1, -1, 0, 0}); err == nil {
+		t.Errorf("no error for a negative weight")
+	}
+
+	// The initial weights are learned.
+	x, y := copytask.GenSeq(2, vectorSize)
+	ForwardBackward(c, x, y)
+	enumerated := make(map[*Unit]bool)
+	c.Weights(func(u *Unit) { enumerated[u] = true })
+	for i, biases := range c.Wtm1BiasV() {
+		var g float64 = 0
+		for _, b := range biases {
+			if !enumerated[&b.Top] {
+				t.Fatalf("head %d: bias value not enumerated by Weights", i)
+			}
+			g += math.Abs(b.Top.Grad)
+		}
+		if g == 0 {
+			t.Errorf("head %d: zero gradients of the initial weights", i)
+		}
+	}
+}
+
+func TestGenerateFreeRunning(t *testing.T) {
+	// A controller that ignores its memory and outputs its input rotated by one bit,
+	// so that feeding back its outputs cycles a single set bit through every position.
+	size := 4
+	c := NewEmptyController1(size, size, size, 1, 3, 2)
+	for i := 0; i < size; i++ {
+		c.Wh1x[i][i] = Unit{Val: 20}
+		c.Wh1b[i] = Unit{Val: -10}
+		c.Wyh1[(i+size-1)%size][i] = Unit{Val: 20}
+		c.Wyh1[i][size] = Unit{Val: -10}
+	}
+	prefix := [][]float64{{0, 0, 0, 0}, {1, 0, 0, 0}}
+	steps := 2*size + 1
+	generated := GenerateFreeRunning(c, prefix, steps)
+	if len(generated) != steps {
+		t.Fatalf("%d steps generated, expected %d", len(generated), steps)
+	}
+	for tt, g := range generated {
+		for i, v := range g {
+			var expected float64
+			if i == (size-1-tt%size+size)%size {
+				expected = 1
+			}
+			if v != expected {
+				t.Fatalf("step %d generated %v", tt, g)
+			}
+		}
+	}
+}
+
+func TestBitsPerSequence(t *testing.T) {
+	// A prediction of 0.5 costs exactly one bit regardless of the ground truth.
+	y := [][]float64{{0, 1, 1}, {1, 0, 0}}
+	machines := predictionMachines([][]float64{{0.5, 0.5, 0.5}, {0.5, 0.5, 0.5}})
+	if bps := BitsPerSequence(y, machines); math.Abs(bps-6) > 1e-12 {
+		t.Errorf("expected 6 bits per sequence, got %f", bps)
+	}
+	if bpb := BitsPerBit(y, machines); math.Abs(bpb-1) > 1e-12 {
+		t.Errorf("expected 1 bit per bit, got %f", bpb)
+	}
+	if bpb := BitsPerBit(nil, nil); bpb != 0 {
+		t.Errorf("expected 0 bits per bit without outputs, got %f", bpb)
+	}
+}
+
+func TestForwardBackwardDimError(t *testing.T) {
+	c := NewEmptyController1(3, 2, 4, 1, 5, 2)
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}}
+	y := [][]float64{{0, 1}, {1, 0}}
+	tests := []struct {
+		x, y     [][]float64
+		expected DimError
+	}{
+		{[][]float64{{1, 0, 1}, {0, 1}}, y, DimError{Axis: "x", Expected: 3, Got: 2}},
+		{x, [][]float64{{0, 1}, {1, 0, 1}}, DimError{Axis: "y", Expected: 2, Got: 3}},
+		{x, y[0:1], DimError{Axis: "T", Expected: 2, Got: 1}},
+		{nil, nil, DimError{Axis: "T", Expected: 1, Got: 0}},
+	}
+	for _, test := range tests {
+		err := CheckDims(c, test.x, test.y)
+		if err != test.expected {
+			t.Errorf("CheckDims returned %v, expected %v", err, test.expected)
+		}
+
+		func() {
+			defer func() {
+				r := recover()
+				if r != test.expected {
+					t.Errorf("ForwardBackward panicked with %v, expected %v", r, test.expected)
+				}
+			}()
+			ForwardBackward(c, test.x, test.y)
+		}()
+	}
+	if err := CheckDims(c, x, y); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != (DimError{Axis: "T", Expected: 1, Got: 0}) {
+				t.Errorf("forward panicked with %v on an empty input", r)
+			}
+		}()
+		forward(c, nil)
+	}()
+	if s := (DimError{Axis: "x", Expected: 3, Got: 2}).Error(); s != "ntm: x has size 2, expected 3" {
+		t.Errorf("unexpected message %q", s)
+	}
+}
+
+func TestGradientNormsPerStep(t *testing.T) {
+	c := NewEmptyController1(3, 2, 4, 1, 5, 2)
+	rnd := rand.New(rand.NewSource(20))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	machines := ForwardBackward(c, [][]float64{{1, 0, 1}, {0, 1, 0}}, [][]float64{{0, 1}, {1, 0}})
+	norms := GradientNormsPerStep(machines)
+	if len(norms) != 2 {
+		t.Fatalf("%d norms, expected 2", len(norms))
+	}
+	for i, n := range norms {
+		if math.IsNaN(n) || math.IsInf(n, 0) || n <= 0 {
+			t.Errorf("norm %d is %f", i, n)
+		}
+	}
+
+	// The memory heads of the last step do not affect the loss, so only the output gradient enters the controller.
+	var sum float64 = 0
+	for _, y := range machines[1].Controller.Y() {
+		sum += y.Grad * y.Grad
+	}
+	if math.Abs(norms[1]-math.Sqrt(sum)) > 1e-12 {
+		t.Errorf("norm %f, expected the output gradient norm %f", norms[1], math.Sqrt(sum))
+	}
+}
+
+func TestForwardBackwardCheckedNaN(t *testing.T) {
+	x := [][]float64{{0, 1}, {1, 0}}
+	y := [][]float64{{1, 0}, {0, 1}}
+	m := 2
+	c := NewEmptyController1(2, 2, 3, 1, 4, m, WithNaNCheck())
+	rnd := rand.New(rand.NewSource(12))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	if _, err := ForwardBackwardChecked(c, x, y); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	gamma := c.Wuh1[0][3*m+3]
+	gamma[len(gamma)-1].Val = math.NaN()
+	_, err := ForwardBackwardChecked(c, x, y)
+	nanErr, ok := err.(*NaNError)
+	if !ok {
+		t.Fatalf("expected a *NaNError, got %v", err)
+	}
+	if nanErr.Component != "refocus" || nanErr.Head != 0 {
+		t.Errorf("unexpected error %v", nanErr)
+	}
+	if !strings.Contains(nanErr.Error(), "refocus") {
+		t.Errorf("error does not name the component: %v", nanErr)
+	}
+
+	if _, err := ForwardBackwardChecked(c, x, y[:1]); err == nil {
+		t.Errorf("no error for mismatched dimensions")
+	}
+}
+
+func TestRMSPropMeanSquare(t *testing.T) {
+	c := NewEmptyController1(2, 2, 3, 1, 4, 2)
+	rnd := rand.New(rand.NewSource(13))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x := [][]float64{{0, 1}, {1, 0}, {1, 1}}
+	y := [][]float64{{1, 0}, {0, 1}, {1, 1}}
+	decay := 0.9
+	rmsp := NewRMSProp(c)
+	expected := make([]float64, c.NumWeights())
+	mean := make([]float64, c.NumWeights())
+	for step := 0; step < 5; step++ {
+		rmsp.Train(x, y, decay, 0.5, 1e-3, 1e-3)
+		i := 0
+		c.Weights(func(u *Unit) {
+			expected[i] = decay*expected[i] + (1-decay)*u.Grad*u.Grad
+			mean[i] = decay*mean[i] + (1-decay)*u.Grad
+			i++
+		})
+	}
+	ms := rmsp.MeanSquare()
+	v := rmsp.Variance()
+	for i := range ms {
+		if !(ms[i] > 0) || math.IsInf(ms[i], 0) {
+			t.Errorf("mean square %d is %f", i, ms[i])
+		}
+		if math.Abs(ms[i]-expected[i]) > 1e-12 {
+			t.Errorf("mean square %d %f != %f", i, ms[i], expected[i])
+		}
+		if math.Abs(v[i]-(expected[i]-mean[i]*mean[i])) > 1e-12 {
+			t.Errorf("variance %d %f != %f", i, v[i], expected[i]-mean[i]*mean[i])
+		}
+	}
+	ms[0] = -1
+	if rmsp.N[0] == -1 {
+		t.Errorf("MeanSquare does not return a copy")
+	}
+}
+
+func TestArena(t *testing.T) {
+	newController := func() Controller {
+		c := NewEmptyController1(10, 8, 5, 2, 6, 3)
+		rnd := rand.New(rand.NewSource(17))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+		return c
+	}
+	fresh := newController()
+	a := NewArena(newController())
+	for _, length := range []int{3, 7, 2, 7} {
+		x, y := copytask.GenSeq(length, 8)
+		expected := ForwardBackward(fresh, x, y)
+		machines := a.Run(x, y)
+		if l, el := Loss(y, machines), Loss(y, expected); l != el {
+			t.Errorf("length %d: loss %f != %f", length, l, el)
+		}
+		var grads []float64
+		fresh.Weights(func(u *Unit) { grads = append(grads, u.Grad) })
+		i := 0
+		a.C.Weights(func(u *Unit) {
+			if u.Grad != grads[i] {
+				t.Errorf("length %d: gradient %d %f != %f", length, i, u.Grad, grads[i])
+			}
+			i++
+		})
+	}
+}
+
+func TestArenaAllocs(t *testing.T) {
+	c := NewEmptyController1(10, 8, 5, 2, 6, 3)
+	rnd := rand.New(rand.NewSource(53))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(5, 8)
+	a := NewArena(c)
+	a.Run(x, y)
+	fresh := testing.AllocsPerRun(10, func() { ForwardBackward(c, x, y) })
+	// The reused arrays save at least one allocation per written memory, one per time instant.
+	if reused := testing.AllocsPerRun(10, func() { a.Run(x, y) }); reused > fresh-float64(len(x)) {
+		t.Errorf("Run makes %.0f allocations, expected fewer than the %.0f of ForwardBackward by %d", reused, fresh, len(x))
+	}
+}
+
+func BenchmarkForwardBackward(b *testing.B) {
+	c := NewEmptyController1(10, 8, 100, 1, 128, 20)
+	rnd := rand.New(rand.NewSource(19))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(10, 8)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ForwardBackward(c, x, y)
+	}
+}
+
+// copyTaskAllocs is the number of allocations of a copyTaskStep, any increase of which warrants a look at the forward and backward passes.
+const copyTaskAllocs = 8070
+
+// copyTaskStep returns a training step of a fixed, small copy task.
+// It draws only from local random sources, so its allocations are stable across runs.
+func copyTaskStep() func() {
+	vectorSize := 8
+	c := NewEmptyController1(vectorSize+2, vectorSize, 32, 1, 16, 8)
+	rnd := rand.New(rand.NewSource(36))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	opts := copytask.DefaultGenSeqOpts()
+	opts.Rand = rand.New(rand.NewSource(37))
+	x, y := copytask.GenSeqWithOpts(8, vectorSize, opts)
+	rmsp := NewRMSProp(c)
+	return func() { rmsp.Train(x, y, 0.95, 0.5, 1e-3, 1e-3) }
+}
+
+func TestCopyTaskStepAllocs(t *testing.T) {
+	if allocs := testing.AllocsPerRun(10, copyTaskStep()); allocs > copyTaskAllocs {
+		t.Errorf("a copy task step makes %.0f allocations, more than the %d of copyTaskAllocs", allocs, copyTaskAllocs)
+	}
+}
+
+// BenchmarkCopyTaskStep guards the hot path of training with a copyTaskStep, whose allocations are checked by TestCopyTaskStepAllocs.
+func BenchmarkCopyTaskStep(b *testing.B) {
+	step := copyTaskStep()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		step()
+	}
+}
+
+func BenchmarkArenaRun(b *testing.B) {
+	c := NewEmptyController1(10, 8, 100, 1, 128, 20)
+	rnd := rand.New(rand.NewSource(19))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(10, 8)
+	a := NewArena(c)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Run(x, y)
+	}
+}
+
+func TestGradTransform(t *testing.T) {
+	x, y := copytask.GenSeq(3, 4)
+	newController := func() Controller {
+		c := NewEmptyController1(6, 4, 5, 1, 6, 3)
+		rnd := rand.New(rand.NewSource(20))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+		return c
+	}
+	weights := func(c Controller) []float64 {
+		var w []float64
+		c.Weights(func(u *Unit) { w = append(w, u.Val) })
+		return w
+	}
+	zero := func(u *Unit) { u.Grad = 0 }
+
+	trains := []func(c Controller, f func(*Unit)){
+		func(c Controller, f func(*Unit)) {
+			s := NewSGDMomentum(c)
+			s.GradTransform = f
+			s.Train(x, y, 1e-2, 0.9)
+		},
+		func(c Controller, f func(*Unit)) {
+			r := NewRMSProp(c)
+			r.GradTransform = f
+			r.Train(x, y, 0.95, 0.5, 1e-3, 1e-3)
+		},
+		func(c Controller, f func(*Unit)) {
+			a := NewAdaGrad(c)
+			a.GradTransform = f
+			a.Train(x, y, 1e-2, 1e-6)
+		},
+	}
+	for i, train := range trains {
+		c := newController()
+		before := weights(c)
+		train(c, zero)
+		for j, w := range weights(c) {
+			if w != before[j] {
+				t.Errorf("optimizer %d changed weight %d with zero gradients: %f != %f", i, j, w, before[j])
+			}
+		}
+	}
+
+	plain, halved := newController(), newController()
+	before := weights(plain)
+	trains[0](plain, nil)
+	trains[0](halved, func(u *Unit) { u.Grad *= 0.5 })
+	pw, hw := weights(plain), weights(halved)
+	for j := range before {
+		if d, hd := pw[j]-before[j], hw[j]-before[j]; math.Abs(hd-0.5*d) > 1e-12 {
+			t.Errorf("weight %d update %g, expected %g", j, hd, 0.5*d)
+		}
+	}
+}
+
+func TestForwardBackwardMasked(t *testing.T) {
+	c := NewEmptyController1(3, 2, 4, 1, 5, 2)
+	rnd := rand.New(rand.NewSource(48))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}, {1, 1, 0}}
+	y := [][]float64{{0, 1}, {1, 0}, {1, 1}}
+	mask := []bool{false, true, false}
+	grads := func() []float64 {
+		g := make([]float64, 0, c.NumWeights())
+		c.Weights(func(u *Unit) { g = append(g, u.Grad) })
+		return g
+	}
+	machines := ForwardBackwardMasked(c, x, y, mask)
+	masked := grads()
+
+	// Unscored outputs that equal their predictions contribute no gradient either.
+	yp := Predictions(machines)
+	yp[1] = y[1]
+	ForwardBackward(c, x, yp)
+	for i, g := range grads() {
+		if math.Abs(g-masked[i]) > 1e-12 {
+			t.Fatalf("gradient %d is %f, expected %f", i, masked[i], g)
+		}
+	}
+
+	ForwardBackward(c, x, y)
+	full := grads()
+	ForwardBackwardMasked(c, x, y, nil)
+	for i, g := range grads() {
+		if g != full[i] {
+			t.Fatalf("gradient %d with a nil mask is %f, expected %f", i, g, full[i])
+		}
+	}
+
+	expected := LossMasked(y, machines, mask) / 2
+	if bpb := BitsPerBitMasked(y, machines, mask); math.Abs(bpb-expected) > 1e-12 {
+		t.Errorf("masked bits-per-bit %f, expected %f", bpb, expected)
+	}
+}
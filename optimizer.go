@@ -0,0 +1,155 @@
+package ntm
+
+import (
+	"fmt"
+	"sort"
+)
+
+// An Optimizer updates the weights of a controller with the gradients computed by ForwardBackward.
+// The hyperparameters of an Optimizer are set when it is constructed.
+type Optimizer interface {
+	// Step updates the weights of the controller after ForwardBackward has run on the controller to produce machines.
+	// The optimizers of this package use only the gradients accumulated in the controller and ignore machines,
+	// which is passed for optimizers that depend on the forward pass, such as on the loss or the memory contents.
+	Step(machines []*NTM)
+	// Name returns the name under which the optimizer is registered, see NewOptimizer.
+	Name() string
+}
+
+var (
+	_ Optimizer = (*SGDMomentum)(nil)
+	_ Optimizer = (*RMSProp)(nil)
+	_ Optimizer = (*AdaGrad)(nil)
+)
+
+// lrMultipliers scale the learning rates of the weights of a controller by the multipliers of their weight groups.
+type lrMultipliers struct {
+	mults  map[string]float64
+	scales []float64 // the multiplier of every weight in the order of Controller.Weights, nil if no multiplier is set
+}
+
+// set sets the multiplier of the weights of a group of c.
+func (lm *lrMultipliers) set(c Controller, group string, mult float64) error {
+	g, err := groupedController(c, group)
+	if err != nil {
+		return err
+	}
+	if lm.mults == nil {
+		lm.mults = make(map[string]float64)
+	}
+	lm.mults[group] = mult
+
+	groupMults := make(map[*Unit]float64, c.NumWeights())
+	for group, mult := range lm.mults {
+		g.weightGroup(group, func(u *Unit) { groupMults[u] = mult })
+	}
+	lm.scales = make([]float64, 0, c.NumWeights())
+	c.Weights(func(u *Unit) {
+		mult, ok := groupMults[u]
+		if !ok {
+			mult = 1
+		}
+		lm.scales = append(lm.scales, mult)
+	})
+	return nil
+}
+
+// scale returns the multiplier of the i-th weight.
+func (lm *lrMultipliers) scale(i int) float64 {
+	if lm.scales == nil {
+		return 1
+	}
+	return lm.scales[i]
+}
+
+// An optimizerFactory creates an Optimizer with its default hyperparameters,
+// and returns the hyperparameters of an Optimizer it created keyed by their names, or those of a zero Optimizer if o is nil.
+type optimizerFactory struct {
+	create func(c Controller) Optimizer
+	params func(o Optimizer) map[string]*float64
+}
+
+// optimizers are the registered optimizers, keyed by their names.
+var optimizers = map[string]optimizerFactory{
+	"sgdmomentum": {
+		create: func(c Controller) Optimizer { return NewSGDMomentum(c) },
+		params: func(o Optimizer) map[string]*float64 {
+			s, ok := o.(*SGDMomentum)
+			if !ok {
+				s = &SGDMomentum{}
+			}
+			return map[string]*float64{"lr": &s.LearningRate, "momentum": &s.Momentum}
+		},
+	},
+	"rmsprop": {
+		create: func(c Controller) Optimizer { return NewRMSProp(c) },
+		params: func(o Optimizer) map[string]*float64 {
+			r, ok := o.(*RMSProp)
+			if !ok {
+				r = &RMSProp{}
+			}
+			return map[string]*float64{"decay": &r.Decay, "momentum": &r.Momentum, "lr": &r.LearningRate, "epsilon": &r.Epsilon}
+		},
+	},
+	"adagrad": {
+		create: func(c Controller) Optimizer { return NewAdaGrad(c) },
+		params: func(o Optimizer) map[string]*float64 {
+			a, ok := o.(*AdaGrad)
+			if !ok {
+				a = &AdaGrad{}
+			}
+			return map[string]*float64{"lr": &a.LearningRate, "epsilon": &a.Epsilon}
+		},
+	},
+}
+
+// OptimizerNames returns the sorted names of the optimizers that can be created by NewOptimizer.
+func OptimizerNames() []string {
+	names := make([]string, 0, len(optimizers))
+	for name := range optimizers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewOptimizer returns the optimizer registered under name for the controller c.
+// The hyperparameters are given by params, whose keys are among "lr", "momentum", "decay" and "epsilon" depending on the optimizer.
+// Missing hyperparameters take the default values set by the constructor of the optimizer, such as NewRMSProp.
+func NewOptimizer(name string, c Controller, params map[string]float64) (Optimizer, error) {
+	opt, p, err := newOptimizer(name, c)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range params {
+		if _, ok := p[k]; !ok {
+			return nil, fmt.Errorf("ntm: unknown parameter %q of optimizer %q", k, name)
+		}
+		*p[k] = v
+	}
+	return opt, nil
+}
+
+// newOptimizer returns the optimizer registered under name for the controller c with its default hyperparameters,
+// together with its hyperparameters keyed by their names, through which they can be changed between steps.
+func newOptimizer(name string, c Controller) (Optimizer, map[string]*float64, error) {
+	f, ok := optimizers[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("ntm: unknown optimizer %q", name)
+	}
+	opt := f.create(c)
+	return opt, f.params(opt), nil
+}
+
+// optimizerParams returns the names of the hyperparameters of the optimizer registered under name.
+func optimizerParams(name string) (map[string]bool, error) {
+	f, ok := optimizers[name]
+	if !ok {
+		return nil, fmt.Errorf("ntm: unknown optimizer %q", name)
+	}
+	names := make(map[string]bool)
+	for k := range f.params(nil) {
+		names[k] = true
+	}
+	return names, nil
+}
@@ -0,0 +1,152 @@
+package ntm
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/fumin/ntm/copytask"
+)
+
+func TestNewOptimizer(t *testing.T) {
+	params := map[string]map[string]float64{
+		"sgdmomentum": {"lr": 1e-2},
+		"rmsprop":     {"lr": 1e-2},
+		"adagrad":     {"lr": 5e-2},
+	}
+	for _, name := range OptimizerNames() {
+		c := NewEmptyController1(6, 4, 20, 1, 8, 4)
+		rnd := rand.New(rand.NewSource(21))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+		opt, err := NewOptimizer(name, c, params[name])
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if opt.Name() != name {
+			t.Errorf("optimizer %q is named %q", name, opt.Name())
+		}
+
+		var first, last float64
+		steps := 300
+		for i := 0; i < steps; i++ {
+			x, y := copytask.GenSeq(2, 4)
+			machines := ForwardBackward(c, x, y)
+			opt.Step(machines)
+			bpb := BitsPerBit(y, machines)
+			if i < 50 {
+				first += bpb
+			}
+			if i >= steps-50 {
+				last += bpb
+			}
+		}
+		if last >= first {
+			t.Errorf("optimizer %q did not reduce the loss: %f >= %f", name, last/50, first/50)
+		}
+	}
+
+	// Optimizers with the default hyperparameters of their constructors update the weights.
+	for _, name := range OptimizerNames() {
+		c := NewEmptyController1(6, 4, 5, 1, 8, 4)
+		rnd := rand.New(rand.NewSource(51))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+		before := make([]float64, 0, c.NumWeights())
+		c.Weights(func(u *Unit) { before = append(before, u.Val) })
+		opt, err := NewOptimizer(name, c, nil)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		x, y := dropoutSeqs(rnd, 3, 6, 4)
+		opt.Step(ForwardBackward(c, x, y))
+		changed := false
+		i := 0
+		c.Weights(func(u *Unit) {
+			changed = changed || u.Val != before[i]
+			i++
+		})
+		if !changed {
+			t.Errorf("optimizer %q with default hyperparameters did not update the weights", name)
+		}
+	}
+
+	if _, err := NewOptimizer("nosuch", nil, nil); err == nil {
+		t.Errorf("no error for an unknown optimizer")
+	}
+	if _, err := NewOptimizer("rmsprop", NewEmptyController1(2, 2, 2, 1, 2, 2), map[string]float64{"beta": 1}); err == nil {
+		t.Errorf("no error for an unknown parameter")
+	}
+}
+
+func TestSetLRMultiplier(t *testing.T) {
+	vectorSize := 2
+	c := NewEmptyController1(vectorSize+2, vectorSize, 4, 1, 5, 3)
+	rnd := rand.New(rand.NewSource(29))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(2, vectorSize)
+
+	lr, mult := 1e-2, 10.0
+	s := NewSGDMomentum(c)
+	if err := s.SetLRMultiplier(GroupHeads, mult); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := s.SetLRMultiplier("beta", mult); err == nil {
+		t.Errorf("no error for an unknown group")
+	}
+	before := make(map[*Unit]float64)
+	c.Weights(func(u *Unit) { before[u] = u.Val })
+	ForwardBackward(c, x, y)
+	grads := make(map[*Unit]float64)
+	c.Weights(func(u *Unit) { grads[u] = u.Grad })
+	s.LearningRate = lr
+	s.Step(nil)
+
+	for _, group := range []string{GroupController, GroupHeads, GroupMemoryInit} {
+		scale := 1.0
+		if group == GroupHeads {
+			scale = mult
+		}
+		c.weightGroup(group, func(u *Unit) {
+			if d, expected := u.Val-before[u], -lr*scale*grads[u]; math.Abs(d-expected) > 1e-12 {
+				t.Errorf("%s weight changed by %g, expected %g", group, d, expected)
+			}
+		})
+	}
+}
+
+func TestSGDMomentumNesterov(t *testing.T) {
+	lr, mt := 0.1, 0.9
+	grads := []float64{1, -2, 0.5}
+	prevD := []float64{0.3, 0.3, -0.2}
+	update := func(nesterov bool) ([]float64, []float64) {
+		c := NewEmptyController1(1, 1, 1, 1, 1, 1)
+		s := NewSGDMomentum(c)
+		s.LearningRate = lr
+		s.Momentum = mt
+		s.Nesterov = nesterov
+		i := 0
+		c.Weights(func(u *Unit) {
+			u.Grad = grads[i%len(grads)]
+			s.PrevD[i] = prevD[i%len(prevD)]
+			i++
+		})
+		s.Step(nil)
+		return Snapshot(c), s.PrevD
+	}
+
+	classical, classicalD := update(false)
+	nesterov, nesterovD := update(true)
+	for i := range classical {
+		g, v := grads[i%len(grads)], prevD[i%len(prevD)]
+		d := -lr*g + mt*v
+		if math.Abs(classical[i]-d) > 1e-12 {
+			t.Errorf("classical update %d %f, expected %f", i, classical[i], d)
+		}
+		// The Nesterov update looks ahead along the new velocity.
+		if expected := -(1+mt)*lr*g + mt*mt*v; math.Abs(nesterov[i]-expected) > 1e-12 {
+			t.Errorf("nesterov update %d %f, expected %f", i, nesterov[i], expected)
+		}
+		if classicalD[i] != nesterovD[i] {
+			t.Errorf("velocity %d %f != %f", i, nesterovD[i], classicalD[i])
+		}
+	}
+}
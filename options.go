@@ -0,0 +1,568 @@
+package ntm
+
+import (
+	"fmt"
+	"math"
+)
+
+// A ControllerOption configures an optional feature of a controller.
+type ControllerOption func(*controllerConfig)
+
+type controllerConfig struct {
+	head             headConfig
+	modes            []AddressingMode
+	noReadFeedback   bool
+	output           OutputMode
+	dropout          *dropoutConfig
+	matMul           MatMul
+	shareHeadWeights bool
+	temperature      bool
+}
+
+func newControllerConfig(opts []ControllerOption) controllerConfig {
+	var cfg controllerConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// headConfig returns the configuration of the i-th memory head.
+func (cfg controllerConfig) headConfig(i int) headConfig {
+	h := cfg.head
+	if i < len(cfg.modes) {
+		h.mode = cfg.modes[i]
+	}
+	return h
+}
+
+// numHeadProjections returns the total number of distinct rows of the weights projecting the controller's hidden layer
+// onto the units of numHeads memory heads operating on a memory whose rows have size m.
+func (cfg controllerConfig) numHeadProjections(numHeads, m int) int {
+	if cfg.shareHeadWeights && numHeads > 1 {
+		numHeads = 1
+	}
+	n := 0
+	for i := 0; i < numHeads; i++ {
+		n += cfg.headConfig(i).numProjections(m)
+	}
+	return n
+}
+
+// makeHeadProjections returns the weights projecting cols inputs onto the units of numHeads memory heads
+// operating on a memory whose rows have size m.
+// If the heads share their weights, the weights of every head are those of the first head.
+func (cfg controllerConfig) makeHeadProjections(numHeads, m, cols int) [][][]Unit {
+	w := make([][][]Unit, numHeads)
+	for i := range w {
+		if cfg.shareHeadWeights && i > 0 {
+			if cfg.headConfig(i) != cfg.headConfig(0) {
+				panic("ntm: memory heads of different configurations cannot share weights")
+			}
+			w[i] = w[0]
+			continue
+		}
+		w[i] = cfg.headConfig(i).makeProjection(m, cols)
+	}
+	return w
+}
+
+// doHeadWeights is similar to doUnit3 on the head weights w of a controller, except that tied rows are visited only once,
+// and shared head weights are visited only for the first head.
+// The heads operate on a memory whose rows have size m.
+func (cfg controllerConfig) doHeadWeights(w [][][]Unit, m int, f func([]int, *Unit)) {
+	if cfg.shareHeadWeights && len(w) > 1 {
+		w = w[:1]
+	}
+	for i, wi := range w {
+		hc := cfg.headConfig(i)
+		for j, wij := range wi {
+			if hc.tiedRow(j, m) {
+				continue
+			}
+			doUnit1(wij, func(ids []int, u *Unit) { f(append(ids, j, i), u) })
+		}
+	}
+}
+
+// divideByTemperature divides the pre-activation outputs y of a controller by the temperature exp(t.Val),
+// where t is nil for controllers without WithOutputTemperature.
+func divideByTemperature(y []Unit, t *Unit) {
+	if t == nil {
+		return
+	}
+	temperature := math.Exp(t.Val)
+	for i := range y {
+		y[i].Val /= temperature
+	}
+}
+
+// temperatureGrads returns the gradients of the pre-activation outputs of a controller before they are divided by the temperature exp(t.Val),
+// adding the gradient of the temperature to t.
+// y are the outputs of the controller, and logits their values before activation and after division by the temperature.
+func temperatureGrads(y []Unit, logits []float64, t *Unit) []float64 {
+	grads := unitGrads(y)
+	if t == nil {
+		return grads
+	}
+	temperature := math.Exp(t.Val)
+	for i, g := range grads {
+		// The derivative of z/exp(t) with respect to t is -z/exp(t).
+		t.Grad -= g * logits[i]
+		grads[i] = g / temperature
+	}
+	return grads
+}
+
+// activateOutputs replaces the pre-activation outputs y of a controller with their activations.
+func (cfg controllerConfig) activateOutputs(y []Unit) {
+	switch cfg.output {
+	case SoftmaxOutput:
+		max := math.Inf(-1)
+		for _, u := range y {
+			max = math.Max(max, u.Val)
+		}
+		var sum float64 = 0
+		for i := range y {
+			y[i].Val = math.Exp(y[i].Val - max)
+			sum += y[i].Val
+		}
+		for i := range y {
+			y[i].Val = y[i].Val / sum
+		}
+	default:
+		for i := range y {
+			y[i].Val = Sigmoid(y[i].Val)
+		}
+	}
+}
+
+// validate returns an error describing the first option that is invalid for a memory of n locations of size m.
+func (cfg controllerConfig) validate(n, m int) error {
+	if cfg.head.maxShift >= n {
+		return fmt.Errorf("ntm: maximum shift %d must be less than the %d memory locations", cfg.head.maxShift, n)
+	}
+	if cfg.head.readOnly && cfg.head.readOnlyRow >= n {
+		return fmt.Errorf("ntm: read-only memory location %d out of range [0, %d)", cfg.head.readOnlyRow, n)
+	}
+	if cfg.head.keyWidthSet && (cfg.head.keyColumns <= 0 || cfg.head.keyColumns > m) {
+		return fmt.Errorf("ntm: key width %d out of range [1, %d]", cfg.head.keyColumns, m)
+	}
+	return nil
+}
+
+// numReadInputs returns the number of controller inputs taken up by the reads of numHeads memory heads
+// operating on a memory whose rows have size m.
+func (cfg controllerConfig) numReadInputs(numHeads, m int) int {
+	if cfg.noReadFeedback {
+		return 0
+	}
+	return numHeads * cfg.readWidth(m)
+}
+
+// readWidth returns the size of the read of a memory head operating on a memory whose rows have size m,
+// which concatenates the reads of all its weightings.
+func (cfg controllerConfig) readWidth(m int) int {
+	return cfg.head.numWeightings() * m
+}
+
+// headConfig determines the layout of a head's units and how they are used to operate on the memory.
+type headConfig struct {
+	writeGate       bool
+	maxShift        int
+	mode            AddressingMode
+	shiftLogits     bool
+	epsilon         float64
+	tieEraseAdd     bool
+	nanCheck        bool
+	maxBeta         float64
+	maxGamma        float64
+	similarity      SimilarityMode
+	keyColumns      int  // the key width, or 0 for the full rows
+	keyWidthSet     bool // whether keyColumns was set by WithKeyWidth
+	normalizeKey    bool
+	addActivation   AddActivation
+	compensatedSums bool
+	addresser       Addresser
+	readOnly        bool // whether the head never writes to the memory location readOnlyRow
+	readOnlyRow     int
+	readWeightings  int // the number of weightings with which the head reads, or 0 for 1
+}
+
+// shiftRange returns the maximum number of locations by which a head can shift its addressing weights.
+func (cfg headConfig) shiftRange() int {
+	if cfg.maxShift == 0 {
+		return 1
+	}
+	return cfg.maxShift
+}
+
+// refocusEpsilon returns the threshold below which shifted weights are ignored in the backward pass of sharpening.
+func (cfg headConfig) refocusEpsilon() float64 {
+	if cfg.epsilon == 0 {
+		return MachineEpsilon
+	}
+	return cfg.epsilon
+}
+
+// numShiftUnits returns the number of units a head uses to determine its shift.
+func (cfg headConfig) numShiftUnits() int {
+	if cfg.shiftLogits {
+		return 2*cfg.shiftRange() + 1
+	}
+	return 1
+}
+
+// keyWidth returns the size of the key of a head operating on a memory whose rows have size m,
+// which is the number of leading columns of the memory rows compared with the key in content addressing.
+func (cfg headConfig) keyWidth(m int) int {
+	if cfg.keyColumns == 0 {
+		return m
+	}
+	return cfg.keyColumns
+}
+
+// numWeightings returns the number of weightings with which a head reads the memory, see WithReadWeightings.
+func (cfg headConfig) numWeightings() int {
+	if cfg.readWeightings == 0 {
+		return 1
+	}
+	return cfg.readWeightings
+}
+
+// numAddressingUnits returns the number of units with which a head computes one of its weightings on a memory whose rows have size m,
+// which are the key, the key strength and, unless the head is ContentOnly, the G, S and Gamma units.
+func (cfg headConfig) numAddressingUnits(m int) int {
+	n := cfg.keyWidth(m) + 1
+	if cfg.mode != ContentOnly {
+		n += 2 + cfg.numShiftUnits()
+	}
+	return n
+}
+
+// numUnits returns the number of units of a head operating on a memory whose rows have size m.
+// The units of the additional read weightings of the head follow the write gate.
+func (cfg headConfig) numUnits(m int) int {
+	n := 2*m + cfg.numWeightings()*cfg.numAddressingUnits(m)
+	if cfg.writeGate {
+		n++
+	}
+	return n
+}
+
+// readWeighting returns the configuration and the offset of the addressing units of the j-th additional read weighting of a head
+// operating on a memory whose rows have size m.
+func (cfg headConfig) readWeighting(j, m int) (headConfig, int) {
+	offset := (j + 1) * cfg.numAddressingUnits(m)
+	if cfg.writeGate {
+		offset++
+	}
+	cfg.writeGate = false
+	cfg.readOnly = false
+	cfg.readWeightings = 0
+	return cfg, offset
+}
+
+// numProjections returns the number of distinct rows of the weights projecting onto the units of a head
+// operating on a memory whose rows have size m.
+func (cfg headConfig) numProjections(m int) int {
+	if cfg.tieEraseAdd {
+		return cfg.numUnits(m) - m
+	}
+	return cfg.numUnits(m)
+}
+
+// tiedRow reports whether the j-th row of the weights projecting onto the units of a head is shared with another row.
+func (cfg headConfig) tiedRow(j, m int) bool {
+	return cfg.tieEraseAdd && j >= m && j < 2*m
+}
+
+// makeProjection returns the weights projecting cols inputs onto the units of a head operating on a memory whose rows have size m.
+// If the erase and add vectors are tied, the rows of the add vector are the same slices as those of the erase vector.
+func (cfg headConfig) makeProjection(m, cols int) [][]Unit {
+	w := makeTensorUnit2(cfg.numUnits(m), cols)
+	if cfg.tieEraseAdd {
+		for j := 0; j < m; j++ {
+			w[m+j] = w[j]
+		}
+	}
+	return w
+}
+
+// WithWriteGate adds a write gate to every memory head,
+// which scales the erase and add contributions of a head by the sigmoid of a controller output.
+// This allows a NTM to learn to skip writing to memory, for example in the output phase of the copy task.
+func WithWriteGate() ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.writeGate = true
+	}
+}
+
+// WithMaxShift restricts the location-based addressing of every memory head to shifts in the range (-maxShift, maxShift).
+// The default is a maxShift of 1, in which case the addressing weights are only rotated between neighbouring locations.
+// It panics if maxShift is not positive, and a controller panics if maxShift is not less than its number of memory locations.
+func WithMaxShift(maxShift int) ControllerOption {
+	if maxShift <= 0 {
+		panic(fmt.Sprintf("ntm: maximum shift %d is not positive", maxShift))
+	}
+	return func(cfg *controllerConfig) {
+		cfg.head.maxShift = maxShift
+	}
+}
+
+// WithShiftLogits makes every memory head emit a vector of shift logits in place of the scalar S,
+// one for each shift in -r, ..., r, where r is the shift range set by WithMaxShift.
+// The addressing weights are then rotated by the circular convolution with the softmax of the logits,
+// as in the NTM paper.
+func WithShiftLogits() ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.shiftLogits = true
+	}
+}
+
+// WithRefocusEpsilon sets the threshold below which shifted weights are treated as zero
+// when backpropagating through the sharpening step of every memory head.
+// The default is MachineEpsilon.
+// A larger epsilon avoids huge gradients for very peaked weights, at the cost of accuracy.
+func WithRefocusEpsilon(epsilon float64) ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.epsilon = epsilon
+	}
+}
+
+// WithReadFeedback sets whether the memory reads of time t-1 are fed into the controller at time t.
+// By default they are, and the controller input at time t is the concatenation of the reads of time t-1 and x[t], as in the NTM paper.
+// Without read feedback, the controller cannot use the memory, which is only useful as a baseline in ablation studies.
+func WithReadFeedback(feed bool) ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.noReadFeedback = !feed
+	}
+}
+
+// WithTiedEraseAdd makes the erase and add vectors of every memory head share their projection from the controller's hidden layer,
+// reducing the number of weights by the size of a memory row times the size of the hidden layer plus one, for each head.
+// The erase and add vectors are thus always equal.
+func WithTiedEraseAdd() ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.tieEraseAdd = true
+	}
+}
+
+// WithSharedHeadWeights makes all memory heads share the projection from the controller's hidden layer onto their units,
+// so that heads differ only by their previous weights, and the number of weights of the projection is divided by the number of heads.
+// The heads must have the same configuration, in particular the same addressing mode.
+func WithSharedHeadWeights() ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.shareHeadWeights = true
+	}
+}
+
+// WithBetaClamp clamps the key strength exp(beta) of every memory head to at most max.
+// Above the clamp, no gradient flows back into beta.
+func WithBetaClamp(max float64) ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.maxBeta = max
+	}
+}
+
+// WithGammaClamp clamps the sharpening exponent softplus(gamma)+1 of every memory head to at most max,
+// preventing the addressing weights from collapsing to a one-hot vector and math.Pow from overflowing.
+// Above the clamp, no gradient flows back into gamma.
+func WithGammaClamp(max float64) ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.maxGamma = max
+	}
+}
+
+// WithKeyWidth restricts the content addressing of every memory head to the first p columns of the memory rows,
+// which then act as the keys of a key-value memory, while reads and writes still operate on the full rows.
+// The keys emitted by the heads have size p.
+// The default is to compare keys with the full rows.
+// NewController1 returns an error if p is not in [1, m] for memory rows of size m.
+func WithKeyWidth(p int) ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.keyColumns = p
+		cfg.head.keyWidthSet = true
+	}
+}
+
+// WithNormalizedKeys divides the key of every memory head by its L2 norm before comparing it with the memory rows,
+// so that the key strength Beta alone controls the sharpness of content addressing.
+// This matters for DotProductSimilarity, since the cosine similarity is already independent of the magnitude of the key.
+func WithNormalizedKeys() ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.normalizeKey = true
+	}
+}
+
+// WithCompensatedSums makes every memory head normalize its content addressing and sharpened weights
+// with sums computed by Kahan summation, whose rounding error does not grow with the number of memory locations.
+// This matters little for float64 values and small memories, but keeps the weights normalized to 1 more accurately for large ones.
+func WithCompensatedSums() ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.compensatedSums = true
+	}
+}
+
+// WithReadOnlyLocation reserves the memory location i as a constant slot that no memory head writes to.
+// The location keeps its initial content, which is still learned through the reads of the heads,
+// and provides the heads with a stable reference such as a "null" slot.
+// Its content receives no gradient from the erase and add vectors, nor from the write weights of the heads,
+// which are zero at the location.
+// It panics if i is negative, and NewController1 returns an error if i is not a location of the memory.
+func WithReadOnlyLocation(i int) ControllerOption {
+	if i < 0 {
+		panic(fmt.Sprintf("ntm: memory location %d out of range", i))
+	}
+	return func(cfg *controllerConfig) {
+		cfg.head.readOnly = true
+		cfg.head.readOnlyRow = i
+	}
+}
+
+// WithReadWeightings makes every memory head read the memory with k weightings in place of one,
+// and concatenate the k read vectors, so that the read of a head has size k*m for memory rows of size m.
+// The first weighting is the one with which the head writes, and each of the others is computed by its own
+// key, key strength and, unless the head is ContentOnly, G, S and Gamma units, which the head emits after its write gate.
+// All the weightings of a head start from the initial weights of the head, see InitialHeadWeights.
+// The default is a single weighting.
+// It panics if k is not positive.
+func WithReadWeightings(k int) ControllerOption {
+	if k <= 0 {
+		panic(fmt.Sprintf("ntm: number of read weightings %d is not positive", k))
+	}
+	return func(cfg *controllerConfig) {
+		cfg.head.readWeightings = k
+	}
+}
+
+// WithAddresser makes every memory head address the memory with a, in place of DefaultAddresser.
+// The weights computed by a are read from and written to like those of DefaultAddresser,
+// and the location addressing units of the heads, such as G and S, are left to a to use or ignore.
+func WithAddresser(a Addresser) ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.addresser = a
+	}
+}
+
+// WithNaNCheck makes every memory operation scan the outputs of its components for NaN and infinite values,
+// panicking with a *NaNError naming the first offending component, see ForwardBackwardChecked.
+// Only the values let through by the ErrorPolicy are found, see ErrorPolicy.
+// This slows down training and is intended for debugging.
+func WithNaNCheck() ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.nanCheck = true
+	}
+}
+
+// An AddressingMode determines the addressing mechanisms used by a memory head.
+type AddressingMode int
+
+const (
+	// ContentAndLocation is the full addressing mechanism of the NTM paper,
+	// in which content addressing is followed by interpolation with the previous weights, shifting and sharpening.
+	ContentAndLocation AddressingMode = iota
+	// ContentOnly heads address the memory by content addressing alone, and do not have the G, S and Gamma units.
+	ContentOnly
+)
+
+// WithAddressingModes sets the addressing mode of each memory head.
+// Heads for which no mode is given use ContentAndLocation.
+func WithAddressingModes(modes ...AddressingMode) ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.modes = modes
+	}
+}
+
+// A SimilarityMode determines how the keys of memory heads are compared with memory rows in content addressing.
+type SimilarityMode int
+
+const (
+	// CosineSimilarity compares keys and memory rows by their cosine similarity, as in the NTM paper.
+	CosineSimilarity SimilarityMode = iota
+	// DotProductSimilarity compares keys and memory rows by their dot product, which is cheaper and never divides by a norm,
+	// but is not bounded in [-1, 1].
+	DotProductSimilarity
+)
+
+// WithSimilarityMode sets the similarity measure of the content addressing of every memory head.
+// The default is CosineSimilarity.
+func WithSimilarityMode(mode SimilarityMode) ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.similarity = mode
+	}
+}
+
+// An AddActivation is the activation function of the add vectors of memory heads.
+// Erase vectors always use Sigmoid, as they must lie in [0, 1].
+type AddActivation int
+
+const (
+	// SigmoidAdd add vectors lie in (0, 1).
+	SigmoidAdd AddActivation = iota
+	// TanhAdd add vectors lie in (-1, 1), which allows heads to decrease memory values by adding.
+	TanhAdd
+	// LinearAdd add vectors are unbounded, as in the NTM paper.
+	LinearAdd
+)
+
+// activate returns the add vector element of the head unit x.
+func (a AddActivation) activate(x float64) float64 {
+	switch a {
+	case TanhAdd:
+		return math.Tanh(x)
+	case LinearAdd:
+		return x
+	default:
+		return Sigmoid(x)
+	}
+}
+
+// backward returns the gradient with respect to the head unit, given the gradient grad of the activated value v.
+func (a AddActivation) backward(grad, v float64) float64 {
+	switch a {
+	case TanhAdd:
+		return grad * (1 - v*v)
+	case LinearAdd:
+		return grad
+	default:
+		return grad * v * (1 - v)
+	}
+}
+
+// WithAddActivation sets the activation function of the add vectors of every memory head.
+// The default is SigmoidAdd.
+func WithAddActivation(a AddActivation) ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.head.addActivation = a
+	}
+}
+
+// An OutputMode determines the activation function of the outputs of a controller, together with the loss of a NTM.
+type OutputMode int
+
+const (
+	// SigmoidOutput outputs are independent probabilities, whose loss is the binary cross-entropy.
+	SigmoidOutput OutputMode = iota
+	// SoftmaxOutput outputs are a probability distribution over classes, whose loss is the categorical cross-entropy.
+	SoftmaxOutput
+)
+
+// WithOutputTemperature adds a learned temperature to a controller, which divides the outputs before activation.
+// The temperature is exp(t) for a weight t enumerated last by Controller.Weights, and trained jointly with the other weights,
+// which amounts to Platt scaling learned end to end.
+// As the weights of an empty controller are zero, its temperature starts at 1, which leaves the predictions unchanged.
+func WithOutputTemperature() ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.temperature = true
+	}
+}
+
+// WithOutputMode sets the output mode of a controller.
+// The default is SigmoidOutput.
+// In both modes, the gradient of the loss with respect to the outputs before activation is the prediction minus the ground truth.
+func WithOutputMode(mode OutputMode) ControllerOption {
+	return func(cfg *controllerConfig) {
+		cfg.output = mode
+	}
+}
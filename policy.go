@@ -0,0 +1,100 @@
+package ntm
+
+import (
+	"fmt"
+	"math"
+)
+
+// An ErrorPolicy determines how the memory addressing components of every NTM handle degenerate states,
+// which are NaN and infinite values, and the zero-norm vectors for which the similarity of a key and a memory location is meaningless.
+// Every component applies the policy to its own outputs: the similarities, the content, gated, shifted and sharpened weightings,
+// the memory reads, and the written memory.
+//
+// The policy is independent of WithNaNCheck, which scans the outputs of the components of a controller for the values the policy lets through.
+// Under PanicOnDegenerate, WithNaNCheck thus reports the first NaN or infinite value with a *NaNError naming its head and unit.
+// Under ClampDegenerate and ErrorOnDegenerate, the components replace or report degenerate values before WithNaNCheck sees them.
+type ErrorPolicy int
+
+const (
+	// PanicOnDegenerate panics on NaN values in the cosine similarity, the shifted weighting and the memory read,
+	// and lets the other components propagate NaN and infinite values.
+	// The similarity of zero-norm vectors is kept at 0.
+	// This is how NTMs have always behaved, and is the default.
+	PanicOnDegenerate ErrorPolicy = iota
+	// ClampDegenerate never panics, replacing NaN values by 0 and infinite values by the largest finite float64 of the same sign.
+	// Shifted weights are also kept non-negative.
+	ClampDegenerate
+	// ErrorOnDegenerate panics with a *DegenerateError on NaN and infinite values, as well as on zero-norm vectors in the similarities,
+	// which ForwardBackwardChecked returns as its error.
+	ErrorOnDegenerate
+)
+
+// errorPolicy is the ErrorPolicy of the package.
+var errorPolicy = PanicOnDegenerate
+
+// SetErrorPolicy sets the ErrorPolicy of all NTMs, and returns the previous policy.
+// It is not safe to call SetErrorPolicy while a NTM is running.
+func SetErrorPolicy(p ErrorPolicy) ErrorPolicy {
+	prev := errorPolicy
+	errorPolicy = p
+	return prev
+}
+
+// A DegenerateError reports that a memory addressing component met a degenerate state under ErrorOnDegenerate.
+type DegenerateError struct {
+	Component string // the name of the component, such as "similarity" or "refocus"
+	Reason    string // a description of the degenerate state, such as "zero norm"
+	Inputs    string // a description of the inputs of the component
+}
+
+func (e *DegenerateError) Error() string {
+	return fmt.Sprintf("ntm: %s in %s, inputs: %s", e.Reason, e.Component, e.Inputs)
+}
+
+// degenerate handles the degenerate value v produced by a component according to the ErrorPolicy, returning the value to continue with.
+// Under PanicOnDegenerate, v is handled by legacy, which may panic.
+// inputs is only called on failure.
+func degenerate(component string, v float64, legacy func(), inputs func() string) float64 {
+	switch errorPolicy {
+	case ClampDegenerate:
+		if math.IsNaN(v) {
+			return 0
+		}
+		return math.Max(-math.MaxFloat64, math.Min(v, math.MaxFloat64))
+	case ErrorOnDegenerate:
+		panic(&DegenerateError{Component: component, Reason: fmt.Sprintf("%v value", v), Inputs: inputs()})
+	default:
+		legacy()
+		return v
+	}
+}
+
+// finite reports whether every unit is neither NaN nor infinite.
+func finite(units []Unit) bool {
+	for _, u := range units {
+		if math.IsNaN(u.Val) || math.IsInf(u.Val, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// isZero reports whether every unit is zero.
+func isZero(units []Unit) bool {
+	for _, u := range units {
+		if u.Val != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// degenerateUnits handles every NaN or infinite unit produced by a component according to the ErrorPolicy, see degenerate.
+// Under PanicOnDegenerate, the values are left as they are.
+func degenerateUnits(component string, units []Unit, inputs func() string) {
+	for i, u := range units {
+		if math.IsNaN(u.Val) || math.IsInf(u.Val, 0) {
+			units[i].Val = degenerate(component, u.Val, func() {}, inputs)
+		}
+	}
+}
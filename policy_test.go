@@ -0,0 +1,101 @@
+package ntm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fumin/ntm/copytask"
+)
+
+func TestErrorPolicy(t *testing.T) {
+	zero := []Unit{{}, {}, {}}
+	v := []Unit{{Val: 0.3}, {Val: -0.8}, {Val: 0.5}}
+	nan := []Unit{{Val: math.NaN()}, {Val: 0.2}, {Val: -0.4}}
+	// similarity returns the similarity of u and v, and the value ntm panicked with, if any.
+	similarity := func(p ErrorPolicy, u []Unit) (s float64, r interface{}) {
+		defer SetErrorPolicy(SetErrorPolicy(p))
+		defer func() { r = recover() }()
+		return newSimilarityCircuit(u, v).Top.Val, nil
+	}
+
+	if s, r := similarity(PanicOnDegenerate, zero); r != nil || s != 0 {
+		t.Errorf("PanicOnDegenerate: zero-norm similarity %f, panic %v", s, r)
+	}
+	if _, r := similarity(PanicOnDegenerate, nan); r == nil {
+		t.Errorf("PanicOnDegenerate: no panic on NaN")
+	}
+
+	if s, r := similarity(ClampDegenerate, zero); r != nil || s != 0 {
+		t.Errorf("ClampDegenerate: zero-norm similarity %f, panic %v", s, r)
+	}
+	if s, r := similarity(ClampDegenerate, nan); r != nil || s != 0 {
+		t.Errorf("ClampDegenerate: NaN similarity %f, panic %v", s, r)
+	}
+
+	_, r := similarity(ErrorOnDegenerate, zero)
+	if e, ok := r.(*DegenerateError); !ok || e.Component != "similarity" || e.Reason != "zero norm" {
+		t.Errorf("ErrorOnDegenerate: expected a *DegenerateError on the zero norm, got %v", r)
+	}
+	if _, r := similarity(ErrorOnDegenerate, nan); r == nil {
+		t.Errorf("ErrorOnDegenerate: no panic on NaN")
+	}
+
+	dot := func(p ErrorPolicy, u []Unit) (r interface{}) {
+		defer SetErrorPolicy(SetErrorPolicy(p))
+		defer func() { r = recover() }()
+		newDotSimilarity(u, v)
+		return nil
+	}
+	if r := dot(ClampDegenerate, zero); r != nil {
+		t.Errorf("ClampDegenerate: panic %v on a zero-norm dot similarity", r)
+	}
+	if e, ok := dot(ErrorOnDegenerate, zero).(*DegenerateError); !ok || e.Reason != "zero norm" {
+		t.Errorf("ErrorOnDegenerate: expected a *DegenerateError on the zero norm of the dot similarity, got %v", e)
+	}
+
+	if errorPolicy != PanicOnDegenerate {
+		t.Fatalf("policy %d not restored", errorPolicy)
+	}
+}
+
+func TestErrorPolicyRefocus(t *testing.T) {
+	// Sharpening zero shifted weights divides 0 by 0.
+	sw := &shiftedWeighting{Top: make([]Unit, 3)}
+	refocus := func(p ErrorPolicy) (w []Unit, r interface{}) {
+		defer SetErrorPolicy(SetErrorPolicy(p))
+		defer func() { r = recover() }()
+		return newRefocus(&Unit{Val: 0.5}, sw, MachineEpsilon, 0, false).Top, nil
+	}
+
+	w, r := refocus(PanicOnDegenerate)
+	if r != nil || !math.IsNaN(w[0].Val) {
+		t.Errorf("PanicOnDegenerate: weights %+v, panic %v, expected NaN weights as before", w, r)
+	}
+
+	w, r = refocus(ClampDegenerate)
+	if r != nil {
+		t.Errorf("ClampDegenerate: panic %v", r)
+	}
+	for i, u := range w {
+		if u.Val != 0 {
+			t.Errorf("ClampDegenerate: weight %d is %f, expected 0", i, u.Val)
+		}
+	}
+
+	_, r = refocus(ErrorOnDegenerate)
+	if e, ok := r.(*DegenerateError); !ok || e.Component != "refocus" {
+		t.Errorf("ErrorOnDegenerate: expected a *DegenerateError from refocus, got %v", r)
+	}
+}
+
+func TestErrorPolicyChecked(t *testing.T) {
+	defer SetErrorPolicy(SetErrorPolicy(ErrorOnDegenerate))
+	vectorSize := 2
+	// With all weights zero, the keys of the heads and the initial memory are zero vectors.
+	c := NewEmptyController1(vectorSize+2, vectorSize, 3, 1, 4, 3)
+	x, y := copytask.GenSeq(2, vectorSize)
+	_, err := ForwardBackwardChecked(c, x, y)
+	if e, ok := err.(*DegenerateError); !ok || e.Reason != "zero norm" {
+		t.Errorf("expected a zero norm *DegenerateError, got %v", err)
+	}
+}
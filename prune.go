@@ -0,0 +1,60 @@
+package ntm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// A pruner is a Controller whose individual weights can be pruned.
+type pruner interface {
+	// prunedWeights returns the set of the indices of the pruned weights in the order of Controller.Weights, which the caller may modify.
+	prunedWeights() map[int]bool
+}
+
+// Prune zeroes the smallest fraction of the internal weights of a controller in magnitude, rounded up,
+// and freezes them so that the optimizers of this package keep them at zero.
+// Weights pruned by earlier calls stay pruned.
+// It returns an error, pruning nothing, if fraction is not in [0, 1] or c does not support pruning.
+func Prune(c Controller, fraction float64) error {
+	if !(fraction >= 0 && fraction <= 1) {
+		return fmt.Errorf("ntm: pruning fraction must be in [0, 1], got %g", fraction)
+	}
+	p, ok := c.(pruner)
+	if !ok {
+		return fmt.Errorf("ntm: %T does not support pruning", c)
+	}
+	vals := Snapshot(c)
+	idx := make([]int, len(vals))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return math.Abs(vals[idx[i]]) < math.Abs(vals[idx[j]]) })
+	k := int(math.Ceil(fraction * float64(len(vals))))
+	if k > len(idx) {
+		k = len(idx)
+	}
+	pruned := p.prunedWeights()
+	for _, i := range idx[:k] {
+		pruned[i] = true
+	}
+	zeroPrunedWeights(c)
+	return nil
+}
+
+// zeroPrunedWeights zeroes the values and gradients of the pruned weights of a controller.
+func zeroPrunedWeights(c Controller) {
+	p, ok := c.(pruner)
+	if !ok || len(p.prunedWeights()) == 0 {
+		return
+	}
+	pruned := p.prunedWeights()
+	i := 0
+	c.Weights(func(u *Unit) {
+		if pruned[i] {
+			u.Val = 0
+			u.Grad = 0
+		}
+		i++
+	})
+}
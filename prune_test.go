@@ -0,0 +1,67 @@
+package ntm
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/fumin/ntm/copytask"
+)
+
+func TestPrune(t *testing.T) {
+	c := NewEmptyController1(6, 4, 5, 1, 6, 3)
+	rnd := rand.New(rand.NewSource(25))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x, y := copytask.GenSeq(3, 4)
+	// Train once before pruning, so that the momentum of the pruned weights is nonzero.
+	sgd := NewSGDMomentum(c)
+	sgd.Train(x, y, 1e-2, 0.9)
+
+	before := Snapshot(c)
+	for _, fraction := range []float64{-0.1, 1.5, math.NaN()} {
+		if err := Prune(c, fraction); err == nil {
+			t.Errorf("no error for fraction %g", fraction)
+		}
+	}
+	for i, v := range Snapshot(c) {
+		if v != before[i] {
+			t.Fatalf("invalid fraction pruned weight %d", i)
+		}
+	}
+	if err := Prune(c, 0.5); err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := int(math.Ceil(0.5 * float64(c.NumWeights())))
+	zeros := func() int {
+		n := 0
+		c.Weights(func(u *Unit) {
+			if u.Val == 0 {
+				n++
+			}
+		})
+		return n
+	}
+	if n := zeros(); n != expected {
+		t.Fatalf("%d weights pruned, expected %d", n, expected)
+	}
+	// Every surviving weight is at least as large as every pruned one.
+	var maxPruned, minKept float64 = 0, math.Inf(1)
+	i := 0
+	c.Weights(func(u *Unit) {
+		if u.Val == 0 {
+			maxPruned = math.Max(maxPruned, math.Abs(before[i]))
+		} else {
+			minKept = math.Min(minKept, math.Abs(u.Val))
+		}
+		i++
+	})
+	if maxPruned > minKept {
+		t.Errorf("pruned a weight of magnitude %f while keeping one of %f", maxPruned, minKept)
+	}
+
+	sgd.Train(x, y, 1e-2, 0.9)
+	NewRMSProp(c).Train(x, y, 0.95, 0.5, 1e-3, 1e-3)
+	if n := zeros(); n != expected {
+		t.Errorf("%d weights at zero after training, expected %d", n, expected)
+	}
+}
@@ -0,0 +1,39 @@
+package ntm
+
+import (
+	"math"
+)
+
+// L1Regularization returns a gradient transform that adds lambda*sign(w) to the gradient of every weight w,
+// which is the gradient of the penalty lambda*|w| and drives weights to exactly zero.
+// It is intended to be set as the GradTransform of an optimizer.
+func L1Regularization(lambda float64) func(*Unit) {
+	return func(u *Unit) {
+		switch {
+		case u.Val > 0:
+			u.Grad += lambda
+		case u.Val < 0:
+			u.Grad -= lambda
+		}
+	}
+}
+
+// L2Regularization returns a gradient transform that adds lambda*w to the gradient of every weight w,
+// which is the gradient of the penalty lambda/2*w^2 and shrinks weights in proportion to their magnitude.
+// It is intended to be set as the GradTransform of an optimizer.
+func L2Regularization(lambda float64) func(*Unit) {
+	return func(u *Unit) {
+		u.Grad += lambda * u.Val
+	}
+}
+
+// Sparsity returns the fraction of the internal weights of a controller whose magnitude is below threshold.
+func Sparsity(c Controller, threshold float64) float64 {
+	small := 0
+	c.Weights(func(u *Unit) {
+		if math.Abs(u.Val) < threshold {
+			small++
+		}
+	})
+	return float64(small) / float64(c.NumWeights())
+}
@@ -0,0 +1,69 @@
+package ntm
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/fumin/ntm/copytask"
+)
+
+func TestRegularization(t *testing.T) {
+	u := Unit{Val: -2, Grad: 1}
+	L1Regularization(0.5)(&u)
+	if u.Grad != 0.5 {
+		t.Errorf("L1 grad %f, expected 0.5", u.Grad)
+	}
+	u = Unit{}
+	L1Regularization(0.5)(&u)
+	if u.Grad != 0 {
+		t.Errorf("L1 grad of a zero weight %f, expected 0", u.Grad)
+	}
+	u = Unit{Val: -2, Grad: 1}
+	L2Regularization(0.5)(&u)
+	if u.Grad != 0 {
+		t.Errorf("L2 grad %f, expected 0", u.Grad)
+	}
+}
+
+func TestSparsity(t *testing.T) {
+	c := NewEmptyController1(3, 2, 4, 1, 5, 3)
+	i := 0
+	c.Weights(func(u *Unit) {
+		if i%4 == 0 {
+			u.Val = 1
+		}
+		i++
+	})
+	small := c.NumWeights() - (c.NumWeights()+3)/4
+	if s, expected := Sparsity(c, 0.5), float64(small)/float64(c.NumWeights()); s != expected {
+		t.Errorf("sparsity %f, expected %f", s, expected)
+	}
+}
+
+func TestL1Sparsity(t *testing.T) {
+	vectorSize := 3
+	rnd := rand.New(rand.NewSource(23))
+	seqs := make([][2][][]float64, 20)
+	for i := range seqs {
+		opts := copytask.DefaultGenSeqOpts()
+		opts.Rand = rnd
+		x, y := copytask.GenSeqWithOpts(2, vectorSize, opts)
+		seqs[i] = [2][][]float64{x, y}
+	}
+	// train returns the sparsity of a controller trained with the regularization reg of strength 0.1.
+	train := func(reg func(float64) func(*Unit)) float64 {
+		c := NewEmptyController1(vectorSize+2, vectorSize, 6, 1, 5, 3)
+		r := rand.New(rand.NewSource(24))
+		c.Weights(func(u *Unit) { u.Val = r.Float64() - 0.5 })
+		s := NewSGDMomentum(c)
+		s.GradTransform = reg(0.1)
+		for i := 0; i < 200; i++ {
+			seq := seqs[i%len(seqs)]
+			s.Train(seq[0], seq[1], 1e-2, 0.5)
+		}
+		return Sparsity(c, 1e-2)
+	}
+	if l1, l2 := train(L1Regularization), train(L2Regularization); l1 <= l2 {
+		t.Errorf("L1 sparsity %f is not above L2 sparsity %f", l1, l2)
+	}
+}
@@ -15,6 +15,37 @@ var (
 	}
 )
 
+// Task is the repeat copy task, in which the number of repetitions and the sequence lengths
+// are uniformly distributed in [1, MaxRepeat] and [1, MaxSeqLen] respectively.
+type Task struct {
+	GenFunc   string // a key of G
+	MaxRepeat int
+	MaxSeqLen int
+}
+
+func (t Task) GenSeq() ([][]float64, [][]float64) {
+	return G[t.GenFunc](rand.Intn(t.MaxRepeat)+1, rand.Intn(t.MaxSeqLen)+1)
+}
+
+func (t Task) InputSize() int {
+	x, _ := G[t.GenFunc](1, 1)
+	return len(x[0])
+}
+
+func (t Task) OutputSize() int {
+	_, y := G[t.GenFunc](1, 1)
+	return len(y[0])
+}
+
+func (t Task) Name() string {
+	return "repeatcopy"
+}
+
+// BaselineLoss returns 0, as the outputs are determined by the inputs seen so far.
+func (t Task) BaselineLoss() float64 {
+	return 0
+}
+
 // binary on time
 func GenSeqBT(repeat, seqlen int) ([][]float64, [][]float64) {
 	data := randData(seqlen)
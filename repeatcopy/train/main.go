@@ -5,7 +5,6 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"math"
 	"math/rand"
 	"net/http"
 	"os"
@@ -55,36 +54,34 @@ func main() {
 		}
 	}()
 
-	var seed int64 = 8
-	rand.Seed(seed)
+	cfg := ntm.DefaultTrainConfig()
+	cfg.NumHeads = 2
+	cfg.MaxSeqLen = 10
+	rand.Seed(cfg.Seed)
 
 	genFunc := "bt"
 	x, y := repeatcopy.G[genFunc](1, 1)
-	h1Size := 100
-	numHeads := 2
-	n := 128
-	m := 20
-	c := ntm.NewEmptyController1(len(x[0]), len(y[0]), h1Size, numHeads, n, m)
+	c := ntm.NewEmptyController1(len(x[0]), len(y[0]), cfg.H1Size, cfg.NumHeads, cfg.N, cfg.M)
 	c.Weights(func(u *ntm.Unit) { u.Val = 1 * (rand.Float64() - 0.5) })
 
 	losses := make([]float64, 0)
 	doPrint := false
 
 	rmsp := ntm.NewRMSProp(c)
-	log.Printf("genFunc: %s, seed: %d, numweights: %d, numHeads: %d", genFunc, seed, c.NumWeights(), c.NumHeads())
+	log.Printf("genFunc: %s, seed: %d, numweights: %d, numHeads: %d", genFunc, cfg.Seed, c.NumWeights(), c.NumHeads())
 	for i := 1; ; i++ {
-		x, y := repeatcopy.G[genFunc](rand.Intn(10)+1, rand.Intn(10)+1)
-		machines := rmsp.Train(x, y, 0.95, 0.5, 1e-3, 1e-3)
-		l := ntm.Loss(y, machines)
-		if i%1000 == 0 {
-			bpc := l / float64(len(y)*len(y[0]))
-			losses = append(losses, bpc)
-			log.Printf("%d, bpc: %f, seq length: %d", i, bpc, len(y))
+		seqLens := cfg.MaxSeqLen - cfg.MinSeqLen + 1
+		x, y := repeatcopy.G[genFunc](rand.Intn(seqLens)+cfg.MinSeqLen, rand.Intn(seqLens)+cfg.MinSeqLen)
+		machines := rmsp.Train(x, y, cfg.Decay, cfg.Momentum, cfg.LearningRate, cfg.Epsilon)
+		if i%cfg.ReportInterval == 0 {
+			bpb := ntm.BitsPerBit(y, machines)
+			losses = append(losses, bpb)
+			log.Printf("%d, bits-per-bit: %f, seq length: %d", i, bpb, len(y))
 		}
 
 		handleHTTP(c, losses, &doPrint)
 
-		if i%1000 == 0 && doPrint {
+		if i%cfg.ReportInterval == 0 && doPrint {
 			printDebug(y, machines)
 		}
 	}
@@ -114,15 +111,10 @@ func printDebug(y [][]float64, machines []*ntm.NTM) {
 
 	log.Printf("pred: %s", ntm.Sprint2(ntm.Predictions(machines)))
 
-	n := machines[0].Controller.MemoryN()
-	//outputT := len(machines) - (len(machines) - 2) / 2
-	outputT := 0
-	for t := outputT; t < len(machines); t++ {
-		h := machines[t].Controller.Heads()[0]
-		beta := math.Exp(h.Beta().Val)
-		g := ntm.Sigmoid(h.G().Val)
-		shift := math.Mod(2*ntm.Sigmoid(h.S().Val)-1+float64(n), float64(n))
-		gamma := math.Log(math.Exp(h.Gamma().Val)+1) + 1
-		log.Printf("beta: %.3g(%v), g: %.3g(%v), s: %.3g(%v), gamma: %.3g(%v), erase: %+v, add: %+v, k: %+v", beta, h.Beta(), g, h.G(), shift, h.S(), gamma, h.Gamma(), h.EraseVector(), h.AddVector(), h.K())
+	for _, r := range ntm.HeadParams(machines) {
+		if r.Head != 0 {
+			continue
+		}
+		log.Printf("%+v", r)
 	}
 }
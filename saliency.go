@@ -0,0 +1,27 @@
+package ntm
+
+import (
+	"fmt"
+)
+
+// An inputGrader is a Controller that can compute the gradients of the loss with respect to its input.
+type inputGrader interface {
+	// inputGrads returns the gradients with respect to X, and must be called after Backward.
+	inputGrads() []float64
+}
+
+// InputGradients returns the gradients of the loss with respect to each input x[t], which tell how much each input bit matters to the predictions of y.
+// The loss is the same as that of ForwardBackward, that is the cross-entropy in nats rather than in bits as reported by Loss.
+// Since InputGradients runs ForwardBackward, it also overwrites the gradients of the weights of c with those of the loss.
+// It panics if c does not support input gradients.
+func InputGradients(c Controller, x, y [][]float64) [][]float64 {
+	if _, ok := c.(inputGrader); !ok {
+		panic(fmt.Sprintf("ntm: %T does not support input gradients", c))
+	}
+	machines := ForwardBackward(c, x, y)
+	grads := make([][]float64, len(machines))
+	for t, m := range machines {
+		grads[t] = m.Controller.(inputGrader).inputGrads()
+	}
+	return grads
+}
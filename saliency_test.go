@@ -0,0 +1,41 @@
+package ntm
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestInputGradients(t *testing.T) {
+	xSize, ySize, numHeads, n, m := 3, 2, 2, 5, 3
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}, {1, 1, 0}}
+	y := [][]float64{{0, 1}, {1, 1}, {1, 0}}
+	controllers := []Controller{
+		NewEmptyController1(xSize, ySize, 4, numHeads, n, m),
+		NewEmptyController1Deep(xSize, ySize, []int{4, 3}, numHeads, n, m),
+	}
+	for _, c := range controllers {
+		rnd := rand.New(rand.NewSource(16))
+		c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+
+		grads := InputGradients(c, x, y)
+		if len(grads) != len(x) {
+			t.Fatalf("%T: %d time instants, expected %d", c, len(grads), len(x))
+		}
+		loss := func() float64 { return Loss(y, ForwardBackward(c, x, y)) * math.Ln2 }
+		for tt := range x {
+			for j, v := range x[tt] {
+				h := 1e-6
+				x[tt][j] = v + h
+				lph := loss()
+				x[tt][j] = v - h
+				lmh := loss()
+				x[tt][j] = v
+				grad := (lph - lmh) / (2 * h)
+				if math.IsNaN(grad) || math.Abs(grad-grads[tt][j]) > 1e-6 {
+					t.Errorf("%T: x[%d][%d] gradient expected %f, got %f", c, tt, j, grad, grads[tt][j])
+				}
+			}
+		}
+	}
+}
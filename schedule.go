@@ -0,0 +1,43 @@
+package ntm
+
+import (
+	"math"
+)
+
+// A Scheduler determines the learning rate at each training step.
+type Scheduler interface {
+	// LearningRate returns the learning rate at step, the first step being 0.
+	LearningRate(step int) float64
+}
+
+// CosineAnnealingWarmRestarts implements the learning rate schedule of
+// Loshchilov, I., & Hutter, F. (2016). SGDR: Stochastic gradient descent with warm restarts. arXiv preprint arXiv:1608.03983.
+// Within each cycle, the learning rate is annealed from MaxLR to MinLR along a cosine curve,
+// and is reset to MaxLR at the start of the next cycle.
+// The first cycle lasts T0 steps, and each cycle is TMult times longer than the previous one.
+type CosineAnnealingWarmRestarts struct {
+	MaxLR float64
+	MinLR float64
+	T0    int
+	TMult int // treated as 1 if less than 1
+}
+
+// Cycle returns the index of the cycle containing step, the position of step within the cycle, and the length of the cycle.
+func (s CosineAnnealingWarmRestarts) Cycle(step int) (cycle, pos, length int) {
+	mult := s.TMult
+	if mult < 1 {
+		mult = 1
+	}
+	pos, length = step, s.T0
+	for pos >= length {
+		pos -= length
+		length *= mult
+		cycle++
+	}
+	return cycle, pos, length
+}
+
+func (s CosineAnnealingWarmRestarts) LearningRate(step int) float64 {
+	_, pos, length := s.Cycle(step)
+	return s.MinLR + (s.MaxLR-s.MinLR)*(1+math.Cos(math.Pi*float64(pos)/float64(length)))/2
+}
@@ -0,0 +1,38 @@
+package ntm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCosineAnnealingWarmRestarts(t *testing.T) {
+	s := CosineAnnealingWarmRestarts{MaxLR: 1e-2, MinLR: 1e-4, T0: 10, TMult: 2}
+	var _ Scheduler = s
+
+	// The cycles start at steps 0, 10, 30 and 70, with lengths 10, 20, 40 and 80.
+	starts := []int{0, 10, 30, 70}
+	for i, start := range starts {
+		cycle, pos, length := s.Cycle(start)
+		if cycle != i || pos != 0 || length != 10<<uint(i) {
+			t.Errorf("step %d: cycle %d, pos %d, length %d", start, cycle, pos, length)
+		}
+		if lr := s.LearningRate(start); lr != s.MaxLR {
+			t.Errorf("learning rate %g at restart %d, expected %g", lr, start, s.MaxLR)
+		}
+		if start > 0 {
+			if lr := s.LearningRate(start - 1); lr >= s.LearningRate(start-2) || lr-s.MinLR > 1e-3 {
+				t.Errorf("learning rate %g before restart %d is not annealed", lr, start)
+			}
+		}
+	}
+
+	// Halfway through a cycle, the learning rate is the mean of MaxLR and MinLR.
+	if lr := s.LearningRate(20); math.Abs(lr-(s.MaxLR+s.MinLR)/2) > 1e-15 {
+		t.Errorf("learning rate %g halfway through the second cycle", lr)
+	}
+
+	constant := CosineAnnealingWarmRestarts{MaxLR: 1, MinLR: 0, T0: 4}
+	if _, pos, length := constant.Cycle(9); pos != 1 || length != 4 {
+		t.Errorf("pos %d, length %d, expected 1 and 4", pos, length)
+	}
+}
@@ -0,0 +1,205 @@
+package ntm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"sync"
+)
+
+// A Run is a sequence run through a controller, as displayed by ServeRun.
+type Run struct {
+	Title    string
+	Matrices []RunMatrix
+}
+
+// A RunMatrix is a named matrix of a Run, whose rows are time instants.
+// Values are expected to lie in [0, 1].
+type RunMatrix struct {
+	Name   string
+	Values [][]float64
+}
+
+// NewRun returns a Run displaying the input x, the output y, the predictions of machines, the weights of each memory head,
+// and the gradient norms entering the memory relative to their maximum, see MemoryGradNorms.
+func NewRun(title string, x, y [][]float64, machines []*NTM) Run {
+	r := Run{
+		Title: title,
+		Matrices: []RunMatrix{
+			{Name: "input", Values: x},
+			{Name: "output", Values: y},
+			{Name: "prediction", Values: Predictions(machines)},
+		},
+	}
+	for i, hw := range HeadWeights(machines) {
+		r.Matrices = append(r.Matrices, RunMatrix{Name: fmt.Sprintf("head %d weights", i), Values: hw})
+	}
+
+	norms := MemoryGradNorms(machines)
+	var max float64 = 0
+	for _, v := range norms {
+		max = math.Max(max, v)
+	}
+	grads := make([][]float64, len(norms))
+	for t, v := range norms {
+		if max > 0 {
+			v /= max
+		}
+		grads[t] = []float64{v}
+	}
+	r.Matrices = append(r.Matrices, RunMatrix{Name: "memory gradient norms", Values: grads})
+	return r
+}
+
+// RunHandler returns a handler that displays runs as heatmaps at "/", and serves them in JSON at "/runs.json".
+func RunHandler(runs []Run) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		page := struct {
+			Runs []Run
+		}{
+			Runs: runs,
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		runTmpl.Execute(w, page)
+	})
+	mux.HandleFunc("/runs.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(runs)
+	})
+	return mux
+}
+
+// maxPredictBody is the size in bytes of the largest request body accepted by PredictHandler.
+const maxPredictBody = 32 << 20
+
+// PredictHandler returns a handler that runs c on the input sequences POSTed to it in JSON, and responds with their predictions in JSON.
+// The request body is an array of sequences, each an array of input vectors, and the response holds the predictions of every sequence, see ForwardMany.
+// Empty sequences and sequences whose vectors do not match the input size of c are rejected, as are bodies larger than 32 MiB.
+// Requests are served one at a time, so that c may be copied safely.
+func PredictHandler(c Controller) http.Handler {
+	var mu sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var xs [][][]float64
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPredictBody)).Decode(&xs); err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		pdts, err := forwardManyChecked(&mu, c, xs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pdts)
+	})
+}
+
+// forwardManyChecked is similar to ForwardMany, except that it holds mu and returns the DimError ForwardMany panics with.
+// Since ForwardMany checks the sequences before starting its workers, no DimError is raised on a goroutine other than the caller's.
+func forwardManyChecked(mu *sync.Mutex, c Controller, xs [][][]float64) (pdts [][][]float64, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(DimError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	return ForwardMany(c, xs), nil
+}
+
+// ServeRun serves RunHandler(runs) on addr.
+// It blocks until the server fails, and always returns a non-nil error.
+func ServeRun(runs []Run, addr string) error {
+	return http.ListenAndServe(addr, RunHandler(runs))
+}
+
+var runTmpl = template.Must(template.New("").Parse(`
+<!DOCTYPE html>
+<html>
+<head>
+  <script type="text/javascript" src="https://cdnjs.cloudflare.com/ajax/libs/d3/3.5.5/d3.min.js"></script>
+</head>
+<body>
+<script type="text/javascript">
+var page = {{.}};
+
+var colorbrewer = {};
+colorbrewer.RdYlBu = {};
+colorbrewer.RdYlBu[9] = ["#d73027","#f46d43","#fdae61","#fee090","#ffffbf","#e0f3f8","#abd9e9","#74add1","#4575b4"];
+
+// palette draws a color palette explaining that 0.0 maps to blue and 1.0 maps to red.
+function palette(parent) {
+  var matrix = colorbrewer.RdYlBu[9].map(function(d, i) {
+    return [{"text": ""}, {"bgcolor": d}];
+  });
+  matrix[0][0].text = "1.0";
+  matrix[(colorbrewer.RdYlBu[9].length-1) / 2][0].text = "0.5";
+  matrix[colorbrewer.RdYlBu[9].length-1][0].text = "0.0";
+  var table = parent.append("table")
+  var tr = table.selectAll("tr").data(matrix).
+    enter().append("tr");
+  var td = tr.selectAll("td").data(function(d) { return d; }).
+    enter().append("td").
+    text(function(d) { return d.text; }).
+    style("background-color", function(d) { return d.bgcolor; }).
+    style("min-width", "1em").
+    style("height", "1em");
+  return table;
+}
+
+// imshow displays a 2 dimensional matrix.
+function imshow(parent, matrix) {
+  var table = parent.append("table");
+  var tr = table.selectAll("tr").data(matrix).
+    enter().append("tr");
+  var colormap = d3.scale.quantize().domain([0, 1]).range(colorbrewer.RdYlBu[9].slice().reverse());
+  var td = tr.selectAll("td").data(function(d) { return d; }).
+    enter().append("td").
+    style("background-color", colormap).
+    style("min-width", "1em").
+    style("height", "1em");
+  return table;
+}
+
+palette(d3.select("body"));
+
+var allRuns = d3.select("body").append("div").attr("id", "runs");
+var run = allRuns.selectAll("div").
+  data(page.Runs).
+  enter().append("div").
+  attr("id", function(d, i){ return "run-"+i;});
+
+run.append("h4").text(function(d){ return d.Title; });
+
+// Draw every matrix with time along the horizontal axis.
+var matrix = run.selectAll("div").
+  data(function(d){ return d.Matrices; }).
+  enter().append("div");
+matrix.append("h5").text(function(d){ return d.Name; });
+matrix.each(function(d){ imshow(d3.select(this), d3.transpose(d.Values)); });
+</script>
+<body>
+</html>
+`))
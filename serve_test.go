@@ -0,0 +1,128 @@
+package ntm
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunHandler(t *testing.T) {
+	c := NewEmptyController1(3, 2, 4, 2, 5, 3)
+	rnd := rand.New(rand.NewSource(10))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}}
+	y := [][]float64{{0, 1}, {1, 1}}
+	runs := []Run{NewRun("sample", x, y, ForwardBackward(c, x, y))}
+	srv := httptest.NewServer(RunHandler(runs))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status %d for the page", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/runs.json")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d for the runs", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("content type %q", ct)
+	}
+	var got []Run
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(got) != 1 || got[0].Title != "sample" {
+		t.Fatalf("runs %+v", got)
+	}
+	names := []string{"input", "output", "prediction", "head 0 weights", "head 1 weights", "memory gradient norms"}
+	if len(got[0].Matrices) != len(names) {
+		t.Fatalf("%d matrices, expected %d", len(got[0].Matrices), len(names))
+	}
+	for i, name := range names {
+		m := got[0].Matrices[i]
+		if m.Name != name || len(m.Values) != len(x) {
+			t.Errorf("[%d] matrix %q with %d rows, expected %q with %d rows", i, m.Name, len(m.Values), name, len(x))
+		}
+	}
+}
+
+func TestPredictHandler(t *testing.T) {
+	// Run the sequences on several workers, whose panics could not be recovered.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(2))
+	c := NewEmptyController1(3, 2, 4, 1, 5, 3)
+	rnd := rand.New(rand.NewSource(45))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	xs := [][][]float64{{{1, 0, 1}, {0, 1, 0}}, {{0, 0, 1}}}
+	expected := ForwardMany(c, xs)
+	srv := httptest.NewServer(PredictHandler(c))
+	defer srv.Close()
+
+	body, err := json.Marshal(xs)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	var pdts [][][]float64
+	if err := json.NewDecoder(resp.Body).Decode(&pdts); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(pdts) != len(expected) {
+		t.Fatalf("%d sequences, expected %d", len(pdts), len(expected))
+	}
+	for i := range expected {
+		for tt := range expected[i] {
+			for k := range expected[i][tt] {
+				if pdts[i][tt][k] != expected[i][tt][k] {
+					t.Errorf("prediction [%d][%d][%d] %f != %f", i, tt, k, pdts[i][tt][k], expected[i][tt][k])
+				}
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		method string
+		body   string
+		status int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "not json", http.StatusBadRequest},
+		{http.MethodPost, "[[[1, 0]]]", http.StatusBadRequest},
+		{http.MethodPost, "[[]]", http.StatusBadRequest},
+		{http.MethodPost, "[[], []]", http.StatusBadRequest},
+		{http.MethodPost, "[[[1, 0, 1]], []]", http.StatusBadRequest},
+		{http.MethodPost, "[" + strings.Repeat(" ", maxPredictBody) + "]", http.StatusRequestEntityTooLarge},
+	} {
+		req, err := http.NewRequest(tc.method, srv.URL, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %.20q: status %d, expected %d", tc.method, tc.body, resp.StatusCode, tc.status)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package ntm
+
+import (
+	"sync"
+)
+
+// Snapshot returns a copy of the values of all internal weights of a controller in the order of Controller.Weights.
+// Snapshot reads the weights without synchronization, and is therefore safe to call only when no training is in flight.
+// Use a WeightsStore to share weights with other goroutines.
+func Snapshot(c Controller) []float64 {
+	ws := make([]float64, 0, c.NumWeights())
+	c.Weights(func(u *Unit) { ws = append(ws, u.Val) })
+	return ws
+}
+
+// Gradients returns a copy of the gradients of all internal weights of a controller in the order of Controller.Weights.
+// Like Snapshot, it is safe to call only when no training is in flight.
+func Gradients(c Controller) []float64 {
+	grads := make([]float64, 0, c.NumWeights())
+	c.Weights(func(u *Unit) { grads = append(grads, u.Grad) })
+	return grads
+}
+
+// A WeightsStore holds a snapshot of the weights of a controller that is safe for concurrent use.
+// Typically, a training loop calls Update between training steps, while other goroutines such as HTTP handlers call Weights.
+type WeightsStore struct {
+	mu      sync.RWMutex
+	weights []float64
+}
+
+// Update replaces the stored weights with those of c.
+// It must be called from the goroutine that trains c.
+func (s *WeightsStore) Update(c Controller) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.weights) != c.NumWeights() {
+		s.weights = make([]float64, c.NumWeights())
+	}
+	i := 0
+	c.Weights(func(u *Unit) {
+		s.weights[i] = u.Val
+		i++
+	})
+}
+
+// Weights returns a copy of the stored weights.
+func (s *WeightsStore) Weights() []float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ws := make([]float64, len(s.weights))
+	copy(ws, s.weights)
+	return ws
+}
@@ -0,0 +1,102 @@
+package ntm
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	c := NewEmptyController1(3, 2, 4, 2, 5, 3)
+	rnd := rand.New(rand.NewSource(5))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() })
+
+	ws := Snapshot(c)
+	if len(ws) != c.NumWeights() {
+		t.Fatalf("%d weights, expected %d", len(ws), c.NumWeights())
+	}
+	i := 0
+	c.Weights(func(u *Unit) {
+		if ws[i] != u.Val {
+			t.Errorf("[%d] %f != %f", i, ws[i], u.Val)
+		}
+		u.Val++
+		i++
+	})
+	if ws[0] == Snapshot(c)[0] {
+		t.Errorf("snapshot is not a copy")
+	}
+}
+
+func TestGradients(t *testing.T) {
+	c := NewEmptyController1(3, 2, 4, 2, 5, 3)
+	rnd := rand.New(rand.NewSource(7))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}}
+	y := [][]float64{{0, 1}, {1, 1}}
+	ForwardBackward(c, x, y)
+
+	grads := Gradients(c)
+	if len(grads) != c.NumWeights() {
+		t.Fatalf("%d gradients, expected %d", len(grads), c.NumWeights())
+	}
+	i, nonzero := 0, 0
+	c.Weights(func(u *Unit) {
+		if grads[i] != u.Grad {
+			t.Errorf("[%d] %f != %f", i, grads[i], u.Grad)
+		}
+		if u.Grad != 0 {
+			nonzero++
+		}
+		i++
+	})
+	if nonzero == 0 {
+		t.Errorf("all gradients are zero")
+	}
+}
+
+// TestWeightsStoreConcurrent is intended to be run with the race detector enabled.
+func TestWeightsStoreConcurrent(t *testing.T) {
+	c := NewEmptyController1(3, 2, 4, 1, 5, 3)
+	rnd := rand.New(rand.NewSource(6))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	rmsp := NewRMSProp(c)
+
+	var store WeightsStore
+	store.Update(c)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if ws := store.Weights(); len(ws) != c.NumWeights() {
+					t.Errorf("%d weights, expected %d", len(ws), c.NumWeights())
+					return
+				}
+			}
+		}()
+	}
+
+	x := [][]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	y := [][]float64{{0, 1}, {1, 0}, {1, 1}}
+	for i := 0; i < 20; i++ {
+		rmsp.Train(x, y, 0.95, 0.5, 1e-3, 1e-3)
+		store.Update(c)
+	}
+	close(stop)
+	wg.Wait()
+
+	ws := store.Weights()
+	for i, w := range Snapshot(c) {
+		if ws[i] != w {
+			t.Fatalf("[%d] %f != %f", i, ws[i], w)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package ntm
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// A Task is a learning problem on which a NTM can be trained.
+type Task interface {
+	// GenSeq generates a random pair of input and output sequences.
+	GenSeq() (x, y [][]float64)
+	// InputSize returns the size of each input vector.
+	InputSize() int
+	// OutputSize returns the size of each output vector.
+	OutputSize() int
+	// Name returns the name of the task.
+	Name() string
+	// BaselineLoss returns the expected loss in bits per output bit, as reported by BitsPerBit, of an optimal predictor.
+	// It is 0 for tasks whose outputs are determined by the inputs seen so far,
+	// and the gap between it and the loss of a NTM tells how far the NTM is from optimal.
+	BaselineLoss() float64
+}
+
+// A randTask is a Task that can generate its sequences from a given source of randomness.
+type randTask interface {
+	// GenSeqRand is similar to GenSeq, except that the sequences are drawn from r.
+	GenSeqRand(r *rand.Rand) (x, y [][]float64)
+}
+
+// A maskedTask is a Task in which only some time instants of the output sequences are scored,
+// such as the echo phase of the delayed copy task.
+// RunTraining trains on and reports the loss of the scored time instants only.
+type maskedTask interface {
+	// Mask returns the time instants of the sequences x and y generated by the task that are scored, see LossMasked.
+	Mask(x, y [][]float64) []bool
+}
+
+// TrainConfig holds the settings of a training process.
+type TrainConfig struct {
+	// The architecture of the controller, see ControllerConfig.
+	H1Size   int
+	NumHeads int
+	N        int
+	M        int
+	Options  []ControllerOption
+
+	// Optimizer is the name of an optimizer registered with NewOptimizer, see OptimizerNames.
+	// Only the hyperparameters below which the optimizer takes are used.
+	Optimizer string
+	// Decay is the decay rate of the running averages of RMSProp, the parameter a of RMSProp.Train.
+	Decay float64
+	// Momentum is the momentum of both RMSProp and SGDMomentum.
+	Momentum     float64
+	LearningRate float64
+	// Schedule, if not nil, determines the learning rate at each step in place of LearningRate.
+	Schedule Scheduler
+	// Epsilon is the stabilizing constant of RMSProp and AdaGrad, the parameter d of RMSProp.Train.
+	Epsilon float64
+
+	Steps int
+	Seed  int64 // the seed for initializing the controller weights
+
+	// MinSeqLen and MaxSeqLen bound the lengths of the training sequences, for the training programs whose tasks take them.
+	// RunTraining leaves the lengths to the task, and zero values mean that the task decides.
+	MinSeqLen int
+	MaxSeqLen int
+
+	// ReportInterval is the number of steps between two calls to Report.
+	ReportInterval int
+	// Report is called with the step number and the bits-per-bit loss of the step every ReportInterval steps.
+	Report func(step int, bitsPerBit float64)
+}
+
+// DefaultTrainConfig returns the configuration with which the copy task is trained,
+// a RMSProp optimizer on a controller with a hidden layer of 100 units and a single head on a 128 by 20 memory,
+// for sequences of lengths in [1, 20].
+func DefaultTrainConfig() TrainConfig {
+	return TrainConfig{
+		H1Size:         100,
+		NumHeads:       1,
+		N:              128,
+		M:              20,
+		Optimizer:      "rmsprop",
+		Decay:          0.95,
+		Momentum:       0.5,
+		LearningRate:   1e-3,
+		Epsilon:        1e-3,
+		Steps:          100000,
+		Seed:           8,
+		MinSeqLen:      1,
+		MaxSeqLen:      20,
+		ReportInterval: 1000,
+	}
+}
+
+// Validate returns an error describing the first invalid setting of a TrainConfig.
+func (cfg TrainConfig) Validate() error {
+	sizes := []struct {
+		name string
+		val  int
+	}{
+		{"H1Size", cfg.H1Size},
+		{"NumHeads", cfg.NumHeads},
+		{"N", cfg.N},
+		{"M", cfg.M},
+	}
+	for _, f := range sizes {
+		if f.val <= 0 {
+			return fmt.Errorf("ntm: %s must be positive, got %d", f.name, f.val)
+		}
+	}
+	if err := newControllerConfig(cfg.Options).validate(cfg.N, cfg.M); err != nil {
+		return err
+	}
+	params, err := optimizerParams(cfg.Optimizer)
+	if err != nil {
+		return err
+	}
+	if cfg.Schedule == nil && !(cfg.LearningRate > 0) {
+		return fmt.Errorf("ntm: LearningRate must be positive, got %g", cfg.LearningRate)
+	}
+	if params["momentum"] && (cfg.Momentum < 0 || cfg.Momentum >= 1) {
+		return fmt.Errorf("ntm: Momentum must be in [0, 1), got %g", cfg.Momentum)
+	}
+	if params["decay"] && (cfg.Decay < 0 || cfg.Decay >= 1) {
+		return fmt.Errorf("ntm: Decay must be in [0, 1), got %g", cfg.Decay)
+	}
+	if params["epsilon"] && !(cfg.Epsilon > 0) {
+		return fmt.Errorf("ntm: Epsilon must be positive, got %g", cfg.Epsilon)
+	}
+	if cfg.Steps < 0 || cfg.ReportInterval < 0 {
+		return fmt.Errorf("ntm: Steps and ReportInterval must not be negative, got %d and %d", cfg.Steps, cfg.ReportInterval)
+	}
+	if cfg.MinSeqLen < 0 || cfg.MaxSeqLen < cfg.MinSeqLen {
+		return fmt.Errorf("ntm: invalid sequence length range [%d, %d]", cfg.MinSeqLen, cfg.MaxSeqLen)
+	}
+	return nil
+}
+
+// RunTraining trains a new controller on a task under the given configuration, and returns the trained controller.
+// It returns an error if the configuration is invalid, see TrainConfig.Validate.
+// Tasks which score only some time instants of their sequences, such as delaycopy.Task,
+// are trained on and report the loss of those time instants.
+func RunTraining(t Task, cfg TrainConfig) (Controller, error) {
+	return runTraining(t, cfg, t.GenSeq)
+}
+
+// runTraining is similar to RunTraining, except that the training sequences are generated by genSeq.
+func runTraining(t Task, cfg TrainConfig, genSeq func() (x, y [][]float64)) (Controller, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	c, err := NewController1(ControllerConfig{
+		XSize:           t.InputSize(),
+		YSize:           t.OutputSize(),
+		HiddenSize:      cfg.H1Size,
+		NumHeads:        cfg.NumHeads,
+		MemoryLocations: cfg.N,
+		MemoryWidth:     cfg.M,
+		Options:         cfg.Options,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rnd := rand.New(rand.NewSource(cfg.Seed))
+	c.Weights(func(u *Unit) { u.Val = 1 * (rnd.Float64() - 0.5) })
+
+	opt, params, err := newOptimizer(cfg.Optimizer, c)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range map[string]float64{"decay": cfg.Decay, "momentum": cfg.Momentum, "lr": cfg.LearningRate, "epsilon": cfg.Epsilon} {
+		if p, ok := params[k]; ok {
+			*p = v
+		}
+	}
+	mt, masked := t.(maskedTask)
+
+	for i := 1; i <= cfg.Steps; i++ {
+		x, y := genSeq()
+		if cfg.Schedule != nil {
+			*params["lr"] = cfg.Schedule.LearningRate(i - 1)
+		}
+		var mask []bool
+		if masked {
+			mask = mt.Mask(x, y)
+		}
+		machines := ForwardBackwardMasked(c, x, y, mask)
+		opt.Step(machines)
+		if cfg.Report != nil && cfg.ReportInterval > 0 && i%cfg.ReportInterval == 0 {
+			if masked {
+				cfg.Report(i, BitsPerBitMasked(y, machines, mask))
+			} else {
+				cfg.Report(i, BitsPerBit(y, machines))
+			}
+		}
+	}
+	return c, nil
+}
+
+// A SeedResult is the outcome of training a controller from a seed in SweepSeeds.
+type SeedResult struct {
+	Seed       int64
+	Loss       float64 // the bits-per-bit loss averaged over the last seedLossWindow training steps
+	Controller Controller
+	Err        error
+}
+
+// seedLossWindow is the number of final training steps over which the loss of a SeedResult is averaged.
+const seedLossWindow = 100
+
+// SweepSeeds trains a new controller for steps steps from each seed under cfg, and returns the results in the order of seeds.
+// The seeds are trained concurrently, each seed setting cfg.Seed and drawing its training sequences from its own source seeded by the seed,
+// for tasks that can generate sequences from a given source such as copytask.Task.
+// Other tasks draw from the default source of math/rand, in which case the results are not reproducible.
+// cfg.Steps, cfg.Report and cfg.ReportInterval are ignored, and cfg.Options must not share state between controllers, such as the source of WithDropout.
+func SweepSeeds(t Task, seeds []int64, cfg TrainConfig, steps int) []SeedResult {
+	results := make([]SeedResult, len(seeds))
+	var wg sync.WaitGroup
+	for i, seed := range seeds {
+		wg.Add(1)
+		go func(res *SeedResult, seed int64) {
+			defer wg.Done()
+			losses := NewLossTracker(seedLossWindow)
+			c := cfg
+			c.Seed = seed
+			c.Steps = steps
+			c.ReportInterval = 1
+			c.Report = func(step int, bitsPerBit float64) { losses.Add(bitsPerBit) }
+			genSeq := t.GenSeq
+			if rt, ok := t.(randTask); ok {
+				r := rand.New(rand.NewSource(seed))
+				genSeq = func() (x, y [][]float64) { return rt.GenSeqRand(r) }
+			}
+			res.Seed = seed
+			res.Controller, res.Err = runTraining(t, c, genSeq)
+			res.Loss = losses.MovingAverage(seedLossWindow)
+		}(&results[i], seed)
+	}
+	wg.Wait()
+	return results
+}
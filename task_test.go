@@ -0,0 +1,232 @@
+package ntm
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/fumin/ntm/copytask"
+	"github.com/fumin/ntm/copyuntil"
+	"github.com/fumin/ntm/delaycopy"
+	"github.com/fumin/ntm/ngram"
+	"github.com/fumin/ntm/repeatcopy"
+)
+
+var (
+	_ Task = copytask.Task{}
+	_ Task = repeatcopy.Task{}
+	_ Task = ngram.Task{}
+	_ Task = delaycopy.Task{}
+	_ Task = copyuntil.Task{}
+)
+
+func TestBaselineLoss(t *testing.T) {
+	if l := (copytask.Task{}).BaselineLoss(); l != 0 {
+		t.Errorf("copy task baseline loss %f, expected 0", l)
+	}
+
+	// Estimate the expected entropy of the random outputs of the n-gram task.
+	r := rand.New(rand.NewSource(31))
+	var h float64 = 0
+	samples := 0
+	for i := 0; i < 200; i++ {
+		for _, p := range ngram.GenProbRand(r) {
+			if p > 0 && p < 1 {
+				h -= p*math.Log2(p) + (1-p)*math.Log2(1-p)
+			}
+			samples++
+		}
+	}
+	h /= float64(samples)
+	// The first n-1 = 4 outputs are always 0.
+	_, y := ngram.GenSeq(ngram.GenProbRand(r))
+	expected := h * float64(len(y)-4) / float64(len(y))
+	if l := (ngram.Task{}).BaselineLoss(); math.Abs(l-expected) > 0.01 {
+		t.Errorf("ngram baseline loss %f, expected %f", l, expected)
+	}
+}
+
+func TestMeanSquaredError(t *testing.T) {
+	machines := []*NTM{
+		{Controller: &controller1{controllerCore: controllerCore{y: []Unit{{Val: 0.5}, {Val: 1}}}}},
+		{Controller: &controller1{controllerCore: controllerCore{y: []Unit{{Val: 0}, {Val: 0.25}}}}},
+	}
+	y := [][]float64{{1, 1}, {0, 0.75}}
+	if mse := MeanSquaredError(y, machines); mse != (0.25+0.25)/4 {
+		t.Errorf("mse %f, expected %f", mse, (0.25+0.25)/4)
+	}
+	if mse := MeanSquaredError(nil, nil); mse != 0 {
+		t.Errorf("mse %f without outputs, expected 0", mse)
+	}
+	if mse := MeanSquaredError([][]float64{{}, {}}, machines); mse != 0 {
+		t.Errorf("mse %f for empty outputs, expected 0", mse)
+	}
+}
+
+func TestRunTraining(t *testing.T) {
+	task := copytask.Task{VectorSize: 4, MaxSeqLen: 3}
+	losses := make([]float64, 0)
+	cfg := TrainConfig{
+		H1Size:         20,
+		NumHeads:       1,
+		N:              8,
+		M:              4,
+		Optimizer:      "rmsprop",
+		Decay:          0.95,
+		Momentum:       0.5,
+		LearningRate:   1e-2,
+		Epsilon:        1e-3,
+		Steps:          400,
+		Seed:           3,
+		ReportInterval: 1,
+		Report:         func(step int, bpb float64) { losses = append(losses, bpb) },
+	}
+	c, err := RunTraining(task, cfg)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if c.NumHeads() != cfg.NumHeads || c.MemoryN() != cfg.N || c.MemoryM() != cfg.M {
+		t.Errorf("wrong architecture %d %d %d", c.NumHeads(), c.MemoryN(), c.MemoryM())
+	}
+	if len(losses) != cfg.Steps {
+		t.Fatalf("%d reports, expected %d", len(losses), cfg.Steps)
+	}
+	first := mean(losses[:100])
+	last := mean(losses[len(losses)-100:])
+	if last >= first {
+		t.Errorf("loss did not decrease, first: %f, last: %f", first, last)
+	}
+
+	cfg.Optimizer = "adam"
+	if _, err := RunTraining(task, cfg); err == nil {
+		t.Errorf("expected error for unknown optimizer")
+	}
+}
+
+func TestRunTrainingTiedEraseAdd(t *testing.T) {
+	losses := make([]float64, 0)
+	cfg := TrainConfig{
+		H1Size:         20,
+		NumHeads:       1,
+		N:              8,
+		M:              4,
+		Options:        []ControllerOption{WithTiedEraseAdd()},
+		Optimizer:      "rmsprop",
+		Decay:          0.95,
+		Momentum:       0.5,
+		LearningRate:   1e-2,
+		Epsilon:        1e-3,
+		Steps:          400,
+		Seed:           3,
+		ReportInterval: 1,
+		Report:         func(step int, bpb float64) { losses = append(losses, bpb) },
+	}
+	if _, err := RunTraining(copytask.Task{VectorSize: 4, MaxSeqLen: 3}, cfg); err != nil {
+		t.Fatalf("%v", err)
+	}
+	first := mean(losses[:100])
+	last := mean(losses[len(losses)-100:])
+	if last >= first {
+		t.Errorf("loss did not decrease, first: %f, last: %f", first, last)
+	}
+}
+
+func TestTrainConfigValidate(t *testing.T) {
+	cfg := DefaultTrainConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+	// The defaults are those of copytask/train.
+	expected := TrainConfig{
+		H1Size: 100, NumHeads: 1, N: 128, M: 20,
+		Optimizer: "rmsprop", Decay: 0.95, Momentum: 0.5, LearningRate: 1e-3, Epsilon: 1e-3,
+		Steps: 100000, Seed: 8, MinSeqLen: 1, MaxSeqLen: 20, ReportInterval: 1000,
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("defaults %+v, expected %+v", cfg, expected)
+	}
+
+	invalid := []func(*TrainConfig){
+		func(c *TrainConfig) { c.LearningRate = -1e-3 },
+		func(c *TrainConfig) { c.H1Size = 0 },
+		func(c *TrainConfig) { c.Optimizer = "adam" },
+		func(c *TrainConfig) { c.Momentum = 1 },
+		func(c *TrainConfig) { c.Decay = -0.1 },
+		func(c *TrainConfig) { c.MaxSeqLen = 0 },
+		func(c *TrainConfig) { c.Options = []ControllerOption{WithMaxShift(c.N)} },
+	}
+	for i, f := range invalid {
+		c := DefaultTrainConfig()
+		f(&c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("[%d] no error for %+v", i, c)
+		}
+	}
+	cfg.LearningRate = -1e-3
+	if _, err := RunTraining(copytask.Task{VectorSize: 4, MaxSeqLen: 3}, cfg); err == nil {
+		t.Errorf("RunTraining accepted a negative learning rate")
+	}
+	cfg.LearningRate = 1e-3
+	cfg.N = 4
+	cfg.Options = []ControllerOption{WithReadOnlyLocation(4)}
+	if _, err := RunTraining(copytask.Task{VectorSize: 4, MaxSeqLen: 3}, cfg); err == nil {
+		t.Errorf("RunTraining accepted a read-only location outside the memory")
+	}
+
+	// Every optimizer registered with NewOptimizer can be trained, and the hyperparameters it does not take are not validated.
+	for _, name := range OptimizerNames() {
+		c := DefaultTrainConfig()
+		c.H1Size, c.N, c.M, c.Steps = 4, 4, 2, 2
+		c.Optimizer = name
+		if name == "adagrad" {
+			c.Momentum, c.Decay = 1, 1
+		}
+		if _, err := RunTraining(copytask.Task{VectorSize: 2, MaxSeqLen: 2}, c); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestSweepSeeds(t *testing.T) {
+	task := copytask.Task{VectorSize: 3, MaxSeqLen: 3}
+	cfg := DefaultTrainConfig()
+	cfg.H1Size, cfg.N, cfg.M = 8, 6, 3
+	seeds := []int64{4, 5}
+	steps := 30
+	results := SweepSeeds(task, seeds, cfg, steps)
+	again := SweepSeeds(task, seeds, cfg, steps)
+	for i, res := range results {
+		if res.Err != nil {
+			t.Fatalf("seed %d: %v", res.Seed, res.Err)
+		}
+		if res.Seed != seeds[i] {
+			t.Errorf("[%d] seed %d, expected %d", i, res.Seed, seeds[i])
+		}
+		if math.IsNaN(res.Loss) || res.Loss != again[i].Loss {
+			t.Errorf("seed %d: loss %f is not reproducible, got %f", res.Seed, res.Loss, again[i].Loss)
+		}
+		ws, wsAgain := Snapshot(res.Controller), Snapshot(again[i].Controller)
+		for j := range ws {
+			if ws[j] != wsAgain[j] {
+				t.Fatalf("seed %d: weight %d is not reproducible: %f != %f", res.Seed, j, ws[j], wsAgain[j])
+			}
+		}
+	}
+	if results[0].Loss == results[1].Loss {
+		t.Errorf("seeds %d and %d have the same loss %f", seeds[0], seeds[1], results[0].Loss)
+	}
+
+	cfg.LearningRate = -1
+	if res := SweepSeeds(task, seeds[:1], cfg, steps); res[0].Err == nil {
+		t.Errorf("no error for an invalid config")
+	}
+}
+
+func mean(xs []float64) float64 {
+	s := 0.0
+	for _, x := range xs {
+		s += x
+	}
+	return s / float64(len(xs))
+}
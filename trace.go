@@ -0,0 +1,136 @@
+package ntm
+
+import (
+	"math"
+)
+
+// A RunTrace records the full state of a NTM across time.
+// It contains only exported fields of basic types, and thus can be encoded by both encoding/json and encoding/gob.
+type RunTrace struct {
+	X           [][]float64   // the inputs at every time instant
+	Predictions [][]float64   // the predictions at every time instant
+	HeadWeights [][][]float64 // the addressing weights of each memory head across time, see HeadWeights
+	Memory      [][][]float64 // the memory contents after the write operations at every time instant
+	Heads       [][]HeadTrace // the memory heads at every time instant
+}
+
+// A HeadTrace records the values emitted by a controller for a memory head.
+// All values are those before their respective activation functions.
+type HeadTrace struct {
+	Erase       []float64
+	Add         []float64
+	K           []float64
+	Beta        float64
+	G           float64   // zero if the head does only content addressing
+	S           float64   // zero if the head does only content addressing or emits shift logits
+	ShiftLogits []float64 // nil unless the head emits shift logits
+	Gamma       float64   // zero if the head does only content addressing
+	WriteGate   float64   // zero if the head has no write gate
+}
+
+// Trace returns the trace of a NTM that was run on the inputs x.
+func Trace(x [][]float64, machines []*NTM) RunTrace {
+	tr := RunTrace{
+		X:           x,
+		Predictions: Predictions(machines),
+		HeadWeights: HeadWeights(machines),
+		Memory:      make([][][]float64, len(machines)),
+		Heads:       make([][]HeadTrace, len(machines)),
+	}
+	for t, m := range machines {
+		tr.Memory[t] = m.memOp.WrittenMemoryVals()
+		heads := m.Controller.Heads()
+		tr.Heads[t] = make([]HeadTrace, len(heads))
+		for i, h := range heads {
+			ht := HeadTrace{
+				Erase: unitVals(h.EraseVector()),
+				Add:   unitVals(h.AddVector()),
+				K:     unitVals(h.K()),
+				Beta:  h.Beta().Val,
+			}
+			if h.cfg.mode != ContentOnly {
+				ht.G = h.G().Val
+				if h.cfg.shiftLogits {
+					ht.ShiftLogits = unitVals(h.ShiftLogits())
+				} else {
+					ht.S = h.S().Val
+				}
+				ht.Gamma = h.Gamma().Val
+			}
+			if g := h.WriteGate(); g != nil {
+				ht.WriteGate = g.Val
+			}
+			tr.Heads[t][i] = ht
+		}
+	}
+	return tr
+}
+
+// A HeadParamRecord holds the parameters of a memory head at a time instant after their respective activation functions,
+// together with the raw erase, add and key vectors, the addressing weights over the memory locations, and the vector read from the memory.
+// Like RunTrace, it contains only exported fields of basic types.
+type HeadParamRecord struct {
+	T    int // the time instant
+	Head int // the index of the head
+
+	Beta float64 // exp(beta), clamped by WithBetaClamp
+	G    float64 // sigmoid(g), zero if the head does only content addressing
+	// Shift is the shift (2*sigmoid(s)-1)*r modulo the memory size N, where r is the shift range of the head.
+	// It is zero if the head does only content addressing or emits shift logits.
+	Shift float64
+	// ShiftProbs is the softmax of the shift logits, see Head.ShiftLogits.
+	// It is nil unless the head emits shift logits.
+	ShiftProbs []float64
+	Gamma      float64 // softplus(gamma)+1 clamped by WithGammaClamp, zero if the head does only content addressing
+	WriteGate  float64 // sigmoid of the write gate, 1 if the head has no write gate
+
+	Erase []float64
+	Add   []float64
+	K     []float64
+
+	// ContentWeights are the content addressing weights of every memory location.
+	// They are nil for heads addressed by an Addresser other than DefaultAddresser.
+	ContentWeights []float64
+	Weights        []float64 // the addressing weights of every memory location, see HeadWeights
+	Read           []float64 // the vector read from the memory, which concatenates the reads of every weighting, see WithReadWeightings
+}
+
+// HeadParams returns the parameters of every memory head at every time instant, ordered by time and then by head.
+func HeadParams(machines []*NTM) []HeadParamRecord {
+	records := make([]HeadParamRecord, 0)
+	for t, m := range machines {
+		for i, h := range m.Controller.Heads() {
+			r := HeadParamRecord{
+				T:         t,
+				Head:      i,
+				WriteGate: 1,
+				Erase:     unitVals(h.EraseVector()),
+				Add:       unitVals(h.AddVector()),
+				K:         unitVals(h.K()),
+				Weights:   unitVals(m.memOp.W[i].Top),
+				Read:      unitVals(m.memOp.R[i].Top),
+			}
+			if a, ok := m.memOp.A[i].(*defaultAddressing); ok {
+				r.ContentWeights = unitVals(a.WC.Top)
+			}
+			r.Beta, _ = clamp(math.Exp(h.Beta().Val), h.cfg.maxBeta)
+			if h.cfg.mode != ContentOnly {
+				r.G = Sigmoid(h.G().Val)
+			}
+			// Heads with an Addresser other than DefaultAddresser have no shifted weights.
+			if rf := m.memOp.W[i]; rf.SW != nil {
+				if rf.SW.Logits != nil {
+					r.ShiftProbs = append([]float64{}, rf.SW.p...)
+				} else {
+					r.Shift = rf.SW.Z
+				}
+				r.Gamma = rf.g
+			}
+			if g := h.WriteGate(); g != nil {
+				r.WriteGate = Sigmoid(g.Val)
+			}
+			records = append(records, r)
+		}
+	}
+	return records
+}
@@ -0,0 +1,145 @@
+package ntm
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestTraceRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	times := 4
+	x := MakeTensor2(times, 3)
+	y := MakeTensor2(times, 2)
+	for i := range x {
+		for j := range x[i] {
+			x[i][j] = float64(rnd.Intn(2))
+		}
+		for j := range y[i] {
+			y[i][j] = float64(rnd.Intn(2))
+		}
+	}
+	c := NewEmptyController1(len(x[0]), len(y[0]), 3, 2, 5, 2)
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	machines := ForwardBackward(c, x, y)
+	tr := Trace(x, machines)
+
+	if len(tr.Memory) != times || len(tr.Memory[0]) != c.MemoryN() || len(tr.Memory[0][0]) != c.MemoryM() {
+		t.Fatalf("wrong memory dimensions %d %d %d", len(tr.Memory), len(tr.Memory[0]), len(tr.Memory[0][0]))
+	}
+	if len(tr.Heads) != times || len(tr.Heads[0]) != c.NumHeads() {
+		t.Fatalf("wrong heads dimensions %d %d", len(tr.Heads), len(tr.Heads[0]))
+	}
+
+	var jsonBuf bytes.Buffer
+	if err := json.NewEncoder(&jsonBuf).Encode(tr); err != nil {
+		t.Fatalf("%v", err)
+	}
+	var jsonTr RunTrace
+	if err := json.NewDecoder(&jsonBuf).Decode(&jsonTr); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(tr, jsonTr) {
+		t.Errorf("JSON round trip differs: %+v != %+v", tr, jsonTr)
+	}
+
+	var gobBuf bytes.Buffer
+	if err := gob.NewEncoder(&gobBuf).Encode(tr); err != nil {
+		t.Fatalf("%v", err)
+	}
+	var gobTr RunTrace
+	if err := gob.NewDecoder(&gobBuf).Decode(&gobTr); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(tr, gobTr) {
+		t.Errorf("gob round trip differs: %+v != %+v", tr, gobTr)
+	}
+}
+
+func TestHeadParams(t *testing.T) {
+	n, m := 5, 2
+	c := NewEmptyController1(3, 2, 4, 3, n, m, WithWriteGate(), WithMaxShift(2), WithAddressingModes(ContentAndLocation, ContentOnly))
+	rnd := rand.New(rand.NewSource(16))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}}
+	machines := ForwardBackward(c, x, [][]float64{{0, 1}, {1, 0}})
+
+	records := HeadParams(machines)
+	if len(records) != len(machines)*c.NumHeads() {
+		t.Fatalf("%d records, expected %d", len(records), len(machines)*c.NumHeads())
+	}
+	for _, r := range records {
+		h := machines[r.T].Controller.Heads()[r.Head]
+		if r.Beta != math.Exp(h.Beta().Val) {
+			t.Errorf("beta %f, expected exp(%f)", r.Beta, h.Beta().Val)
+		}
+		if r.WriteGate != Sigmoid(h.WriteGate().Val) {
+			t.Errorf("write gate %f, expected sigmoid(%f)", r.WriteGate, h.WriteGate().Val)
+		}
+		if r.Head == 1 {
+			if r.G != 0 || r.Shift != 0 || r.Gamma != 0 {
+				t.Errorf("content only head has location parameters %+v", r)
+			}
+			continue
+		}
+		if r.G != Sigmoid(h.G().Val) {
+			t.Errorf("g %f, expected sigmoid(%f)", r.G, h.G().Val)
+		}
+		shift := math.Mod((2*Sigmoid(h.S().Val)-1)*2+float64(n), float64(n))
+		if math.Abs(r.Shift-shift) > 1e-12 {
+			t.Errorf("shift %f, expected %f", r.Shift, shift)
+		}
+		if gamma := math.Log(math.Exp(h.Gamma().Val)+1) + 1; math.Abs(r.Gamma-gamma) > 1e-12 {
+			t.Errorf("gamma %f, expected %f", r.Gamma, gamma)
+		}
+		for j, k := range h.K() {
+			if r.K[j] != k.Val {
+				t.Errorf("k[%d] %f, expected %f", j, r.K[j], k.Val)
+			}
+		}
+	}
+}
+
+func TestHeadParamsJSON(t *testing.T) {
+	n, m := 5, 2
+	c := NewEmptyController1(3, 2, 4, 2, n, m, WithWriteGate(), WithAddressingModes(ContentAndLocation, ContentOnly))
+	rnd := rand.New(rand.NewSource(47))
+	c.Weights(func(u *Unit) { u.Val = rnd.Float64() - 0.5 })
+	x := [][]float64{{1, 0, 1}, {0, 1, 0}}
+	machines := ForwardBackward(c, x, [][]float64{{0, 1}, {1, 0}})
+	records := HeadParams(machines)
+
+	hws := HeadWeights(machines)
+	reads := ReadVectors(machines)
+	for _, r := range records {
+		if len(r.Erase) != m || len(r.Add) != m || len(r.K) != m || len(r.ContentWeights) != n {
+			t.Fatalf("incomplete record %+v", r)
+		}
+		if !reflect.DeepEqual(r.Weights, hws[r.Head][r.T]) {
+			t.Errorf("time %d, head %d: weights %v, expected %v", r.T, r.Head, r.Weights, hws[r.Head][r.T])
+		}
+		if !reflect.DeepEqual(r.Read, reads[r.T][r.Head]) {
+			t.Errorf("time %d, head %d: read %v, expected %v", r.T, r.Head, r.Read, reads[r.T][r.Head])
+		}
+		// The weights of a content only head are its content addressing weights.
+		if r.Head == 1 && !reflect.DeepEqual(r.ContentWeights, r.Weights) {
+			t.Errorf("time %d: content weights %v differ from the weights %v of a content only head", r.T, r.ContentWeights, r.Weights)
+		}
+	}
+
+	b, err := json.Marshal(records)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var got []HeadParamRecord
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(got, records) {
+		t.Errorf("JSON round trip changed the records from %+v to %+v", records, got)
+	}
+}
@@ -0,0 +1,93 @@
+// Command train trains a NTM on one of the registered tasks.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fumin/ntm"
+	"github.com/fumin/ntm/copytask"
+	"github.com/fumin/ntm/copyuntil"
+	"github.com/fumin/ntm/delaycopy"
+	"github.com/fumin/ntm/ngram"
+	"github.com/fumin/ntm/repeatcopy"
+)
+
+var (
+	tasks = map[string]ntm.Task{
+		"copy":       copytask.Task{VectorSize: 8, MaxSeqLen: 20},
+		"repeatcopy": repeatcopy.Task{GenFunc: "bt", MaxRepeat: 10, MaxSeqLen: 10},
+		"ngram":      ngram.Task{},
+		"delaycopy":  delaycopy.Task{VectorSize: 8, MaxLength: 20, Delay: 5},
+		"copyuntil":  copyuntil.Task{VectorSize: 8, MaxSeqLen: 10, MaxCopySteps: 30},
+	}
+
+	defaults = ntm.DefaultTrainConfig()
+
+	task        = flag.String("task", "copy", "the task to train on, one of "+taskNames())
+	steps       = flag.Int("steps", defaults.Steps, "number of training steps")
+	seed        = flag.Int64("seed", defaults.Seed, "random seed")
+	h1Size      = flag.Int("h1Size", defaults.H1Size, "size of the hidden layer of the controller")
+	numHeads    = flag.Int("numHeads", defaults.NumHeads, "number of heads")
+	memoryN     = flag.Int("n", defaults.N, "number of memory locations")
+	memoryM     = flag.Int("m", defaults.M, "size of each memory location")
+	optimizer   = flag.String("optimizer", defaults.Optimizer, "the optimizer, one of "+strings.Join(ntm.OptimizerNames(), ", "))
+	weightsFile = flag.String("weightsFile", "", "write the trained weights to file")
+)
+
+func main() {
+	flag.Parse()
+	t, ok := tasks[*task]
+	if !ok {
+		log.Fatalf("unknown task %q, available tasks are %s", *task, taskNames())
+	}
+
+	rand.Seed(*seed)
+	log.Printf("task: %s, seed: %d", t.Name(), *seed)
+	cfg := defaults
+	cfg.H1Size = *h1Size
+	cfg.NumHeads = *numHeads
+	cfg.N = *memoryN
+	cfg.M = *memoryM
+	cfg.Optimizer = *optimizer
+	cfg.Steps = *steps
+	cfg.Seed = *seed
+	cfg.Report = func(step int, bpb float64) {
+		log.Printf("%d, bits-per-bit: %f", step, bpb)
+	}
+	if cfg.Optimizer == "sgdmomentum" {
+		cfg.LearningRate = 1e-4
+		cfg.Momentum = 0.9
+	}
+	c, err := ntm.RunTraining(t, cfg)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if *weightsFile != "" {
+		ws := make([]float64, 0, c.NumWeights())
+		c.Weights(func(u *ntm.Unit) { ws = append(ws, u.Val) })
+		f, err := os.Create(*weightsFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer f.Close()
+		if err := json.NewEncoder(f).Encode(ws); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+}
+
+func taskNames() string {
+	names := make([]string, 0, len(tasks))
+	for name := range tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -22,6 +22,17 @@ func makeTensorUnit2(n, m int) [][]Unit {
 	return t
 }
 
+// makeFlatTensorUnit2 makes a n by m tensor whose rows are views into a single contiguous backing array.
+// Keeping the units contiguous improves cache locality in loops that sweep over the whole tensor.
+func makeFlatTensorUnit2(n, m int) ([]Unit, [][]Unit) {
+	data := make([]Unit, n*m)
+	t := make([][]Unit, n)
+	for i := 0; i < len(t); i++ {
+		t[i] = data[i*m : (i+1)*m : (i+1)*m]
+	}
+	return data, t
+}
+
 func makeTensorUnit3(n, m, p int) [][][]Unit {
 	t := make([][][]Unit, n)
 	for i := 0; i < len(t); i++ {
@@ -38,6 +49,14 @@ func unitVals(units []Unit) []float64 {
 	return v
 }
 
+func unitGrads(units []Unit) []float64 {
+	g := make([]float64, 0, len(units))
+	for _, u := range units {
+		g = append(g, u.Grad)
+	}
+	return g
+}
+
 func doUnit1(t []Unit, f func([]int, *Unit)) {
 	for i := 0; i < len(t); i++ {
 		f([]int{i}, &t[i])
@@ -0,0 +1,98 @@
+package ntm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// weightsMagic begins every file written by SaveWeightsBinary.
+const weightsMagic = "NTMW"
+
+// weightsVersion is the version of the format written by SaveWeightsBinary.
+const weightsVersion uint16 = 1
+
+// A ChecksumError reports that the weights read by LoadWeightsBinary do not match their CRC-32 checksum.
+type ChecksumError struct {
+	Expected uint32
+	Got      uint32
+}
+
+func (e ChecksumError) Error() string {
+	return fmt.Sprintf("ntm: weights checksum %08x, expected %08x", e.Got, e.Expected)
+}
+
+// SaveWeightsBinary writes the internal weights of a controller in the order of Controller.Weights in a compact binary format.
+// The format is the magic string "NTMW", a uint16 version, a uint64 number of weights,
+// the weights as float64s, and the IEEE CRC-32 checksum of the weights, all in little endian.
+func SaveWeightsBinary(c Controller, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := io.WriteString(bw, weightsMagic); err != nil {
+		return err
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint16(buf[:2], weightsVersion)
+	if _, err := bw.Write(buf[:2]); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint64(buf[:], uint64(c.NumWeights()))
+	if _, err := bw.Write(buf[:]); err != nil {
+		return err
+	}
+
+	crc := crc32.NewIEEE()
+	dw := io.MultiWriter(bw, crc)
+	var err error
+	c.Weights(func(u *Unit) {
+		if err != nil {
+			return
+		}
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(u.Val))
+		_, err = dw.Write(buf[:])
+	})
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(buf[:4], crc.Sum32())
+	if _, err := bw.Write(buf[:4]); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// LoadWeightsBinary sets the internal weights of a controller to those written by SaveWeightsBinary.
+// It returns a ChecksumError if the weights are corrupted, in which case the weights of c are left unchanged.
+func LoadWeightsBinary(c Controller, r io.Reader) error {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(weightsMagic)+2+8)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return err
+	}
+	if string(header[:len(weightsMagic)]) != weightsMagic {
+		return fmt.Errorf("ntm: not a binary weights file")
+	}
+	if v := binary.LittleEndian.Uint16(header[len(weightsMagic):]); v != weightsVersion {
+		return fmt.Errorf("ntm: binary weights version %d, expected %d", v, weightsVersion)
+	}
+	if n := binary.LittleEndian.Uint64(header[len(weightsMagic)+2:]); n != uint64(c.NumWeights()) {
+		return fmt.Errorf("ntm: %d binary weights, expected %d", n, c.NumWeights())
+	}
+
+	data := make([]byte, 8*c.NumWeights()+4)
+	if _, err := io.ReadFull(br, data); err != nil {
+		return err
+	}
+	vals, sum := data[:len(data)-4], data[len(data)-4:]
+	if got, expected := crc32.ChecksumIEEE(vals), binary.LittleEndian.Uint32(sum); got != expected {
+		return ChecksumError{Expected: expected, Got: got}
+	}
+	i := 0
+	c.Weights(func(u *Unit) {
+		u.Val = math.Float64frombits(binary.LittleEndian.Uint64(vals[8*i:]))
+		i++
+	})
+	return nil
+}
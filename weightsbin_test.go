@@ -0,0 +1,105 @@
+package ntm
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+func TestWeightsBinary(t *testing.T) {
+	c := NewEmptyController1(3, 2, 4, 2, 5, 3)
+	rnd := rand.New(rand.NewSource(17))
+	c.Weights(func(u *Unit) { u.Val = rnd.NormFloat64() })
+	var buf bytes.Buffer
+	if err := SaveWeightsBinary(c, &buf); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if expected := 4 + 2 + 8 + 8*c.NumWeights() + 4; buf.Len() != expected {
+		t.Errorf("%d bytes, expected %d", buf.Len(), expected)
+	}
+
+	d := NewEmptyController1(3, 2, 4, 2, 5, 3)
+	if err := LoadWeightsBinary(d, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("%v", err)
+	}
+	ws := Snapshot(c)
+	for i, w := range Snapshot(d) {
+		if w != ws[i] {
+			t.Errorf("[%d] %f != %f", i, w, ws[i])
+		}
+	}
+
+	// A controller of a different size is rejected.
+	e := NewEmptyController1(3, 2, 5, 2, 5, 3)
+	if err := LoadWeightsBinary(e, bytes.NewReader(buf.Bytes())); err == nil {
+		t.Errorf("no error for a controller with %d weights", e.NumWeights())
+	}
+}
+
+func TestWeightsBinaryCorrupted(t *testing.T) {
+	c := NewEmptyController1(3, 2, 4, 2, 5, 3)
+	rnd := rand.New(rand.NewSource(18))
+	c.Weights(func(u *Unit) { u.Val = rnd.NormFloat64() })
+	var buf bytes.Buffer
+	if err := SaveWeightsBinary(c, &buf); err != nil {
+		t.Fatalf("%v", err)
+	}
+	data := buf.Bytes()
+	data[4+2+8+8*7+3] ^= 0x10
+
+	d := NewEmptyController1(3, 2, 4, 2, 5, 3)
+	err := LoadWeightsBinary(d, bytes.NewReader(data))
+	if _, ok := err.(ChecksumError); !ok {
+		t.Fatalf("error %v, expected a ChecksumError", err)
+	}
+	d.Weights(func(u *Unit) {
+		if u.Val != 0 {
+			t.Fatalf("weights changed by a corrupted load")
+		}
+	})
+}
+
+// benchmarkController returns a controller of about 20k weights.
+func benchmarkController() Controller {
+	c := NewEmptyController1(10, 8, 160, 1, 128, 20)
+	rnd := rand.New(rand.NewSource(19))
+	c.Weights(func(u *Unit) { u.Val = rnd.NormFloat64() })
+	return c
+}
+
+func BenchmarkLoadWeightsBinary(b *testing.B) {
+	c := benchmarkController()
+	var buf bytes.Buffer
+	if err := SaveWeightsBinary(c, &buf); err != nil {
+		b.Fatalf("%v", err)
+	}
+	b.SetBytes(int64(buf.Len()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := LoadWeightsBinary(c, bytes.NewReader(buf.Bytes())); err != nil {
+			b.Fatalf("%v", err)
+		}
+	}
+}
+
+func BenchmarkLoadWeightsJSON(b *testing.B) {
+	c := benchmarkController()
+	data, err := json.Marshal(Snapshot(c))
+	if err != nil {
+		b.Fatalf("%v", err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ws := make([]float64, 0)
+		if err := json.Unmarshal(data, &ws); err != nil {
+			b.Fatalf("%v", err)
+		}
+		j := 0
+		c.Weights(func(u *Unit) {
+			u.Val = ws[j]
+			j++
+		})
+	}
+}